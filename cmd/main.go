@@ -1,182 +1,81 @@
 package main
 
 import (
-	"bufio"
-	"encoding/json"
-	"io"
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/sebastian-nunez/golang-language-server-protocol/compiler"
-	"github.com/sebastian-nunez/golang-language-server-protocol/lsp"
-	"github.com/sebastian-nunez/golang-language-server-protocol/rpc"
+	"github.com/sebastian-nunez/golang-language-server-protocol/record"
+	"github.com/sebastian-nunez/golang-language-server-protocol/server"
 	"github.com/sebastian-nunez/golang-language-server-protocol/util"
 )
 
 func main() {
-	// Since the LSP will be using `os.Stdout` to send messages,
-	// we are unable to use `os.Stdout` to log messages.
-	logger := util.NewFileLogger("lsp_logs.txt")
-	logger.Println("Starting the LSP...")
-
-	state := compiler.NewState()
-	writer := os.Stdout
-
-	scanner := bufio.NewScanner(os.Stdin)
-	scanner.Split(rpc.SplitMessage)
-	for scanner.Scan() {
-		msg := scanner.Bytes()
-		method, content, err := rpc.DecodeMessage(msg)
-		if err != nil {
-			logger.Printf("Error decoding message: %v", err)
-			continue
-		}
-		handleMessage(logger, state, writer, method, content)
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		os.Exit(replay(os.Args[2:]))
 	}
-}
 
-// handleMessage handles the incoming message from the client and sends the appropriate response (if needed).
-func handleMessage(logger *log.Logger, state *compiler.State, writer io.Writer, method string, content []byte) {
-	switch method {
-	case "initialize":
-		var request lsp.InitializeRequest
-		if err := json.Unmarshal(content, &request); err != nil {
-			logger.Printf("Error unmarshalling initialize request: %v", err)
-			return
-		}
-
-		version := "unknown"
-		if request.Params.ClientInfo != nil && *request.Params.ClientInfo.Version != "" {
-			version = *request.Params.ClientInfo.Version
-		}
-		logger.Printf("Connected to client: %s (version=%s)",
-			request.Params.ClientInfo.Name,
-			version,
-		)
+	listen := flag.String("listen", "", "accept clients on `address`: tcp://host:port, unix:///path/to/socket or ws://host:port/path[?origin=https://allowed.example]")
+	pipe := flag.String("pipe", "", "connect to the client through the named pipe (or Unix socket) `name`")
+	recordPath := flag.String("record", "", "record every message of the session to the JSONL trace `file`, see the replay subcommand")
+	flag.Bool("stdio", false, "communicate with the client over stdin and stdout (the default)")
+	flag.Parse()
 
-		response := lsp.NewInitializeResponse(request.ID)
-		writeResponse(writer, response)
-		logger.Println("Sent initialize response")
-	case "textDocument/didOpen":
-		var request lsp.DidOpenTextDocumentNotification
-		if err := json.Unmarshal(content, &request); err != nil {
-			logger.Printf("Error unmarshalling textDocument/didOpen: %v", err)
-			return
-		}
-
-		logger.Printf("Opened text document: URI=%v", request.Params.TextDocument.URI)
-		diagnostics, err := state.OpenDocument(request.Params.TextDocument.URI, request.Params.TextDocument.Text)
-		if err != nil {
-			logger.Printf("Error opening document: %v", err)
-		}
-
-		writeResponse(writer, lsp.PublishDiagnosticsNotification{
-			Notification: lsp.Notification{
-				RPC:    "2.0",
-				Method: "textDocument/publishDiagnostics",
-			},
-			Params: lsp.PublishDiagnosticsParams{
-				URI:         request.Params.TextDocument.URI,
-				Diagnostics: diagnostics,
-			},
-		})
-	case "textDocument/didChange":
-		var request lsp.TextDocumentDidChangeNotification
-		if err := json.Unmarshal(content, &request); err != nil {
-			logger.Printf("Error unmarshalling textDocument/didChange: %v", err)
-			return
-		}
-
-		for _, change := range request.Params.ContentChanges {
-			logger.Printf("Changed text document: URI=%v", request.Params.TextDocument.URI)
-			diagnostics, err := state.UpdateDocument(request.Params.TextDocument.URI, change.Text)
-			if err != nil {
-				logger.Printf("Error updating document: %v", err)
-			}
-
-			writeResponse(writer, lsp.PublishDiagnosticsNotification{
-				Notification: lsp.Notification{
-					RPC:    "2.0",
-					Method: "textDocument/publishDiagnostics",
-				},
-				Params: lsp.PublishDiagnosticsParams{
-					URI:         request.Params.TextDocument.URI,
-					Diagnostics: diagnostics,
-				},
-			})
-		}
-	case "textDocument/hover":
-		var request lsp.TextDocumentHoverRequest
-		if err := json.Unmarshal(content, &request); err != nil {
-			logger.Printf("Error unmarshalling textDocument/hover: %v", err)
-			return
-		}
-
-		logger.Printf("Hovered over text document: URI=%v, character=%v, line=%v",
-			request.Params.TextDocument.URI,
-			request.Params.Position.Character,
-			request.Params.Position.Line,
-		)
-
-		response, err := state.Hover(request.Params.TextDocument.URI, request.ID, request.Params.Position)
-		if err != nil {
-			logger.Printf("Error getting hover response: %v", err)
-		}
+	if *listen != "" && *pipe != "" {
+		fmt.Fprintln(os.Stderr, "--listen and --pipe cannot be used together")
+		os.Exit(2)
+	}
+	if *listen != "" && *recordPath != "" {
+		fmt.Fprintln(os.Stderr, "--record can only record a single session, it cannot be used with --listen")
+		os.Exit(2)
+	}
 
-		writeResponse(writer, response)
-		logger.Println("Sent hover response")
-	case "textDocument/definition":
-		var request lsp.TextDocumentDefinitionRequest
-		if err := json.Unmarshal(content, &request); err != nil {
-			logger.Printf("Error unmarshalling textDocument/definition: %v", err)
-			return
-		}
+	// Since the LSP may be using `os.Stdout` to send messages,
+	// we are unable to use `os.Stdout` to log messages.
+	logger := util.NewFileLogger("lsp_logs.txt")
+	logger.Println("Starting the LSP...")
 
-		logger.Printf("Definition of text document: URI=%v, character=%v, line=%v",
-			request.Params.TextDocument.URI,
-			request.Params.Position.Character,
-			request.Params.Position.Line,
-		)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-		response, err := state.Definition(request.Params.TextDocument.URI, request.ID, request.Params.Position)
+	transport := func(t server.Transport) server.Transport { return t }
+	if *recordPath != "" {
+		file, err := os.Create(*recordPath)
 		if err != nil {
-			logger.Printf("Error getting definition response: %v", err)
+			fmt.Fprintf(os.Stderr, "unable to create trace file: %v\n", err)
+			os.Exit(1)
 		}
+		defer file.Close()
 
-		writeResponse(writer, response)
-		logger.Println("Sent definition response")
-
-	case "textDocument/codeAction":
-		var request lsp.TextDocumentDefinitionRequest
-		if err := json.Unmarshal(content, &request); err != nil {
-			logger.Printf("Error unmarshalling textDocument/codeAction: %v", err)
-			return
-		}
-
-		response, err := state.TextDocumentCodeAction(request.ID, request.Params.TextDocument.URI)
-		if err != nil {
-			logger.Printf("Error getting codeAction response: %v", err)
-		}
+		recorder := record.NewRecorder(file)
+		transport = func(t server.Transport) server.Transport { return server.NewRecordingTransport(t, recorder) }
+		logger.Printf("Recording the session to %s", *recordPath)
+	}
 
-		writeResponse(writer, response)
-		logger.Println("Sent codeAction response")
-	case "textDocument/completion":
-		var request lsp.TextDocumentCompletionRequest
-		if err := json.Unmarshal(content, &request); err != nil {
-			logger.Printf("Error unmarshalling textDocument/completion: %v", err)
-			return
+	var err error
+	switch {
+	case *listen != "":
+		err = server.ListenAndServe(ctx, *listen, logger)
+	case *pipe != "":
+		conn, dialErr := server.DialPipe(*pipe)
+		if dialErr != nil {
+			err = dialErr
+			break
 		}
-
-		response := state.TextDocumentCompletion(request.ID, request.Params.TextDocument.URI)
-
-		writeResponse(writer, response)
-		logger.Println("Sent completion response")
+		defer conn.Close()
+		err = server.NewWithTransport(transport(server.NewStreamTransport(conn, conn)), logger, compiler.NewState()).Serve(ctx)
 	default:
-		logger.Printf("Received message: method=%v, content=%v", method, string(content))
+		err = server.NewWithTransport(transport(server.NewStreamTransport(os.Stdin, os.Stdout)), logger, compiler.NewState()).Serve(ctx)
 	}
-}
 
-func writeResponse(writer io.Writer, msg any) {
-	encodedMsg := rpc.EncodeMessage(msg)
-	writer.Write([]byte(encodedMsg))
+	if err != nil && !errors.Is(err, context.Canceled) {
+		logger.Printf("Server stopped: %v", err)
+		stop()
+		os.Exit(1)
+	}
 }
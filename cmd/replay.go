@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/sebastian-nunez/golang-language-server-protocol/record"
+	"github.com/sebastian-nunez/golang-language-server-protocol/server"
+	"github.com/sebastian-nunez/golang-language-server-protocol/util"
+)
+
+// replay feeds a trace recorded with `--record` into a fresh server and prints every message that
+// differs from the recording. It returns the exit code of the command.
+func replay(args []string) int {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: replay <trace.jsonl>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to open trace: %v\n", err)
+		return 1
+	}
+	defer file.Close()
+
+	entries, err := record.ReadTrace(file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	logger := util.NewFileLogger("replay_logs.txt")
+	mismatches, err := server.Replay(context.Background(), entries, logger)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to replay trace: %v\n", err)
+		return 1
+	}
+
+	for _, mismatch := range mismatches {
+		fmt.Println(mismatch)
+	}
+	if len(mismatches) > 0 {
+		fmt.Printf("%d messages differ from the recording\n", len(mismatches))
+		return 1
+	}
+	fmt.Printf("Replayed %d messages, the responses match the recording\n", len(entries))
+	return 0
+}
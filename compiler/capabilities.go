@@ -0,0 +1,68 @@
+package compiler
+
+import (
+	"github.com/sebastian-nunez/golang-language-server-protocol/lsp"
+)
+
+// ApplyEditCommand is the command returned instead of code actions to clients that only support
+// commands. Its single argument is the `lsp.WorkspaceEdit` to apply.
+const ApplyEditCommand = "golang-lsp.applyEdit"
+
+// clientFeatures are the features of the client that the responses are tailored to. The zero value
+// is a client that declared no capabilities.
+type clientFeatures struct {
+	// hoverFormat is the format of the hover contents.
+	hoverFormat lsp.MarkupKind
+	// documentationFormat is the format of the documentation of completion items.
+	documentationFormat lsp.MarkupKind
+	// snippets is true if completion items may insert snippets.
+	snippets bool
+	// codeActionLiterals is true if code actions may be returned, rather than commands.
+	codeActionLiterals bool
+	// relatedInformation is true if diagnostics may point to related locations.
+	relatedInformation bool
+	// hierarchicalSymbols is true if document symbols may be nested, rather than flattened.
+	hierarchicalSymbols bool
+}
+
+// newClientFeatures extracts the features that the responses are tailored to from the capabilities
+// declared by the client.
+func newClientFeatures(capabilities lsp.ClientCapabilities) clientFeatures {
+	features := clientFeatures{
+		hoverFormat:         lsp.MarkupKindPlainText,
+		documentationFormat: lsp.MarkupKindPlainText,
+	}
+
+	textDocument := capabilities.TextDocument
+	if textDocument == nil {
+		return features
+	}
+	if hover := textDocument.Hover; hover != nil {
+		features.hoverFormat = preferredMarkupKind(hover.ContentFormat)
+	}
+	if completion := textDocument.Completion; completion != nil && completion.CompletionItem != nil {
+		features.documentationFormat = preferredMarkupKind(completion.CompletionItem.DocumentationFormat)
+		features.snippets = completion.CompletionItem.SnippetSupport
+	}
+	if codeAction := textDocument.CodeAction; codeAction != nil {
+		features.codeActionLiterals = codeAction.CodeActionLiteralSupport != nil
+	}
+	if diagnostics := textDocument.PublishDiagnostics; diagnostics != nil {
+		features.relatedInformation = diagnostics.RelatedInformation
+	}
+	if documentSymbol := textDocument.DocumentSymbol; documentSymbol != nil {
+		features.hierarchicalSymbols = documentSymbol.HierarchicalDocumentSymbolSupport
+	}
+	return features
+}
+
+// preferredMarkupKind returns the first format supported by both the client and the server,
+// following the client's order of preference. It falls back to plain text.
+func preferredMarkupKind(supported []lsp.MarkupKind) lsp.MarkupKind {
+	for _, kind := range supported {
+		if kind == lsp.MarkupKindMarkdown || kind == lsp.MarkupKindPlainText {
+			return kind
+		}
+	}
+	return lsp.MarkupKindPlainText
+}
@@ -0,0 +1,67 @@
+package compiler
+
+import (
+	"testing"
+
+	"github.com/sebastian-nunez/golang-language-server-protocol/lsp"
+)
+
+func TestNewClientFeatures(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name         string
+		capabilities lsp.ClientCapabilities
+		want         clientFeatures
+	}{
+		{
+			name:         "no capabilities",
+			capabilities: lsp.ClientCapabilities{},
+			want: clientFeatures{
+				hoverFormat:         lsp.MarkupKindPlainText,
+				documentationFormat: lsp.MarkupKindPlainText,
+			},
+		},
+		{
+			name: "every capability",
+			capabilities: lsp.ClientCapabilities{TextDocument: &lsp.TextDocumentClientCapabilities{
+				Hover: &lsp.HoverClientCapabilities{ContentFormat: []lsp.MarkupKind{lsp.MarkupKindMarkdown}},
+				Completion: &lsp.CompletionClientCapabilities{CompletionItem: &lsp.CompletionItemClientCapabilities{
+					SnippetSupport:      true,
+					DocumentationFormat: []lsp.MarkupKind{lsp.MarkupKindMarkdown, lsp.MarkupKindPlainText},
+				}},
+				CodeAction:         &lsp.CodeActionClientCapabilities{CodeActionLiteralSupport: &lsp.CodeActionLiteralSupport{}},
+				PublishDiagnostics: &lsp.PublishDiagnosticsClientCapabilities{RelatedInformation: true},
+				DocumentSymbol:     &lsp.DocumentSymbolClientCapabilities{HierarchicalDocumentSymbolSupport: true},
+			}},
+			want: clientFeatures{
+				hoverFormat:         lsp.MarkupKindMarkdown,
+				documentationFormat: lsp.MarkupKindMarkdown,
+				snippets:            true,
+				codeActionLiterals:  true,
+				relatedInformation:  true,
+				hierarchicalSymbols: true,
+			},
+		},
+		{
+			name: "unknown formats are skipped",
+			capabilities: lsp.ClientCapabilities{TextDocument: &lsp.TextDocumentClientCapabilities{
+				Hover:      &lsp.HoverClientCapabilities{ContentFormat: []lsp.MarkupKind{"html", lsp.MarkupKindMarkdown}},
+				Completion: &lsp.CompletionClientCapabilities{},
+				CodeAction: &lsp.CodeActionClientCapabilities{},
+			}},
+			want: clientFeatures{
+				hoverFormat:         lsp.MarkupKindMarkdown,
+				documentationFormat: lsp.MarkupKindPlainText,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := newClientFeatures(tc.capabilities); got != tc.want {
+				t.Errorf("newClientFeatures got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package compiler
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/sebastian-nunez/golang-language-server-protocol/lsp"
+	"github.com/sebastian-nunez/golang-language-server-protocol/markdown"
+)
+
+// linkAt returns the innermost link, image, footnote reference or link reference definition that
+// contains the offset, or nil if there is none.
+func linkAt(tree *markdown.Node, offset int) *markdown.Node {
+	if tree == nil {
+		return nil
+	}
+
+	var link *markdown.Node
+	markdown.Walk(tree, func(n *markdown.Node) bool {
+		if offset < n.Start || offset >= n.End {
+			return n.Kind == markdown.KindDocument
+		}
+		switch n.Kind {
+		case markdown.KindLink, markdown.KindImage, markdown.KindFootnoteReference, markdown.KindLinkReferenceDefinition:
+			link = n
+		}
+		return true
+	})
+	return link
+}
+
+// fileLink is a link to a file that is not open, which must be read from disk to be resolved.
+type fileLink struct {
+	path     string
+	fragment string
+}
+
+// definition returns the location that a link of the document refers to, or nil if it cannot be
+// resolved. Links to files that are not open are returned instead, to be resolved by `resolveFile`
+// without holding the lock. The caller must hold the lock, and pass the workspace folders.
+func (s *State) definition(roots []string, uri lsp.DocumentURI, doc *Document, tree *markdown.Node, link *markdown.Node) (*lsp.Location, *fileLink) {
+	if link == nil {
+		return nil, nil
+	}
+
+	switch {
+	case link.Kind == markdown.KindFootnoteReference:
+		return locationOf(uri, doc, findDefinition(tree, markdown.KindFootnoteDefinition, link.Label)), nil
+	case link.Kind != markdown.KindLinkReferenceDefinition && link.Label != "":
+		return locationOf(uri, doc, findDefinition(tree, markdown.KindLinkReferenceDefinition, link.Label)), nil
+	}
+	return s.resolveDestination(roots, uri, doc, tree, link.Destination)
+}
+
+// findDefinition returns the first definition of the kind with the normalized label. Like the
+// parser, it ignores the definitions that come after it.
+func findDefinition(tree *markdown.Node, kind markdown.Kind, label string) *markdown.Node {
+	var definition *markdown.Node
+	markdown.Walk(tree, func(n *markdown.Node) bool {
+		if definition == nil && n.Kind == kind && n.Label == label {
+			definition = n
+		}
+		return definition == nil && n.Kind.IsBlock()
+	})
+	return definition
+}
+
+func locationOf(uri lsp.DocumentURI, doc *Document, n *markdown.Node) *lsp.Location {
+	if n == nil {
+		return nil
+	}
+	r := doc.rangeAt(n.Start, n.End)
+	return &lsp.Location{URI: uri, Range: &r}
+}
+
+// resolveDestination returns the location of the destination of a link: the heading that its
+// fragment names, or the start of the file it points at. Relative paths are resolved against the
+// folder of the document and paths starting with `/` against its workspace folder, as GitHub does.
+//
+// Links to other websites and to missing headings of the document itself cannot be resolved.
+func (s *State) resolveDestination(roots []string, uri lsp.DocumentURI, doc *Document, tree *markdown.Node, destination string) (*lsp.Location, *fileLink) {
+	u, err := url.Parse(destination)
+	if err != nil || u.Scheme != "" || u.Host != "" {
+		return nil, nil
+	}
+
+	target := uri
+	if u.Path != "" {
+		path, ok := resolvePath(roots, uri, u.Path)
+		if !ok {
+			return nil, nil
+		}
+		if target, doc, tree, ok = s.openDocument(path); !ok {
+			return nil, &fileLink{path: path, fragment: u.Fragment}
+		}
+	}
+
+	if u.Fragment != "" {
+		if heading := findHeading(tree, u.Fragment); heading != nil {
+			return locationOf(target, doc, heading), nil
+		}
+	}
+	if target == uri {
+		return nil, nil
+	}
+	return &lsp.Location{URI: target, Range: &lsp.Range{}}, nil
+}
+
+// resolvePath returns the path of a file linked from the document.
+func resolvePath(roots []string, uri lsp.DocumentURI, path string) (string, bool) {
+	docPath, err := uri.Path()
+	if err != nil {
+		return "", false
+	}
+	base := filepath.Dir(docPath)
+	if strings.HasPrefix(path, "/") {
+		var ok bool
+		if base, ok = rootOf(roots, docPath); !ok {
+			return "", false
+		}
+	}
+	return filepath.Join(base, filepath.FromSlash(path)), true
+}
+
+// openDocument returns the open document with the path, if any.
+func (s *State) openDocument(path string) (lsp.DocumentURI, *Document, *markdown.Node, bool) {
+	for uri, doc := range s.documents {
+		if openPath, err := uri.Path(); err == nil && openPath == path {
+			return uri, doc, s.trees[uri], true
+		}
+	}
+	return "", nil, nil, false
+}
+
+// resolveFile returns the location of a link to a file that is not open: the heading that its
+// fragment names or the start of the file. Links to files that do not exist and to folders cannot
+// be resolved.
+func resolveFile(link fileLink, encoding lsp.PositionEncodingKind) *lsp.Location {
+	info, err := os.Stat(link.path)
+	if err != nil || info.IsDir() {
+		return nil
+	}
+	uri := lsp.URIFromPath(link.path)
+	start := &lsp.Location{URI: uri, Range: &lsp.Range{}}
+	if link.fragment == "" || !isMarkdownFile(link.path) {
+		return start
+	}
+
+	text, err := os.ReadFile(link.path)
+	if err != nil {
+		return nil
+	}
+	if heading := findHeading(markdown.Parse(string(text)), link.fragment); heading != nil {
+		return locationOf(uri, NewDocument(string(text), encoding), heading)
+	}
+	return start
+}
+
+// findHeading returns the heading whose anchor is the fragment, ignoring case.
+func findHeading(tree *markdown.Node, fragment string) *markdown.Node {
+	occurrences := make(map[string]int)
+	for _, heading := range headings(tree) {
+		anchor := slug(headingText(heading))
+
+		// Headings with the same text get the anchors `text`, `text-1`, `text-2`...
+		base := anchor
+		for _, taken := occurrences[anchor]; taken; _, taken = occurrences[anchor] {
+			occurrences[base]++
+			anchor = base + "-" + strconv.Itoa(occurrences[base])
+		}
+		occurrences[anchor] = 0
+
+		if strings.EqualFold(anchor, fragment) {
+			return heading
+		}
+	}
+	return nil
+}
+
+// slug returns the anchor that GitHub gives to a heading with the text: the text in lower case,
+// without punctuation, and with dashes instead of spaces.
+func slug(text string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case r == ' ':
+			sb.WriteByte('-')
+		case r == '-' || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r):
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,174 @@
+package compiler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sebastian-nunez/golang-language-server-protocol/lsp"
+)
+
+func TestDefinitionFiles(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	readme := "# Project\n\n" +
+		"[usage](docs/usage.md#install-it) [config](/docs/config.md#settings) [logo](img/logo.png) " +
+		"[folder](docs) [missing](docs/none.md) [unknown anchor](docs/usage.md#none) [notes](docs/my%20notes.md) " +
+		"[parent](../outside.md)\n"
+	files := map[string]string{
+		"README.md":        readme,
+		"docs/usage.md":    "# Usage\n\n## Install it\n",
+		"docs/config.md":   "# Configuration\n",
+		"docs/my notes.md": "Notes.\n",
+		"img/logo.png":     "\x89PNG",
+		"../outside.md":    "# Outside\n",
+	}
+	for name, text := range files {
+		path := filepath.Join(root, "workspace", filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	uri := func(name string) lsp.DocumentURI {
+		return lsp.URIFromPath(filepath.Join(root, "workspace", filepath.FromSlash(name)))
+	}
+
+	state := NewState()
+	state.SetWorkspaceFolders([]lsp.DocumentURI{uri(".")})
+	// Open documents are resolved as they are in the editor.
+	for name, text := range map[string]string{"README.md": readme, "docs/config.md": "\n# Settings\n"} {
+		if _, err := state.OpenDocument(uri(name), 1, text); err != nil {
+			t.Fatalf("OpenDocument got unexpected error: %v", err)
+		}
+	}
+
+	testCases := []struct {
+		name string
+		// link is the text of the link that the cursor is on, in the README.
+		link string
+		want *lsp.Location
+	}{
+		{
+			name: "file and anchor",
+			link: "[usage]",
+			want: &lsp.Location{URI: uri("docs/usage.md"), Range: &lsp.Range{Start: lsp.Position{Line: 2}, End: lsp.Position{Line: 2, Character: 13}}},
+		},
+		{
+			name: "open document relative to the workspace folder",
+			link: "[config]",
+			want: &lsp.Location{URI: uri("docs/config.md"), Range: &lsp.Range{Start: lsp.Position{Line: 1}, End: lsp.Position{Line: 1, Character: 10}}},
+		},
+		{
+			name: "other file",
+			link: "[logo]",
+			want: &lsp.Location{URI: uri("img/logo.png"), Range: &lsp.Range{}},
+		},
+		{
+			name: "folder",
+			link: "[folder]",
+		},
+		{
+			name: "missing file",
+			link: "[missing]",
+		},
+		{
+			name: "unknown anchor",
+			link: "[unknown anchor]",
+			want: &lsp.Location{URI: uri("docs/usage.md"), Range: &lsp.Range{}},
+		},
+		{
+			name: "escaped path",
+			link: "[notes]",
+			want: &lsp.Location{URI: uri("docs/my notes.md"), Range: &lsp.Range{}},
+		},
+		{
+			name: "outside the workspace folder",
+			link: "[parent]",
+			want: &lsp.Location{URI: uri("../outside.md"), Range: &lsp.Range{}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			position := lsp.Position{Line: 2, Character: strings.Index(strings.Split(readme, "\n")[2], tc.link) + 1}
+			got, err := state.Definition(context.Background(), uri("README.md"), lsp.NewIntID(1), position)
+			if err != nil {
+				t.Fatalf("Definition got unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got.Result, tc.want) {
+				t.Errorf("Definition got %+v, want %+v", got.Result, tc.want)
+			}
+		})
+	}
+}
+
+func TestSlug(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		text string
+		want string
+	}{
+		{text: "Getting started", want: "getting-started"},
+		{text: "What's new in v2.0?", want: "whats-new-in-v20"},
+		{text: "snake_case and kebab-case", want: "snake_case-and-kebab-case"},
+		{text: "A  -  B", want: "a-----b"},
+		{text: "Über café", want: "über-café"},
+		{text: "日本語", want: "日本語"},
+		{text: "😀 Emoji", want: "-emoji"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.text, func(t *testing.T) {
+			t.Parallel()
+
+			if got := slug(tc.text); got != tc.want {
+				t.Errorf("slug(%q) = %q, want %q", tc.text, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFindHeading(t *testing.T) {
+	t.Parallel()
+
+	state := newTestState(map[lsp.DocumentURI]string{
+		"file:///a.md": "# Notes\n\n# Notes\n\n# Notes-1\n\n> ## Quoted *heading*\n\n#\n",
+	})
+	tree := state.trees["file:///a.md"]
+
+	testCases := []struct {
+		fragment string
+		wantLine int
+	}{
+		{fragment: "notes", wantLine: 0},
+		{fragment: "notes-1", wantLine: 2},
+		// The third heading cannot take `notes-1`, which is taken.
+		{fragment: "notes-1-1", wantLine: 4},
+		{fragment: "QUOTED-HEADING", wantLine: 6},
+		{fragment: "missing", wantLine: -1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.fragment, func(t *testing.T) {
+			t.Parallel()
+
+			line := -1
+			if heading := findHeading(tree, tc.fragment); heading != nil {
+				line = state.documents["file:///a.md"].PositionAt(heading.Start).Line
+			}
+			if line != tc.wantLine {
+				t.Errorf("findHeading(%q) got the heading on line %d, want %d", tc.fragment, line, tc.wantLine)
+			}
+		})
+	}
+}
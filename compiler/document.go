@@ -0,0 +1,274 @@
+package compiler
+
+import (
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/sebastian-nunez/golang-language-server-protocol/lsp"
+)
+
+// maxPieces is the number of pieces a document may be split into before it is compacted
+// back into a single piece. This bounds the cost of locating an offset in the piece table.
+const maxPieces = 512
+
+// pieceSource identifies the buffer a piece reads from.
+type pieceSource int
+
+const (
+	sourceOriginal pieceSource = iota
+	sourceAdded
+)
+
+// piece is a span of one of the document buffers.
+type piece struct {
+	source pieceSource
+	start  int
+	length int
+}
+
+// Document is the text of an open document stored as a piece table. Edits only append to the
+// added buffer and split pieces, so ranged changes never copy the whole document. The byte
+// offsets of every line start are cached and updated in place on each edit.
+type Document struct {
+	original string
+	added    []byte
+	pieces   []piece
+	length   int
+
+	// lineStarts holds the byte offset at which each line begins. It always starts with 0.
+	lineStarts []int
+	// text caches the materialized contents of the document until the next edit. It is atomic
+	// because it is filled in lazily by readers, which may run concurrently.
+	text atomic.Pointer[string]
+
+	// encoding is the unit in which the characters of LSP positions are counted.
+	encoding lsp.PositionEncodingKind
+	// version is the version of the document as reported by the client.
+	version int
+}
+
+func NewDocument(text string, encoding lsp.PositionEncodingKind) *Document {
+	d := &Document{encoding: encoding}
+	d.reset(text)
+	return d
+}
+
+// Version returns the version of the document as reported by the client.
+func (d *Document) Version() int {
+	return d.version
+}
+
+func (d *Document) reset(text string) {
+	d.original = text
+	d.added = nil
+	d.pieces = d.pieces[:0]
+	if len(text) > 0 {
+		d.pieces = append(d.pieces, piece{source: sourceOriginal, start: 0, length: len(text)})
+	}
+	d.length = len(text)
+	d.lineStarts = append(d.lineStarts[:0], 0)
+	d.lineStarts = appendLineStarts(d.lineStarts, text, 0)
+	d.text.Store(&text)
+}
+
+// Text returns the full contents of the document.
+func (d *Document) Text() string {
+	if text := d.text.Load(); text != nil {
+		return *text
+	}
+	text := d.slice(0, d.length)
+	d.text.Store(&text)
+	return text
+}
+
+// Len returns the length of the document in bytes.
+func (d *Document) Len() int {
+	return d.length
+}
+
+// LineCount returns the number of lines in the document. An empty document has one line.
+func (d *Document) LineCount() int {
+	return len(d.lineStarts)
+}
+
+// Line returns the contents of the given line without its line terminator.
+func (d *Document) Line(line int) string {
+	if line < 0 || line >= len(d.lineStarts) {
+		return ""
+	}
+	start, end := d.lineBounds(line)
+	return d.slice(start, end)
+}
+
+// lineBounds returns the byte offsets at which the line starts and ends, excluding the `\n`.
+func (d *Document) lineBounds(line int) (start, end int) {
+	start = d.lineStarts[line]
+	end = d.length
+	if line+1 < len(d.lineStarts) {
+		end = d.lineStarts[line+1] - 1
+	}
+	return start, end
+}
+
+// OffsetAt converts an LSP position into a byte offset within the document. Characters are
+// counted in the document's position encoding.
+//
+// Positions past the end of a line are clamped to the end of that line and lines past the
+// end of the document are clamped to the end of the document, mirroring how clients treat them.
+func (d *Document) OffsetAt(position lsp.Position) int {
+	if position.Line < 0 {
+		return 0
+	}
+	if position.Line >= len(d.lineStarts) {
+		return d.length
+	}
+
+	start, end := d.lineBounds(position.Line)
+	line := strings.TrimSuffix(d.slice(start, end), "\r")
+	return start + columnToByte(line, position.Character, d.encoding)
+}
+
+// PositionAt converts a byte offset within the document into an LSP position.
+func (d *Document) PositionAt(offset int) lsp.Position {
+	offset = max(0, min(offset, d.length))
+
+	line := sort.Search(len(d.lineStarts), func(i int) bool { return d.lineStarts[i] > offset }) - 1
+	start := d.lineStarts[line]
+	return lsp.Position{
+		Line:      line,
+		Character: byteToColumn(d.slice(start, offset), offset-start, d.encoding),
+	}
+}
+
+// lineRange creates a range on a single line from byte indexes within that line.
+func (d *Document) lineRange(line, start, end int) lsp.Range {
+	text := d.Line(line)
+	return LineRange(line, byteToColumn(text, start, d.encoding), byteToColumn(text, end, d.encoding))
+}
+
+// rangeAt creates a range from byte offsets within the document.
+func (d *Document) rangeAt(start, end int) lsp.Range {
+	return lsp.Range{Start: d.PositionAt(start), End: d.PositionAt(end)}
+}
+
+// ApplyChange applies a content change event to the document. Changes without a range
+// replace the whole document.
+func (d *Document) ApplyChange(change lsp.TextDocumentContentChangeEvent) {
+	if change.Range == nil {
+		d.reset(change.Text)
+		return
+	}
+
+	start := d.OffsetAt(change.Range.Start)
+	end := d.OffsetAt(change.Range.End)
+	if end < start {
+		start, end = end, start
+	}
+	d.replace(start, end, change.Text)
+}
+
+// replace swaps the bytes in [start, end) for the given text.
+func (d *Document) replace(start, end int, text string) {
+	first := d.split(start)
+	last := d.split(end)
+
+	var inserted []piece
+	if len(text) > 0 {
+		inserted = append(inserted, piece{source: sourceAdded, start: len(d.added), length: len(text)})
+		d.added = append(d.added, text...)
+	}
+	d.pieces = append(d.pieces[:first], append(inserted, d.pieces[last:]...)...)
+	d.length += len(text) - (end - start)
+	d.updateLineStarts(start, end, text)
+	d.text.Store(nil)
+
+	if len(d.pieces) > maxPieces {
+		d.compact()
+	}
+}
+
+// split ensures a piece boundary exists at the given offset and returns the index of the
+// piece that starts there (or len(pieces) if the offset is the end of the document).
+func (d *Document) split(offset int) int {
+	pos := 0
+	for i, p := range d.pieces {
+		if offset == pos {
+			return i
+		}
+		if offset < pos+p.length {
+			head := piece{source: p.source, start: p.start, length: offset - pos}
+			tail := piece{source: p.source, start: p.start + head.length, length: p.length - head.length}
+			d.pieces = append(d.pieces[:i+1], d.pieces[i:]...)
+			d.pieces[i], d.pieces[i+1] = head, tail
+			return i + 1
+		}
+		pos += p.length
+	}
+	return len(d.pieces)
+}
+
+// updateLineStarts drops the line starts that were inside the replaced range, shifts the
+// ones after it and adds the ones introduced by the inserted text.
+func (d *Document) updateLineStarts(start, end int, text string) {
+	lo := sort.SearchInts(d.lineStarts, start+1)
+	hi := sort.SearchInts(d.lineStarts, end+1)
+
+	delta := len(text) - (end - start)
+	tail := d.lineStarts[hi:]
+	for i := range tail {
+		tail[i] += delta
+	}
+
+	inserted := appendLineStarts(nil, text, start)
+	d.lineStarts = append(d.lineStarts[:lo], append(inserted, tail...)...)
+}
+
+// compact collapses the piece table into a single piece.
+func (d *Document) compact() {
+	text := d.Text()
+	d.original = text
+	d.added = nil
+	d.pieces = append(d.pieces[:0], piece{source: sourceOriginal, start: 0, length: len(text)})
+}
+
+// slice returns the bytes in [start, end) of the document.
+func (d *Document) slice(start, end int) string {
+	if start >= end {
+		return ""
+	}
+	if text := d.text.Load(); text != nil {
+		return (*text)[start:end]
+	}
+
+	var sb strings.Builder
+	sb.Grow(end - start)
+	pos := 0
+	for _, p := range d.pieces {
+		pieceEnd := pos + p.length
+		if pieceEnd > start && pos < end {
+			from := max(start, pos) - pos + p.start
+			to := min(end, pieceEnd) - pos + p.start
+			if p.source == sourceOriginal {
+				sb.WriteString(d.original[from:to])
+			} else {
+				sb.Write(d.added[from:to])
+			}
+		}
+		if pieceEnd >= end {
+			break
+		}
+		pos = pieceEnd
+	}
+	return sb.String()
+}
+
+// appendLineStarts appends the offset following every `\n` in the text, shifted by base.
+func appendLineStarts(lineStarts []int, text string, base int) []int {
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			lineStarts = append(lineStarts, base+i+1)
+		}
+	}
+	return lineStarts
+}
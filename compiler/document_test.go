@@ -0,0 +1,245 @@
+package compiler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sebastian-nunez/golang-language-server-protocol/lsp"
+)
+
+func TestDocumentOffsetAt(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		text     string
+		position lsp.Position
+		want     int
+	}{
+		{
+			name:     "start of document",
+			text:     "hello\nworld",
+			position: lsp.Position{Line: 0, Character: 0},
+			want:     0,
+		},
+		{
+			name:     "start of second line",
+			text:     "hello\nworld",
+			position: lsp.Position{Line: 1, Character: 0},
+			want:     6,
+		},
+		{
+			name:     "character past end of line is clamped",
+			text:     "hello\nworld",
+			position: lsp.Position{Line: 0, Character: 42},
+			want:     5,
+		},
+		{
+			name:     "line past end of document is clamped",
+			text:     "hello\nworld",
+			position: lsp.Position{Line: 7, Character: 0},
+			want:     11,
+		},
+		{
+			name:     "carriage return is not part of the line",
+			text:     "hello\r\nworld",
+			position: lsp.Position{Line: 0, Character: 10},
+			want:     5,
+		},
+		{
+			name:     "multi-byte rune counts as one code unit",
+			text:     "héllo",
+			position: lsp.Position{Line: 0, Character: 2},
+			want:     3,
+		},
+		{
+			name:     "astral rune counts as two code units",
+			text:     "a😀b",
+			position: lsp.Position{Line: 0, Character: 3},
+			want:     5,
+		},
+		{
+			name:     "middle of surrogate pair resolves to rune start",
+			text:     "a😀b",
+			position: lsp.Position{Line: 0, Character: 2},
+			want:     1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc := NewDocument(tc.text, lsp.PositionEncodingUTF16)
+			if got := doc.OffsetAt(tc.position); got != tc.want {
+				t.Errorf("OffsetAt got = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDocumentPositionAt(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		text   string
+		offset int
+		want   lsp.Position
+	}{
+		{
+			name:   "start of document",
+			text:   "hello\nworld",
+			offset: 0,
+			want:   lsp.Position{Line: 0, Character: 0},
+		},
+		{
+			name:   "newline belongs to the first line",
+			text:   "hello\nworld",
+			offset: 5,
+			want:   lsp.Position{Line: 0, Character: 5},
+		},
+		{
+			name:   "end of document",
+			text:   "hello\nworld",
+			offset: 11,
+			want:   lsp.Position{Line: 1, Character: 5},
+		},
+		{
+			name:   "offset past end of document is clamped",
+			text:   "hello\n",
+			offset: 100,
+			want:   lsp.Position{Line: 1, Character: 0},
+		},
+		{
+			name:   "astral rune counts as two code units",
+			text:   "a😀b",
+			offset: 5,
+			want:   lsp.Position{Line: 0, Character: 3},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc := NewDocument(tc.text, lsp.PositionEncodingUTF16)
+			if got := doc.PositionAt(tc.offset); got != tc.want {
+				t.Errorf("PositionAt got = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDocumentApplyChange(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		text      string
+		changes   []lsp.TextDocumentContentChangeEvent
+		wantText  string
+		wantLines []string
+	}{
+		{
+			name: "full replacement",
+			text: "hello",
+			changes: []lsp.TextDocumentContentChangeEvent{
+				{Text: "goodbye\nworld"},
+			},
+			wantText:  "goodbye\nworld",
+			wantLines: []string{"goodbye", "world"},
+		},
+		{
+			name: "insert line break",
+			text: "helloworld",
+			changes: []lsp.TextDocumentContentChangeEvent{
+				{Range: &lsp.Range{Start: lsp.Position{Line: 0, Character: 5}, End: lsp.Position{Line: 0, Character: 5}}, Text: "\n"},
+			},
+			wantText:  "hello\nworld",
+			wantLines: []string{"hello", "world"},
+		},
+		{
+			name: "delete across lines",
+			text: "one\ntwo\nthree\nfour",
+			changes: []lsp.TextDocumentContentChangeEvent{
+				{Range: &lsp.Range{Start: lsp.Position{Line: 0, Character: 2}, End: lsp.Position{Line: 2, Character: 3}}, Text: ""},
+			},
+			wantText:  "onee\nfour",
+			wantLines: []string{"onee", "four"},
+		},
+		{
+			name: "sequential edits",
+			text: "# Title\n\nbody\n",
+			changes: []lsp.TextDocumentContentChangeEvent{
+				{Range: &lsp.Range{Start: lsp.Position{Line: 2, Character: 0}, End: lsp.Position{Line: 2, Character: 0}}, Text: "some "},
+				{Range: &lsp.Range{Start: lsp.Position{Line: 0, Character: 2}, End: lsp.Position{Line: 0, Character: 7}}, Text: "Heading\nmore"},
+				{Range: &lsp.Range{Start: lsp.Position{Line: 4, Character: 0}, End: lsp.Position{Line: 4, Character: 0}}, Text: "end"},
+			},
+			wantText:  "# Heading\nmore\n\nsome body\nend",
+			wantLines: []string{"# Heading", "more", "", "some body", "end"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc := NewDocument(tc.text, lsp.PositionEncodingUTF16)
+			for _, change := range tc.changes {
+				doc.ApplyChange(change)
+			}
+
+			if got := doc.Text(); got != tc.wantText {
+				t.Errorf("Text got = %q, want %q", got, tc.wantText)
+			}
+			if got := doc.LineCount(); got != len(tc.wantLines) {
+				t.Fatalf("LineCount got = %v, want %v", got, len(tc.wantLines))
+			}
+			for i, want := range tc.wantLines {
+				if got := doc.Line(i); got != want {
+					t.Errorf("Line(%d) got = %q, want %q", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDocumentManyEdits(t *testing.T) {
+	t.Parallel()
+
+	// Enough edits to force the piece table to be compacted at least once.
+	doc := NewDocument("start\n", lsp.PositionEncodingUTF16)
+	want := "start\n"
+	for i := 0; i < 2*maxPieces; i++ {
+		offset := (i * 7) % (len(want) + 1)
+		text := "x"
+		if i%3 == 0 {
+			text = "\n"
+		}
+
+		doc.replace(offset, offset, text)
+		want = want[:offset] + text + want[offset:]
+
+		if doc.Len() != len(want) {
+			t.Fatalf("Len got = %v, want %v", doc.Len(), len(want))
+		}
+	}
+
+	if got := doc.Text(); got != want {
+		t.Fatalf("Text got = %q, want %q", got, want)
+	}
+	wantLines := strings.Split(want, "\n")
+	for i, line := range wantLines {
+		if got := doc.Line(i); got != line {
+			t.Errorf("Line(%d) got = %q, want %q", i, got, line)
+		}
+	}
+}
+
+func BenchmarkDocumentApplyChange(b *testing.B) {
+	doc := NewDocument(strings.Repeat("Some line of markdown text with VS Code in it.\n", 100_000), lsp.PositionEncodingUTF16)
+	change := lsp.TextDocumentContentChangeEvent{
+		Range: &lsp.Range{Start: lsp.Position{Line: 50_000, Character: 4}, End: lsp.Position{Line: 50_000, Character: 4}},
+		Text:  "a",
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		doc.ApplyChange(change)
+	}
+}
@@ -0,0 +1,98 @@
+package compiler
+
+import (
+	"math"
+	"unicode"
+)
+
+// Scores of fuzzyMatch.
+const (
+	scoreMatch       = 16
+	scoreFirstChar   = 10
+	scoreWordStart   = 8
+	scoreConsecutive = 8
+	// A gap between two matched characters costs scoreGapStart plus scoreGapExtension for every
+	// skipped character after the first. Characters skipped before the first match cost
+	// scoreGapExtension each, up to maxLeadingGap.
+	scoreGapStart     = -3
+	scoreGapExtension = -1
+	maxLeadingGap     = -5
+)
+
+// fuzzyMatch reports whether the characters of the query appear in order in the name, ignoring
+// case, and scores the best way they do: matches at the start of the name or of its words and
+// runs of consecutive characters raise the score, while skipped characters lower it. Every name
+// matches an empty query with a score of 0.
+func fuzzyMatch(query, name string) (int, bool) {
+	q := []rune(query)
+	n := []rune(name)
+	if len(q) == 0 {
+		return 0, true
+	}
+	if len(q) > len(n) {
+		return 0, false
+	}
+
+	// prev[j] is the best score of the query so far with its last character matched at n[j].
+	unmatched := math.MinInt / 2
+	prev := make([]int, len(n))
+	cur := make([]int, len(n))
+	for j := range n {
+		prev[j] = unmatched
+		if equalFold(q[0], n[j]) {
+			prev[j] = scoreMatch + bonus(n, j) + max(j*scoreGapExtension, maxLeadingGap)
+		}
+	}
+	for i := 1; i < len(q); i++ {
+		// gap is the best score of matching the previous character at some k <= j-2, accounting
+		// for the characters skipped up to j.
+		gap := unmatched
+		for j := range n {
+			cur[j] = unmatched
+			if j >= 2 && prev[j-2] > unmatched {
+				gap = max(gap+scoreGapExtension, prev[j-2]+scoreGapStart)
+			} else if gap > unmatched {
+				gap += scoreGapExtension
+			}
+			if j == 0 || !equalFold(q[i], n[j]) {
+				continue
+			}
+
+			best := gap
+			if prev[j-1] > unmatched {
+				best = max(best, prev[j-1]+scoreConsecutive)
+			}
+			if best > unmatched {
+				cur[j] = best + scoreMatch + bonus(n, j)
+			}
+		}
+		prev, cur = cur, prev
+	}
+
+	score := unmatched
+	for _, s := range prev {
+		score = max(score, s)
+	}
+	return score, score > unmatched
+}
+
+// bonus returns the bonus of matching the character at index j of the name: the first character
+// and the first character of a word score higher.
+func bonus(n []rune, j int) int {
+	if j == 0 {
+		return scoreFirstChar
+	}
+	prev, c := n[j-1], n[j]
+	switch {
+	case !unicode.IsLetter(prev) && !unicode.IsDigit(prev) && (unicode.IsLetter(c) || unicode.IsDigit(c)):
+		return scoreWordStart
+	case unicode.IsLower(prev) && unicode.IsUpper(c):
+		// camelCase
+		return scoreWordStart
+	}
+	return 0
+}
+
+func equalFold(a, b rune) bool {
+	return a == b || unicode.ToLower(a) == unicode.ToLower(b)
+}
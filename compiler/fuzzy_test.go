@@ -0,0 +1,82 @@
+package compiler
+
+import (
+	"testing"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		query     string
+		candidate string
+		wantMatch bool
+	}{
+		{name: "empty query", query: "", candidate: "Install", wantMatch: true},
+		{name: "exact", query: "Install", candidate: "Install", wantMatch: true},
+		{name: "case insensitive", query: "inSTALL", candidate: "Install", wantMatch: true},
+		{name: "subsequence", query: "gst", candidate: "Getting started", wantMatch: true},
+		{name: "unicode", query: "ÉTÉ", candidate: "Un été", wantMatch: true},
+		{name: "out of order", query: "tsg", candidate: "Getting started", wantMatch: false},
+		{name: "missing character", query: "installx", candidate: "Install", wantMatch: false},
+		{name: "query longer than the name", query: "Installation", candidate: "Install", wantMatch: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, ok := fuzzyMatch(tc.query, tc.candidate); ok != tc.wantMatch {
+				t.Errorf("fuzzyMatch(%q, %q) got match = %v, want %v", tc.query, tc.candidate, ok, tc.wantMatch)
+			}
+		})
+	}
+}
+
+func TestFuzzyMatchRanking(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name  string
+		query string
+		// want is ordered from the best match to the worst.
+		want []string
+	}{
+		{
+			name:  "prefix before word start before scattered",
+			query: "conf",
+			want:  []string{"Configuration", "Server configuration", "Connect to a file"},
+		},
+		{
+			name:  "consecutive before scattered",
+			query: "api",
+			want:  []string{"API reference", "Rapid iteration", "Amplification"},
+		},
+		{
+			name:  "initials before inner characters",
+			query: "gs",
+			want:  []string{"Getting started", "Logs"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var scores []int
+			for _, name := range tc.want {
+				score, ok := fuzzyMatch(tc.query, name)
+				if !ok {
+					t.Fatalf("fuzzyMatch(%q, %q) got no match", tc.query, name)
+				}
+				scores = append(scores, score)
+			}
+			for i := 1; i < len(scores); i++ {
+				if scores[i-1] <= scores[i] {
+					t.Errorf("fuzzyMatch(%q) scored %q (%d) no higher than %q (%d)", tc.query, tc.want[i-1], scores[i-1], tc.want[i], scores[i])
+				}
+			}
+		})
+	}
+}
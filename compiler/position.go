@@ -0,0 +1,66 @@
+package compiler
+
+import (
+	"unicode/utf8"
+
+	"github.com/sebastian-nunez/golang-language-server-protocol/lsp"
+)
+
+// NegotiatePositionEncoding picks the position encoding to use for a session from the
+// encodings offered by the client. The client's order of preference is honoured and UTF-16
+// is used when the client does not offer any, as required by the LSP specification.
+func NegotiatePositionEncoding(offered []lsp.PositionEncodingKind) lsp.PositionEncodingKind {
+	for _, encoding := range offered {
+		switch encoding {
+		case lsp.PositionEncodingUTF8, lsp.PositionEncodingUTF16, lsp.PositionEncodingUTF32:
+			return encoding
+		}
+	}
+	return lsp.PositionEncodingUTF16
+}
+
+// columnToByte converts a column counted in the given encoding into a byte index within the
+// line. A column in the middle of a rune resolves to the start of that rune.
+func columnToByte(line string, column int, encoding lsp.PositionEncodingKind) int {
+	if encoding == lsp.PositionEncodingUTF8 {
+		column = max(0, min(column, len(line)))
+		for column > 0 && column < len(line) && !utf8.RuneStart(line[column]) {
+			column--
+		}
+		return column
+	}
+
+	units := 0
+	for i, r := range line {
+		if units+runeLen(r, encoding) > column {
+			return i
+		}
+		units += runeLen(r, encoding)
+	}
+	return len(line)
+}
+
+// byteToColumn converts a byte index within the line into a column counted in the given encoding.
+func byteToColumn(line string, index int, encoding lsp.PositionEncodingKind) int {
+	if encoding == lsp.PositionEncodingUTF8 {
+		return max(0, min(index, len(line)))
+	}
+
+	units := 0
+	for i, r := range line {
+		if i >= index {
+			break
+		}
+		units += runeLen(r, encoding)
+	}
+	return units
+}
+
+// runeLen returns the number of code units needed to encode the rune in UTF-16 or UTF-32.
+// Unknown encodings are treated as UTF-16.
+func runeLen(r rune, encoding lsp.PositionEncodingKind) int {
+	if encoding == lsp.PositionEncodingUTF32 || r < 0x10000 {
+		return 1
+	}
+	return 2 // Encoded as a UTF-16 surrogate pair.
+}
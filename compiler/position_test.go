@@ -0,0 +1,99 @@
+package compiler
+
+import (
+	"testing"
+
+	"github.com/sebastian-nunez/golang-language-server-protocol/lsp"
+)
+
+func TestNegotiatePositionEncoding(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		offered []lsp.PositionEncodingKind
+		want    lsp.PositionEncodingKind
+	}{
+		{
+			name:    "nothing offered",
+			offered: nil,
+			want:    lsp.PositionEncodingUTF16,
+		},
+		{
+			name:    "client preference is honoured",
+			offered: []lsp.PositionEncodingKind{lsp.PositionEncodingUTF32, lsp.PositionEncodingUTF8},
+			want:    lsp.PositionEncodingUTF32,
+		},
+		{
+			name:    "unknown encodings are skipped",
+			offered: []lsp.PositionEncodingKind{"utf-7", lsp.PositionEncodingUTF8},
+			want:    lsp.PositionEncodingUTF8,
+		},
+		{
+			name:    "only unknown encodings",
+			offered: []lsp.PositionEncodingKind{"utf-7"},
+			want:    lsp.PositionEncodingUTF16,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := NegotiatePositionEncoding(tc.offered); got != tc.want {
+				t.Errorf("NegotiatePositionEncoding got = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestColumnConversion(t *testing.T) {
+	t.Parallel()
+
+	// "é" is 2 bytes and 1 UTF-16 code unit, "😀" is 4 bytes and 2 UTF-16 code units.
+	line := "é😀VS Code"
+	byteIndex := len("é😀")
+
+	testCases := []struct {
+		name     string
+		encoding lsp.PositionEncodingKind
+		column   int
+	}{
+		{
+			name:     "utf-8",
+			encoding: lsp.PositionEncodingUTF8,
+			column:   6,
+		},
+		{
+			name:     "utf-16",
+			encoding: lsp.PositionEncodingUTF16,
+			column:   3,
+		},
+		{
+			name:     "utf-32",
+			encoding: lsp.PositionEncodingUTF32,
+			column:   2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := byteToColumn(line, byteIndex, tc.encoding); got != tc.column {
+				t.Errorf("byteToColumn got = %v, want %v", got, tc.column)
+			}
+			if got := columnToByte(line, tc.column, tc.encoding); got != byteIndex {
+				t.Errorf("columnToByte got = %v, want %v", got, byteIndex)
+			}
+		})
+	}
+}
+
+func TestColumnToByteInsideRune(t *testing.T) {
+	t.Parallel()
+
+	line := "a😀b"
+	if got := columnToByte(line, 2, lsp.PositionEncodingUTF8); got != 1 {
+		t.Errorf("columnToByte utf-8 got = %v, want %v", got, 1)
+	}
+	if got := columnToByte(line, 2, lsp.PositionEncodingUTF16); got != 1 {
+		t.Errorf("columnToByte utf-16 got = %v, want %v", got, 1)
+	}
+}
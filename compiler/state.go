@@ -1,48 +1,129 @@
 package compiler
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"strings"
+	"sort"
+	"sync"
 
 	"github.com/sebastian-nunez/golang-language-server-protocol/lsp"
+	"github.com/sebastian-nunez/golang-language-server-protocol/markdown"
 )
 
 var (
 	ErrDocumentNotFound      = errors.New("document was not opened")
 	ErrDocumentAlreadyOpened = errors.New("document was already opened")
+	ErrStaleVersion          = errors.New("document version is not newer than the current version")
 )
 
+// State holds the documents opened by the client. It is safe for concurrent use: document
+// mutations are serialized and never run concurrently with reads.
 type State struct {
-	// documents is a map of document URIs (file names) to their text contents.
-	documents map[lsp.DocumentURI]string
+	mu sync.RWMutex
+	// documents is a map of document URIs (file names) to their contents.
+	documents map[lsp.DocumentURI]*Document
+	// trees holds the syntax tree of every document, parsed from its current contents.
+	trees map[lsp.DocumentURI]*markdown.Node
+	// encoding is the position encoding negotiated with the client.
+	encoding lsp.PositionEncodingKind
+	// features are the features of the client that the responses are tailored to.
+	features clientFeatures
+	// workspace indexes the markdown files of the workspace folders, open or not.
+	workspace *workspaceIndex
 }
 
 func NewState() *State {
 	return &State{
-		documents: make(map[lsp.DocumentURI]string),
+		documents: make(map[lsp.DocumentURI]*Document),
+		trees:     make(map[lsp.DocumentURI]*markdown.Node),
+		encoding:  lsp.PositionEncodingUTF16,
+		features:  newClientFeatures(lsp.ClientCapabilities{}),
+		workspace: newWorkspaceIndex(),
 	}
 }
 
-func (s *State) OpenDocument(uri lsp.DocumentURI, text string) ([]lsp.Diagnostic, error) {
+// SetClientCapabilities tailors the responses to the capabilities declared by the client, e.g.
+// markdown hovers or commands instead of code actions. Until it is called, the client is assumed
+// to support nothing beyond the base protocol.
+func (s *State) SetClientCapabilities(capabilities lsp.ClientCapabilities) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.features = newClientFeatures(capabilities)
+}
+
+// SetPositionEncoding sets the encoding used to interpret and emit positions for every document.
+func (s *State) SetPositionEncoding(encoding lsp.PositionEncodingKind) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.encoding = encoding
+	for _, doc := range s.documents {
+		doc.encoding = encoding
+	}
+}
+
+func (s *State) OpenDocument(uri lsp.DocumentURI, version int, text string) ([]lsp.Diagnostic, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	_, ok := s.documents[uri]
 	if ok {
 		return nil, ErrDocumentAlreadyOpened
 	}
-	s.documents[uri] = text
-	return getDiagnosticsForFile(text), nil
+	doc := NewDocument(text, s.encoding)
+	doc.version = version
+	s.documents[uri] = doc
+	s.trees[uri] = markdown.Parse(text)
+	return getDiagnosticsForFile(uri, doc, s.trees[uri], s.features), nil
 }
 
-func (s *State) UpdateDocument(uri lsp.DocumentURI, text string) ([]lsp.Diagnostic, error) {
-	_, ok := s.documents[uri]
+// UpdateDocument applies the content changes, in order, to the stored document. Ranged changes
+// are applied incrementally while changes without a range replace the whole document.
+//
+// Changes must be for a newer version than the one stored, otherwise they are rejected with
+// `ErrStaleVersion` and the document is left untouched.
+func (s *State) UpdateDocument(uri lsp.DocumentURI, version int, changes []lsp.TextDocumentContentChangeEvent) ([]lsp.Diagnostic, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	doc, ok := s.documents[uri]
 	if !ok {
 		return nil, ErrDocumentNotFound
 	}
-	s.documents[uri] = text
-	return getDiagnosticsForFile(text), nil
+	if version <= doc.version {
+		return nil, fmt.Errorf("%w: got version %d, current version %d", ErrStaleVersion, version, doc.version)
+	}
+
+	// Only the blocks of the syntax tree that were affected by the changes are parsed again.
+	edit := newTextChange(doc)
+	for _, change := range changes {
+		edit.add(doc, change)
+		doc.ApplyChange(change)
+	}
+	doc.version = version
+	s.trees[uri] = edit.reparse(s.trees[uri], doc.Text())
+	return getDiagnosticsForFile(uri, doc, s.trees[uri], s.features), nil
 }
 
-func (s *State) Hover(uri lsp.DocumentURI, id int, position lsp.Position) (*lsp.TextDocumentHoverResponse, error) {
+// DocumentVersion returns the current version of the document. Handlers can compare it against
+// the version their results were computed for to drop results for a superseded version.
+func (s *State) DocumentVersion(uri lsp.DocumentURI) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	doc, ok := s.documents[uri]
+	if !ok {
+		return 0, ErrDocumentNotFound
+	}
+	return doc.version, nil
+}
+
+func (s *State) Hover(ctx context.Context, uri lsp.DocumentURI, id lsp.ID, position lsp.Position) (*lsp.TextDocumentHoverResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	doc, ok := s.documents[uri]
 	if !ok {
 		return nil, ErrDocumentNotFound
@@ -50,63 +131,85 @@ func (s *State) Hover(uri lsp.DocumentURI, id int, position lsp.Position) (*lsp.
 
 	// This is mocked behavior. In a real implementation, you would want to
 	// return the actual hover information for the given position in the document.
-	contents := lsp.MarkedString(fmt.Sprintf("file=%s, characters=%d", uri, len(doc)))
+	contents := lsp.MarkupContent{
+		Kind:  lsp.MarkupKindPlainText,
+		Value: fmt.Sprintf("file=%s, characters=%d", uri, doc.Len()),
+	}
+	if s.features.hoverFormat == lsp.MarkupKindMarkdown {
+		contents = lsp.MarkupContent{
+			Kind:  lsp.MarkupKindMarkdown,
+			Value: fmt.Sprintf("**file**: `%s`, **characters**: %d", uri, doc.Len()),
+		}
+	}
 	return lsp.NewTextDocumentHoverResponse(id, contents), nil
 }
 
-func (s *State) Definition(uri lsp.DocumentURI, id int, position lsp.Position) (*lsp.TextDocumentDefinitionResponse, error) {
-	_, ok := s.documents[uri]
+// Definition returns the location that the link, reference or footnote under the cursor refers to:
+// the file and heading a link points at, the definition of a reference-style link or the body of a
+// footnote. The result is null if there is nothing to go to, e.g. for external links.
+func (s *State) Definition(ctx context.Context, uri lsp.DocumentURI, id lsp.ID, position lsp.Position) (*lsp.TextDocumentDefinitionResponse, error) {
+	// The workspace folders are copied first, so that the lock of the index is never waited for
+	// while holding the state lock.
+	roots := s.workspace.folders()
+
+	s.mu.RLock()
+	doc, ok := s.documents[uri]
 	if !ok {
+		s.mu.RUnlock()
 		return nil, ErrDocumentNotFound
 	}
+	tree := s.trees[uri]
+	location, file := s.definition(roots, uri, doc, tree, linkAt(tree, doc.OffsetAt(position)))
+	encoding := s.encoding
+	s.mu.RUnlock()
 
-	// This is mocked behavior: the "definition" is the line above the word.
-	// In a real implementation, you would want to return the actual hover information
-	// for the given position in the document.
-	contents := lsp.MarkedString("definition")
-	r := lsp.Range{
-		Start: lsp.Position{Line: position.Line - 1, Character: 0},
-		End:   lsp.Position{Line: position.Line - 1, Character: 0},
+	// Files are read from disk without holding the lock, so that documents can still be edited.
+	if file != nil {
+		location = resolveFile(*file, encoding)
 	}
-	return lsp.NewTextDocumentDefinitionResponse(id, uri, r, contents), nil
+	return lsp.NewTextDocumentDefinitionResponse(id, location), nil
 }
 
-func (s *State) TextDocumentCodeAction(id int, uri lsp.DocumentURI) (lsp.TextDocumentCodeActionResponse, error) {
-	text, ok := s.documents[uri]
+func (s *State) TextDocumentCodeAction(ctx context.Context, id lsp.ID, uri lsp.DocumentURI) (lsp.TextDocumentCodeActionResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	doc, ok := s.documents[uri]
 	if !ok {
 		return lsp.TextDocumentCodeActionResponse{}, ErrDocumentNotFound
 	}
 
 	actions := []lsp.CodeAction{}
-	for row, line := range strings.Split(text, "\n") {
-		idx := strings.Index(line, "VS Code")
-		if idx >= 0 {
-			replaceChange := map[string][]lsp.TextEdit{}
-			replaceChange[string(uri)] = []lsp.TextEdit{
-				{
-					Range:   LineRange(row, idx, idx+len("VS Code")),
-					NewText: "Neovim",
-				},
-			}
-
-			actions = append(actions, lsp.CodeAction{
-				Title: "Replace VS C*de with a superior editor",
-				Edit:  &lsp.WorkspaceEdit{Changes: replaceChange},
-			})
+	for _, r := range textMatches(doc, s.trees[uri], "VS Code") {
+		if err := ctx.Err(); err != nil {
+			return lsp.TextDocumentCodeActionResponse{}, err
+		}
 
-			censorChange := map[string][]lsp.TextEdit{}
-			censorChange[string(uri)] = []lsp.TextEdit{
-				{
-					Range:   LineRange(row, idx, idx+len("VS Code")),
-					NewText: "VS C*de",
-				},
-			}
-
-			actions = append(actions, lsp.CodeAction{
-				Title: "Censor to VS C*de",
-				Edit:  &lsp.WorkspaceEdit{Changes: censorChange},
-			})
+		replaceChange := map[string][]lsp.TextEdit{}
+		replaceChange[string(uri)] = []lsp.TextEdit{
+			{
+				Range:   r,
+				NewText: "Neovim",
+			},
 		}
+
+		actions = append(actions, lsp.CodeAction{
+			Title: "Replace VS C*de with a superior editor",
+			Edit:  &lsp.WorkspaceEdit{Changes: replaceChange},
+		})
+
+		censorChange := map[string][]lsp.TextEdit{}
+		censorChange[string(uri)] = []lsp.TextEdit{
+			{
+				Range:   r,
+				NewText: "VS C*de",
+			},
+		}
+
+		actions = append(actions, lsp.CodeAction{
+			Title: "Censor to VS C*de",
+			Edit:  &lsp.WorkspaceEdit{Changes: censorChange},
+		})
 	}
 
 	response := lsp.TextDocumentCodeActionResponse{
@@ -116,6 +219,19 @@ func (s *State) TextDocumentCodeAction(id int, uri lsp.DocumentURI) (lsp.TextDoc
 		},
 		Result: actions,
 	}
+	if !s.features.codeActionLiterals {
+		// Older clients only understand commands, so each edit is sent back to the server to be
+		// applied through `workspace/applyEdit`.
+		commands := make([]lsp.Command, 0, len(actions))
+		for _, action := range actions {
+			commands = append(commands, lsp.Command{
+				Title:     action.Title,
+				Command:   ApplyEditCommand,
+				Arguments: []interface{}{action.Edit},
+			})
+		}
+		response.Result = commands
+	}
 
 	return response, nil
 }
@@ -133,14 +249,117 @@ func LineRange(line, start, end int) lsp.Range {
 	}
 }
 
-func (s *State) TextDocumentCompletion(id int, uri lsp.DocumentURI) *lsp.TextDocumentCompletionResponse {
+// DocumentSymbol returns the outline of the document: a symbol for every heading, whose range
+// covers its whole section. Subsections are nested in their section, unless the client only
+// supports flat symbols.
+func (s *State) DocumentSymbol(ctx context.Context, uri lsp.DocumentURI, id lsp.ID) (*lsp.TextDocumentDocumentSymbolResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	doc, ok := s.documents[uri]
+	if !ok {
+		return nil, ErrDocumentNotFound
+	}
+
+	sections := sections(doc.Text(), s.trees[uri])
+	var result any = documentSymbols(doc, sections)
+	if !s.features.hierarchicalSymbols {
+		result = symbolInformation(uri, doc, sections, "")
+	}
+	if len(sections) == 0 {
+		// The outline of a document without headings is empty, not null.
+		result = []lsp.DocumentSymbol{}
+	}
+
+	return &lsp.TextDocumentDocumentSymbolResponse{
+		Response: lsp.Response{
+			RPC: "2.0",
+			ID:  id,
+		},
+		Result: result,
+	}, nil
+}
+
+// SetWorkspaceFolders sets the folders searched for markdown files by `WorkspaceSymbol`, which are
+// also the roots that links starting with `/` are relative to. Folders that are not `file` URIs are
+// ignored.
+func (s *State) SetWorkspaceFolders(folders []lsp.DocumentURI) {
+	var roots []string
+	for _, folder := range folders {
+		if path, err := folder.Path(); err == nil {
+			roots = append(roots, path)
+		}
+	}
+	s.workspace.setRoots(roots)
+}
+
+// WorkspaceSymbol searches the headings, link reference definitions and front matter titles of
+// the markdown files under the workspace folders. Open documents are searched as they are in the
+// editor, rather than as they are on disk. Symbols are matched fuzzily against the query and the
+// best matches come first.
+func (s *State) WorkspaceSymbol(ctx context.Context, id lsp.ID, query string) (*lsp.WorkspaceSymbolResponse, error) {
+	s.mu.RLock()
+	var symbols []lsp.SymbolInformation
+	open := make(map[string]bool, len(s.documents))
+	for uri, doc := range s.documents {
+		if path, err := uri.Path(); err == nil {
+			open[path] = true
+		}
+		symbols = append(symbols, fileSymbols(uri, doc, s.trees[uri])...)
+	}
+	encoding := s.encoding
+	s.mu.RUnlock()
+
+	// Files are read from disk without holding the lock, so that documents can still be edited.
+	indexed, err := s.workspace.symbols(ctx, encoding, open)
+	if err != nil {
+		return nil, err
+	}
+	symbols = append(symbols, indexed...)
+
+	return &lsp.WorkspaceSymbolResponse{
+		Response: lsp.Response{
+			RPC: "2.0",
+			ID:  id,
+		},
+		Result: rankSymbols(query, symbols),
+	}, nil
+}
+
+func (s *State) TextDocumentCompletion(ctx context.Context, id lsp.ID, uri lsp.DocumentURI) *lsp.TextDocumentCompletionResponse {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	documentation := lsp.MarkupContent{
+		Kind:  lsp.MarkupKindPlainText,
+		Value: "This is a documentation tooltip. In a real app, this would be useful information.",
+	}
+	if s.features.documentationFormat == lsp.MarkupKindMarkdown {
+		documentation = lsp.MarkupContent{
+			Kind:  lsp.MarkupKindMarkdown,
+			Value: "This is a *documentation* tooltip. In a real app, this would be **useful** information.",
+		}
+	}
+
+	link := lsp.CompletionItem{
+		Label:            "link",
+		Detail:           "Markdown link",
+		InsertText:       "[text](url)",
+		InsertTextFormat: lsp.InsertTextFormatPlainText,
+	}
+	if s.features.snippets {
+		link.InsertText = "[${1:text}](${2:url})"
+		link.InsertTextFormat = lsp.InsertTextFormatSnippet
+	}
+
 	// In a real app, we would run static analysis.
 	items := []lsp.CompletionItem{
 		{
 			Label:         "Custom completion",
 			Detail:        "Some super great details.",
-			Documentation: "This is a documentation tooltip. In a real app, this would be useful information.",
+			Documentation: &documentation,
 		},
+		link,
 	}
 
 	response := &lsp.TextDocumentCompletionResponse{
@@ -157,30 +376,40 @@ func stringToPtr(s string) *string {
 	return &s
 }
 
-func getDiagnosticsForFile(text string) []lsp.Diagnostic {
-	diagnostics := []lsp.Diagnostic{}
-	for row, line := range strings.Split(text, "\n") {
-		if strings.Contains(line, "VS Code") {
-			idx := strings.Index(line, "VS Code")
-			diagnostics = append(diagnostics, lsp.Diagnostic{
-				Range:    LineRange(row, idx, idx+len("VS Code")),
-				Severity: lsp.DiagnosticSeverityError,
-				Source:   stringToPtr("Common knowledge"),
-				Message:  "Please make sure we use good language!!",
-			})
-		}
-
-		if strings.Contains(line, "Neovim") {
-			idx := strings.Index(line, "Neovim")
-			diagnostics = append(diagnostics, lsp.Diagnostic{
-				Range:    LineRange(row, idx, idx+len("Neovim")),
-				Severity: lsp.DiagnosticSeverityHint,
-				Source:   stringToPtr("Common Sense"),
-				Message:  "Great choice ;)",
-			})
+func getDiagnosticsForFile(uri lsp.DocumentURI, doc *Document, tree *markdown.Node, features clientFeatures) []lsp.Diagnostic {
+	vsCode := textMatches(doc, tree, "VS Code")
+	neovim := textMatches(doc, tree, "Neovim")
 
-		}
+	// Clients that support it are pointed to the first mention of a superior editor.
+	var related []lsp.DiagnosticRelatedInformation
+	if features.relatedInformation && len(neovim) > 0 {
+		related = append(related, lsp.DiagnosticRelatedInformation{
+			Location: lsp.Location{URI: uri, Range: &neovim[0]},
+			Message:  "A superior editor is mentioned here",
+		})
 	}
 
+	diagnostics := []lsp.Diagnostic{}
+	for _, r := range vsCode {
+		diagnostics = append(diagnostics, lsp.Diagnostic{
+			Range:              r,
+			Severity:           lsp.DiagnosticSeverityError,
+			Source:             stringToPtr("Common knowledge"),
+			Message:            "Please make sure we use good language!!",
+			RelatedInformation: related,
+		})
+	}
+	for _, r := range neovim {
+		diagnostics = append(diagnostics, lsp.Diagnostic{
+			Range:    r,
+			Severity: lsp.DiagnosticSeverityHint,
+			Source:   stringToPtr("Common Sense"),
+			Message:  "Great choice ;)",
+		})
+	}
+	// Diagnostics are reported line by line, in the order of the document.
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		return diagnostics[i].Range.Start.Line < diagnostics[j].Range.Start.Line
+	})
 	return diagnostics
 }
@@ -1,11 +1,13 @@
 package compiler
 
 import (
+	"context"
 	"errors"
 	"reflect"
 	"testing"
 
 	"github.com/sebastian-nunez/golang-language-server-protocol/lsp"
+	"github.com/sebastian-nunez/golang-language-server-protocol/markdown"
 )
 
 func TestNewState(t *testing.T) {
@@ -74,9 +76,9 @@ func TestOpenDocument(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			state := NewState()
 			if tc.initialURI != "" {
-				state.documents[tc.initialURI] = tc.initialText
+				state.documents[tc.initialURI] = NewDocument(tc.initialText, lsp.PositionEncodingUTF16)
 			}
-			_, err := state.OpenDocument(tc.newURI, tc.newText)
+			_, err := state.OpenDocument(tc.newURI, 1, tc.newText)
 
 			if err != nil && err.Error() != tc.wantErr.Error() {
 				t.Errorf("OpenDocument got error = %v, want %v", err, tc.wantErr)
@@ -86,7 +88,7 @@ func TestOpenDocument(t *testing.T) {
 				t.Errorf("OpenDocument got length = %v, want %v", gotLength, tc.wantLength)
 			}
 
-			if gotText := state.documents[tc.newURI]; gotText != tc.wantText {
+			if gotText := state.documents[tc.newURI].Text(); gotText != tc.wantText {
 				t.Errorf("OpenDocument got text = %v, want %v", gotText, tc.wantText)
 			}
 		})
@@ -101,7 +103,8 @@ func TestUpdateDocument(t *testing.T) {
 		initialURI  lsp.DocumentURI
 		initialText string
 		updateURI   lsp.DocumentURI
-		updateText  string
+		version     int
+		changes     []lsp.TextDocumentContentChangeEvent
 		wantText    string
 		wantErr     error
 	}{
@@ -110,17 +113,85 @@ func TestUpdateDocument(t *testing.T) {
 			initialURI:  lsp.DocumentURI("file:///example.go"),
 			initialText: "package main\n\nfunc main() {}\n",
 			updateURI:   lsp.DocumentURI("file:///example.go"),
-			updateText:  "package main\n\nfunc main() { println(\"Hello, World!\") }\n",
-			wantText:    "package main\n\nfunc main() { println(\"Hello, World!\") }\n",
-			wantErr:     nil,
+			version:     1,
+			changes: []lsp.TextDocumentContentChangeEvent{
+				{Text: "package main\n\nfunc main() { println(\"Hello, World!\") }\n"},
+			},
+			wantText: "package main\n\nfunc main() { println(\"Hello, World!\") }\n",
+			wantErr:  nil,
+		},
+		{
+			name:        "incremental change",
+			initialURI:  lsp.DocumentURI("file:///example.md"),
+			initialText: "# Title\n\nHello world\n",
+			updateURI:   lsp.DocumentURI("file:///example.md"),
+			version:     1,
+			changes: []lsp.TextDocumentContentChangeEvent{
+				{Range: &lsp.Range{Start: lsp.Position{Line: 2, Character: 6}, End: lsp.Position{Line: 2, Character: 11}}, Text: "there"},
+			},
+			wantText: "# Title\n\nHello there\n",
+			wantErr:  nil,
+		},
+		{
+			name:        "incremental changes are applied in order",
+			initialURI:  lsp.DocumentURI("file:///example.md"),
+			initialText: "abc\n",
+			updateURI:   lsp.DocumentURI("file:///example.md"),
+			version:     1,
+			changes: []lsp.TextDocumentContentChangeEvent{
+				{Range: &lsp.Range{Start: lsp.Position{Line: 0, Character: 3}, End: lsp.Position{Line: 0, Character: 3}}, Text: "d\nef"},
+				{Range: &lsp.Range{Start: lsp.Position{Line: 1, Character: 0}, End: lsp.Position{Line: 1, Character: 1}}, Text: ""},
+			},
+			wantText: "abcd\nf\n",
+			wantErr:  nil,
+		},
+		{
+			name:        "incremental change after surrogate pair",
+			initialURI:  lsp.DocumentURI("file:///example.md"),
+			initialText: "a😀b\n",
+			updateURI:   lsp.DocumentURI("file:///example.md"),
+			version:     1,
+			changes: []lsp.TextDocumentContentChangeEvent{
+				{Range: &lsp.Range{Start: lsp.Position{Line: 0, Character: 3}, End: lsp.Position{Line: 0, Character: 4}}, Text: "c"},
+			},
+			wantText: "a😀c\n",
+			wantErr:  nil,
+		},
+		{
+			name:        "full replacement after incremental change",
+			initialURI:  lsp.DocumentURI("file:///example.md"),
+			initialText: "abc",
+			updateURI:   lsp.DocumentURI("file:///example.md"),
+			version:     1,
+			changes: []lsp.TextDocumentContentChangeEvent{
+				{Range: &lsp.Range{Start: lsp.Position{Line: 0, Character: 0}, End: lsp.Position{Line: 0, Character: 1}}, Text: "x"},
+				{Text: "replaced"},
+			},
+			wantText: "replaced",
+			wantErr:  nil,
 		},
 		{
 			name:       "update non-existing document",
 			initialURI: "",
 			updateURI:  lsp.DocumentURI("file:///nonexistent.go"),
-			updateText: "package main\n\nfunc main() {}\n",
-			wantText:   "",
-			wantErr:    errors.New("document was not opened"),
+			version:    1,
+			changes: []lsp.TextDocumentContentChangeEvent{
+				{Text: "package main\n\nfunc main() {}\n"},
+			},
+			wantText: "",
+			wantErr:  ErrDocumentNotFound,
+		},
+		{
+			name:        "stale version",
+			initialURI:  lsp.DocumentURI("file:///example.md"),
+			initialText: "abc",
+			updateURI:   lsp.DocumentURI("file:///example.md"),
+			version:     0,
+			changes: []lsp.TextDocumentContentChangeEvent{
+				{Text: "replaced"},
+			},
+			wantText: "abc", // Stale changes are not applied
+			wantErr:  ErrStaleVersion,
 		},
 	}
 
@@ -128,57 +199,179 @@ func TestUpdateDocument(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			state := NewState()
 			if tc.initialURI != "" {
-				state.documents[tc.initialURI] = tc.initialText
+				state.documents[tc.initialURI] = NewDocument(tc.initialText, lsp.PositionEncodingUTF16)
 			}
-			_, err := state.UpdateDocument(tc.updateURI, tc.updateText)
+			_, err := state.UpdateDocument(tc.updateURI, tc.version, tc.changes)
 
-			if err != nil && err.Error() != tc.wantErr.Error() {
+			if !errors.Is(err, tc.wantErr) {
 				t.Errorf("UpdateDocument got error = %v, want %v", err, tc.wantErr)
 			}
 
-			if gotText := state.documents[tc.updateURI]; gotText != tc.wantText {
+			var gotText string
+			if doc, ok := state.documents[tc.updateURI]; ok {
+				gotText = doc.Text()
+			}
+			if gotText != tc.wantText {
 				t.Errorf("UpdateDocument got text = %v, want %v", gotText, tc.wantText)
 			}
 		})
 	}
 }
 
+func TestUpdateDocumentSyntaxTree(t *testing.T) {
+	t.Parallel()
+
+	text := "# Title\n\nSome text\n\n- a\n- b\n\n```\ncode\n```\n"
+	testCases := []struct {
+		name    string
+		changes []lsp.TextDocumentContentChangeEvent
+	}{
+		{
+			name: "edit a paragraph",
+			changes: []lsp.TextDocumentContentChangeEvent{
+				{Range: &lsp.Range{Start: lsp.Position{Line: 2, Character: 5}, End: lsp.Position{Line: 2, Character: 9}}, Text: "*VS Code*"},
+			},
+		},
+		{
+			name: "open a fence",
+			changes: []lsp.TextDocumentContentChangeEvent{
+				{Range: &lsp.Range{Start: lsp.Position{Line: 1, Character: 0}, End: lsp.Position{Line: 1, Character: 0}}, Text: "```"},
+			},
+		},
+		{
+			name: "several changes",
+			changes: []lsp.TextDocumentContentChangeEvent{
+				{Range: &lsp.Range{Start: lsp.Position{Line: 5, Character: 2}, End: lsp.Position{Line: 5, Character: 3}}, Text: "c\n\n  more"},
+				{Range: &lsp.Range{Start: lsp.Position{Line: 0, Character: 0}, End: lsp.Position{Line: 0, Character: 2}}, Text: ""},
+			},
+		},
+		{
+			name: "changes that undo each other",
+			changes: []lsp.TextDocumentContentChangeEvent{
+				{Range: &lsp.Range{Start: lsp.Position{Line: 2, Character: 0}, End: lsp.Position{Line: 2, Character: 0}}, Text: "> "},
+				{Range: &lsp.Range{Start: lsp.Position{Line: 2, Character: 0}, End: lsp.Position{Line: 2, Character: 2}}, Text: ""},
+			},
+		},
+		{
+			name: "full replacement",
+			changes: []lsp.TextDocumentContentChangeEvent{
+				{Range: &lsp.Range{Start: lsp.Position{Line: 0, Character: 0}, End: lsp.Position{Line: 0, Character: 1}}, Text: ""},
+				{Text: "replaced\n===\n"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			state := NewState()
+			uri := lsp.DocumentURI("file:///example.md")
+			if _, err := state.OpenDocument(uri, 1, text); err != nil {
+				t.Fatalf("OpenDocument got unexpected error: %v", err)
+			}
+			if _, err := state.UpdateDocument(uri, 2, tc.changes); err != nil {
+				t.Fatalf("UpdateDocument got unexpected error: %v", err)
+			}
+
+			if want := markdown.Parse(state.documents[uri].Text()); !reflect.DeepEqual(state.trees[uri], want) {
+				t.Errorf("UpdateDocument got a syntax tree that differs from parsing %q", state.documents[uri].Text())
+			}
+		})
+	}
+}
+
+func TestDocumentVersion(t *testing.T) {
+	t.Parallel()
+
+	state := NewState()
+	uri := lsp.DocumentURI("file:///example.md")
+	if _, err := state.DocumentVersion(uri); !errors.Is(err, ErrDocumentNotFound) {
+		t.Fatalf("DocumentVersion got error = %v, want %v", err, ErrDocumentNotFound)
+	}
+
+	if _, err := state.OpenDocument(uri, 3, "hello"); err != nil {
+		t.Fatalf("OpenDocument got unexpected error: %v", err)
+	}
+	if got, _ := state.DocumentVersion(uri); got != 3 {
+		t.Errorf("DocumentVersion got = %v, want %v", got, 3)
+	}
+
+	if _, err := state.UpdateDocument(uri, 2, []lsp.TextDocumentContentChangeEvent{{Text: "stale"}}); !errors.Is(err, ErrStaleVersion) {
+		t.Errorf("UpdateDocument got error = %v, want %v", err, ErrStaleVersion)
+	}
+	if got, _ := state.DocumentVersion(uri); got != 3 {
+		t.Errorf("DocumentVersion after stale change got = %v, want %v", got, 3)
+	}
+
+	if _, err := state.UpdateDocument(uri, 4, []lsp.TextDocumentContentChangeEvent{{Text: "fresh"}}); err != nil {
+		t.Fatalf("UpdateDocument got unexpected error: %v", err)
+	}
+	if got, _ := state.DocumentVersion(uri); got != 4 {
+		t.Errorf("DocumentVersion after change got = %v, want %v", got, 4)
+	}
+}
+
 func TestHover(t *testing.T) {
 	t.Parallel()
 
 	testCases := []struct {
-		name        string
-		documents   map[lsp.DocumentURI]string
-		uri         lsp.DocumentURI
-		id          int
-		position    lsp.Position
-		wantContent lsp.MarkedString
-		wantErr     error
+		name         string
+		documents    map[lsp.DocumentURI]string
+		capabilities lsp.ClientCapabilities
+		uri          lsp.DocumentURI
+		id           lsp.ID
+		position     lsp.Position
+		wantContent  lsp.MarkupContent
+		wantErr      error
 	}{
 		{
 			name:        "existing document",
 			documents:   map[lsp.DocumentURI]string{"file:///example.go": "package main\n\nfunc main() {}\n"},
 			uri:         lsp.DocumentURI("file:///example.go"),
-			id:          1,
+			id:          lsp.NewIntID(1),
 			position:    lsp.Position{Line: 1, Character: 5},
-			wantContent: lsp.MarkedString("file=file:///example.go, characters=29"),
+			wantContent: lsp.MarkupContent{Kind: lsp.MarkupKindPlainText, Value: "file=file:///example.go, characters=29"},
+			wantErr:     nil,
+		},
+		{
+			name:      "client prefers markdown",
+			documents: map[lsp.DocumentURI]string{"file:///example.go": "package main\n\nfunc main() {}\n"},
+			capabilities: lsp.ClientCapabilities{TextDocument: &lsp.TextDocumentClientCapabilities{
+				Hover: &lsp.HoverClientCapabilities{ContentFormat: []lsp.MarkupKind{lsp.MarkupKindMarkdown, lsp.MarkupKindPlainText}},
+			}},
+			uri:         lsp.DocumentURI("file:///example.go"),
+			id:          lsp.NewIntID(1),
+			position:    lsp.Position{Line: 1, Character: 5},
+			wantContent: lsp.MarkupContent{Kind: lsp.MarkupKindMarkdown, Value: "**file**: `file:///example.go`, **characters**: 29"},
+			wantErr:     nil,
+		},
+		{
+			name:      "client prefers plain text",
+			documents: map[lsp.DocumentURI]string{"file:///example.go": "package main\n\nfunc main() {}\n"},
+			capabilities: lsp.ClientCapabilities{TextDocument: &lsp.TextDocumentClientCapabilities{
+				Hover: &lsp.HoverClientCapabilities{ContentFormat: []lsp.MarkupKind{lsp.MarkupKindPlainText, lsp.MarkupKindMarkdown}},
+			}},
+			uri:         lsp.DocumentURI("file:///example.go"),
+			id:          lsp.NewIntID(1),
+			position:    lsp.Position{Line: 1, Character: 5},
+			wantContent: lsp.MarkupContent{Kind: lsp.MarkupKindPlainText, Value: "file=file:///example.go, characters=29"},
 			wantErr:     nil,
 		},
 		{
 			name:        "non-existing document",
 			documents:   map[lsp.DocumentURI]string{},
 			uri:         lsp.DocumentURI("file:///nonexistent.go"),
-			id:          2,
+			id:          lsp.NewIntID(2),
 			position:    lsp.Position{Line: 1, Character: 5},
-			wantContent: "",
+			wantContent: lsp.MarkupContent{},
 			wantErr:     ErrDocumentNotFound,
 		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			state := &State{documents: tc.documents}
-			got, err := state.Hover(tc.uri, tc.id, tc.position)
+			state := newTestState(tc.documents)
+			state.SetClientCapabilities(tc.capabilities)
+			got, err := state.Hover(context.Background(), tc.uri, tc.id, tc.position)
 
 			if err != nil && err.Error() != tc.wantErr.Error() {
 				t.Errorf("Hover got error = %v, want = %v", err, tc.wantErr)
@@ -194,54 +387,241 @@ func TestHover(t *testing.T) {
 func TestDefinition(t *testing.T) {
 	t.Parallel()
 
+	uri := lsp.DocumentURI("file:///editors.md")
+	text := "# Editors\n\n## Editors\n\n" +
+		"See [Neovim][nvim], [the top](#editors), [the second](#EDITORS-1) and [missing](#none).\n\n" +
+		"Vim[^vim] and [site](https://neovim.io).\n\n" +
+		"[nvim]: #editors-1\n\n" +
+		"[^vim]: Vi *improved*.\n"
+	location := func(r lsp.Range) *lsp.Location {
+		return &lsp.Location{URI: uri, Range: &r}
+	}
+
+	testCases := []struct {
+		name     string
+		uri      lsp.DocumentURI
+		position lsp.Position
+		want     *lsp.Location
+		wantErr  error
+	}{
+		{
+			name:     "reference link",
+			uri:      uri,
+			position: lsp.Position{Line: 4, Character: 6},
+			want:     location(lsp.Range{Start: lsp.Position{Line: 8, Character: 0}, End: lsp.Position{Line: 8, Character: 18}}),
+		},
+		{
+			name:     "anchor",
+			uri:      uri,
+			position: lsp.Position{Line: 4, Character: 21},
+			want:     location(LineRange(0, 0, 9)),
+		},
+		{
+			name:     "anchor of a duplicate heading",
+			uri:      uri,
+			position: lsp.Position{Line: 4, Character: 42},
+			want:     location(LineRange(2, 0, 10)),
+		},
+		{
+			name:     "missing anchor",
+			uri:      uri,
+			position: lsp.Position{Line: 4, Character: 71},
+		},
+		{
+			name:     "footnote",
+			uri:      uri,
+			position: lsp.Position{Line: 6, Character: 5},
+			want:     location(LineRange(10, 0, 22)),
+		},
+		{
+			name:     "external link",
+			uri:      uri,
+			position: lsp.Position{Line: 6, Character: 15},
+		},
+		{
+			name:     "reference definition",
+			uri:      uri,
+			position: lsp.Position{Line: 8, Character: 2},
+			want:     location(LineRange(2, 0, 10)),
+		},
+		{
+			name:     "no link",
+			uri:      uri,
+			position: lsp.Position{Line: 0, Character: 2},
+		},
+		{
+			name:     "non-existing document",
+			uri:      lsp.DocumentURI("file:///nonexistent.md"),
+			position: lsp.Position{Line: 2, Character: 10},
+			wantErr:  ErrDocumentNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			state := newTestState(map[lsp.DocumentURI]string{uri: text})
+			got, err := state.Definition(context.Background(), tc.uri, lsp.NewIntID(1), tc.position)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("Definition got error = %v, want %v", err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if !reflect.DeepEqual(got.Result, tc.want) {
+				t.Errorf("Definition got %+v, want %+v", got.Result, tc.want)
+			}
+		})
+	}
+}
+
+func TestDiagnosticsPositionEncoding(t *testing.T) {
+	t.Parallel()
+
 	testCases := []struct {
 		name      string
-		documents map[lsp.DocumentURI]string
-		uri       lsp.DocumentURI
-		id        int
-		position  lsp.Position
+		encoding  lsp.PositionEncodingKind
 		wantRange lsp.Range
-		wantURI   lsp.DocumentURI
-		wantErr   error
 	}{
 		{
-			name:      "existing document",
-			documents: map[lsp.DocumentURI]string{"file:///example.go": "package main\n\nfunc main() {}\n"},
-			uri:       lsp.DocumentURI("file:///example.go"),
-			id:        1,
-			position:  lsp.Position{Line: 2, Character: 10},
-			wantRange: lsp.Range{Start: lsp.Position{Line: 1, Character: 0}, End: lsp.Position{Line: 1, Character: 0}},
-			wantURI:   lsp.DocumentURI("file:///example.go"),
-			wantErr:   nil,
+			name:      "utf-8",
+			encoding:  lsp.PositionEncodingUTF8,
+			wantRange: LineRange(0, 10, 17),
 		},
 		{
-			name:      "non-existing document",
-			documents: map[lsp.DocumentURI]string{},
-			uri:       lsp.DocumentURI("file:///nonexistent.go"),
-			id:        1,
-			position:  lsp.Position{Line: 2, Character: 10},
-			wantRange: lsp.Range{},
-			wantURI:   lsp.DocumentURI("file:///nonexistent.go"),
-			wantErr:   ErrDocumentNotFound,
+			name:      "utf-16",
+			encoding:  lsp.PositionEncodingUTF16,
+			wantRange: LineRange(0, 7, 14),
+		},
+		{
+			name:      "utf-32",
+			encoding:  lsp.PositionEncodingUTF32,
+			wantRange: LineRange(0, 6, 13),
 		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			state := &State{documents: tc.documents}
-			got, err := state.Definition(tc.uri, tc.id, tc.position)
+			state := NewState()
+			state.SetPositionEncoding(tc.encoding)
+			diagnostics, err := state.OpenDocument("file:///example.md", 1, "I 😀 é VS Code")
+			if err != nil {
+				t.Fatalf("OpenDocument got unexpected error: %v", err)
+			}
 
-			if err != nil && err.Error() != tc.wantErr.Error() {
-				t.Errorf("Definition got error = %v, want %v", err, tc.wantErr)
+			if len(diagnostics) != 1 {
+				t.Fatalf("OpenDocument got %d diagnostics, want 1", len(diagnostics))
+			}
+			if diagnostics[0].Range != tc.wantRange {
+				t.Errorf("OpenDocument got range = %+v, want %+v", diagnostics[0].Range, tc.wantRange)
+			}
+		})
+	}
+}
+
+func TestDiagnosticsSyntax(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name       string
+		text       string
+		wantRanges []lsp.Range
+	}{
+		{
+			name:       "heading",
+			text:       "# Why VS Code",
+			wantRanges: []lsp.Range{LineRange(0, 6, 13)},
+		},
+		{
+			name:       "every occurrence on a line",
+			text:       "VS Code or *VS Code*",
+			wantRanges: []lsp.Range{LineRange(0, 0, 7), LineRange(0, 12, 19)},
+		},
+		{
+			name:       "link text but not its destination",
+			text:       "[VS Code](<https://example.com/VS Code>)",
+			wantRanges: []lsp.Range{LineRange(0, 1, 8)},
+		},
+		{
+			name:       "code span",
+			text:       "Run `VS Code` to start",
+			wantRanges: nil,
+		},
+		{
+			name:       "fenced code block",
+			text:       "```\nVS Code\n```",
+			wantRanges: nil,
+		},
+		{
+			name:       "front matter",
+			text:       "---\neditor: VS Code\n---\nVS Code",
+			wantRanges: []lsp.Range{LineRange(3, 0, 7)},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			state := NewState()
+			diagnostics, err := state.OpenDocument("file:///example.md", 1, tc.text)
+			if err != nil {
+				t.Fatalf("OpenDocument got unexpected error: %v", err)
 			}
 
-			if got != nil {
-				if *got.Result.Range != tc.wantRange {
-					t.Errorf("Definition got range = %v, want %v", *got.Result.Range, tc.wantRange)
-				}
-				if got.Result.URI != tc.wantURI {
-					t.Errorf("Definition got uri = %v, want %v", got.Result.URI, tc.wantURI)
-				}
+			var ranges []lsp.Range
+			for _, diagnostic := range diagnostics {
+				ranges = append(ranges, diagnostic.Range)
+			}
+			if !reflect.DeepEqual(ranges, tc.wantRanges) {
+				t.Errorf("OpenDocument got ranges = %+v, want %+v", ranges, tc.wantRanges)
+			}
+		})
+	}
+}
+
+func TestDiagnosticsRelatedInformation(t *testing.T) {
+	t.Parallel()
+
+	neovim := LineRange(1, 8, 14)
+	testCases := []struct {
+		name         string
+		capabilities lsp.ClientCapabilities
+		want         []lsp.DiagnosticRelatedInformation
+	}{
+		{
+			name: "client supports related information",
+			capabilities: lsp.ClientCapabilities{TextDocument: &lsp.TextDocumentClientCapabilities{
+				PublishDiagnostics: &lsp.PublishDiagnosticsClientCapabilities{RelatedInformation: true},
+			}},
+			want: []lsp.DiagnosticRelatedInformation{
+				{
+					Location: lsp.Location{URI: "file:///example.md", Range: &neovim},
+					Message:  "A superior editor is mentioned here",
+				},
+			},
+		},
+		{
+			name:         "client does not support related information",
+			capabilities: lsp.ClientCapabilities{},
+			want:         nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			state := NewState()
+			state.SetClientCapabilities(tc.capabilities)
+			diagnostics, err := state.OpenDocument("file:///example.md", 1, "I use VS Code\nbut not Neovim")
+			if err != nil {
+				t.Fatalf("OpenDocument got unexpected error: %v", err)
+			}
+
+			if len(diagnostics) != 2 {
+				t.Fatalf("OpenDocument got %d diagnostics, want 2", len(diagnostics))
+			}
+			if got := diagnostics[0].RelatedInformation; !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("OpenDocument got related information = %+v, want %+v", got, tc.want)
 			}
 		})
 	}
@@ -251,24 +631,26 @@ func TestTextDocumentCodeAction(t *testing.T) {
 	t.Parallel()
 
 	testCases := []struct {
-		name      string
-		documents map[lsp.DocumentURI]string
-		id        int
-		uri       lsp.DocumentURI
-		want      lsp.TextDocumentCodeActionResponse
-		wantError error
+		name         string
+		documents    map[lsp.DocumentURI]string
+		capabilities lsp.ClientCapabilities
+		id           lsp.ID
+		uri          lsp.DocumentURI
+		want         lsp.TextDocumentCodeActionResponse
+		wantError    error
 	}{
 		{
 			name: "Document contains 'VS Code'",
 			documents: map[lsp.DocumentURI]string{
 				"file:///example": "This is a line with VS Code",
 			},
-			id:  1,
-			uri: "file:///example",
+			capabilities: codeActionLiteralCapabilities,
+			id:           lsp.NewIntID(1),
+			uri:          "file:///example",
 			want: lsp.TextDocumentCodeActionResponse{
 				Response: lsp.Response{
 					RPC: "2.0",
-					ID:  1,
+					ID:  lsp.NewIntID(1),
 				},
 				Result: []lsp.CodeAction{
 					{
@@ -277,7 +659,7 @@ func TestTextDocumentCodeAction(t *testing.T) {
 							Changes: map[string][]lsp.TextEdit{
 								"file:///example": {
 									{
-										Range:   LineRange(0, 23, 30), // Adjust based on actual position
+										Range:   LineRange(0, 20, 27),
 										NewText: "Neovim",
 									},
 								},
@@ -290,7 +672,7 @@ func TestTextDocumentCodeAction(t *testing.T) {
 							Changes: map[string][]lsp.TextEdit{
 								"file:///example": {
 									{
-										Range:   LineRange(0, 23, 30), // Adjust based on actual position
+										Range:   LineRange(0, 20, 27),
 										NewText: "VS C*de",
 									},
 								},
@@ -301,17 +683,53 @@ func TestTextDocumentCodeAction(t *testing.T) {
 			},
 			wantError: nil,
 		},
+		{
+			name: "Client without code action literals gets commands",
+			documents: map[lsp.DocumentURI]string{
+				"file:///example": "This is a line with VS Code",
+			},
+			id:  lsp.NewIntID(1),
+			uri: "file:///example",
+			want: lsp.TextDocumentCodeActionResponse{
+				Response: lsp.Response{
+					RPC: "2.0",
+					ID:  lsp.NewIntID(1),
+				},
+				Result: []lsp.Command{
+					{
+						Title:   "Replace VS C*de with a superior editor",
+						Command: ApplyEditCommand,
+						Arguments: []interface{}{&lsp.WorkspaceEdit{
+							Changes: map[string][]lsp.TextEdit{
+								"file:///example": {{Range: LineRange(0, 20, 27), NewText: "Neovim"}},
+							},
+						}},
+					},
+					{
+						Title:   "Censor to VS C*de",
+						Command: ApplyEditCommand,
+						Arguments: []interface{}{&lsp.WorkspaceEdit{
+							Changes: map[string][]lsp.TextEdit{
+								"file:///example": {{Range: LineRange(0, 20, 27), NewText: "VS C*de"}},
+							},
+						}},
+					},
+				},
+			},
+			wantError: nil,
+		},
 		{
 			name: "Document does not contain 'VS Code'",
 			documents: map[lsp.DocumentURI]string{
 				"file:///example": "No special text here",
 			},
-			id:  1,
-			uri: "file:///example",
+			capabilities: codeActionLiteralCapabilities,
+			id:           lsp.NewIntID(1),
+			uri:          "file:///example",
 			want: lsp.TextDocumentCodeActionResponse{
 				Response: lsp.Response{
 					RPC: "2.0",
-					ID:  1,
+					ID:  lsp.NewIntID(1),
 				},
 				Result: []lsp.CodeAction{}, // No actions should be generated
 			},
@@ -320,7 +738,7 @@ func TestTextDocumentCodeAction(t *testing.T) {
 		{
 			name:      "Document not found",
 			documents: map[lsp.DocumentURI]string{},
-			id:        1,
+			id:        lsp.NewIntID(1),
 			uri:       "file:///missing",
 			want:      lsp.TextDocumentCodeActionResponse{},
 			wantError: ErrDocumentNotFound,
@@ -329,19 +747,147 @@ func TestTextDocumentCodeAction(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			state := &State{documents: tc.documents}
-			response, err := state.TextDocumentCodeAction(tc.id, tc.uri)
+			state := newTestState(tc.documents)
+			state.SetClientCapabilities(tc.capabilities)
+			response, err := state.TextDocumentCodeAction(context.Background(), tc.id, tc.uri)
 			if err != nil && err != tc.wantError {
 				t.Errorf("want error %v, got %v", tc.wantError, err)
 			}
 
-			if err == nil && len(response.Result) == 1 && response.Result[0].Title != tc.want.Result[0].Title {
-				t.Errorf("want Title %+v, got %+v", tc.want.Result, response.Result)
+			if err == nil && !reflect.DeepEqual(response, tc.want) {
+				t.Errorf("want %+v, got %+v", tc.want, response)
 			}
 		})
 	}
 }
 
+func TestTextDocumentCodeActionCancelled(t *testing.T) {
+	t.Parallel()
+
+	state := newTestState(map[lsp.DocumentURI]string{
+		"file:///example": "This is a line with VS Code",
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := state.TextDocumentCodeAction(ctx, lsp.NewIntID(1), "file:///example")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("TextDocumentCodeAction got error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestDocumentSymbol(t *testing.T) {
+	t.Parallel()
+
+	hierarchical := lsp.ClientCapabilities{TextDocument: &lsp.TextDocumentClientCapabilities{
+		DocumentSymbol: &lsp.DocumentSymbolClientCapabilities{HierarchicalDocumentSymbolSupport: true},
+	}}
+	install := LineRange(2, 0, 10)
+	text := "# Guide\n\nIntro\n\n## Install *now*\n\nRun it.\n\nUsage\n-----\n\n### `go` run\n\n# Other\n"
+	testCases := []struct {
+		name         string
+		text         string
+		capabilities lsp.ClientCapabilities
+		want         any
+	}{
+		{
+			name:         "nested sections",
+			text:         text,
+			capabilities: hierarchical,
+			want: []lsp.DocumentSymbol{
+				{
+					Name:           "Guide",
+					Kind:           lsp.SymbolKindString,
+					Range:          lsp.Range{Start: lsp.Position{Line: 0, Character: 0}, End: lsp.Position{Line: 11, Character: 12}},
+					SelectionRange: LineRange(0, 2, 7),
+					Children: []lsp.DocumentSymbol{
+						{
+							Name:           "Install now",
+							Kind:           lsp.SymbolKindString,
+							Range:          lsp.Range{Start: lsp.Position{Line: 4, Character: 0}, End: lsp.Position{Line: 6, Character: 7}},
+							SelectionRange: LineRange(4, 3, 16),
+						},
+						{
+							Name:           "Usage",
+							Kind:           lsp.SymbolKindString,
+							Range:          lsp.Range{Start: lsp.Position{Line: 8, Character: 0}, End: lsp.Position{Line: 11, Character: 12}},
+							SelectionRange: LineRange(8, 0, 5),
+							Children: []lsp.DocumentSymbol{
+								{
+									Name:           "go run",
+									Kind:           lsp.SymbolKindString,
+									Range:          LineRange(11, 0, 12),
+									SelectionRange: LineRange(11, 4, 12),
+								},
+							},
+						},
+					},
+				},
+				{
+					Name:           "Other",
+					Kind:           lsp.SymbolKindString,
+					Range:          LineRange(13, 0, 7),
+					SelectionRange: LineRange(13, 2, 7),
+				},
+			},
+		},
+		{
+			name: "flat symbols",
+			text: "# Guide\n\n## Install\n",
+			want: []lsp.SymbolInformation{
+				{
+					Name:     "Guide",
+					Kind:     lsp.SymbolKindString,
+					Location: lsp.Location{URI: "file:///example.md", Range: &lsp.Range{Start: lsp.Position{Line: 0, Character: 0}, End: lsp.Position{Line: 2, Character: 10}}},
+				},
+				{
+					Name:          "Install",
+					Kind:          lsp.SymbolKindString,
+					Location:      lsp.Location{URI: "file:///example.md", Range: &install},
+					ContainerName: "Guide",
+				},
+			},
+		},
+		{
+			name:         "headings in code are ignored",
+			text:         "```\n# Not a heading\n```\n",
+			capabilities: hierarchical,
+			want:         []lsp.DocumentSymbol{},
+		},
+		{
+			name:         "empty heading",
+			text:         "##\n",
+			capabilities: hierarchical,
+			want: []lsp.DocumentSymbol{
+				{Name: "##", Kind: lsp.SymbolKindString, Range: LineRange(0, 0, 2), SelectionRange: LineRange(0, 0, 2)},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			state := newTestState(map[lsp.DocumentURI]string{"file:///example.md": tc.text})
+			state.SetClientCapabilities(tc.capabilities)
+			response, err := state.DocumentSymbol(context.Background(), "file:///example.md", lsp.NewIntID(1))
+			if err != nil {
+				t.Fatalf("DocumentSymbol got unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(response.Result, tc.want) {
+				t.Errorf("DocumentSymbol got %+v, want %+v", response.Result, tc.want)
+			}
+		})
+	}
+
+	t.Run("document not found", func(t *testing.T) {
+		state := newTestState(nil)
+		if _, err := state.DocumentSymbol(context.Background(), "file:///missing.md", lsp.NewIntID(1)); !errors.Is(err, ErrDocumentNotFound) {
+			t.Errorf("DocumentSymbol got error = %v, want %v", err, ErrDocumentNotFound)
+		}
+	})
+}
+
 func TestLineRange(t *testing.T) {
 	testCases := []struct {
 		name   string
@@ -430,25 +976,68 @@ func TestTextDocumentCompletion(t *testing.T) {
 	t.Parallel()
 
 	tests := []struct {
-		name string
-		id   int
-		uri  lsp.DocumentURI
-		want *lsp.TextDocumentCompletionResponse
+		name         string
+		capabilities lsp.ClientCapabilities
+		id           lsp.ID
+		uri          lsp.DocumentURI
+		want         *lsp.TextDocumentCompletionResponse
 	}{
 		{
 			name: "Basic Completion",
-			id:   1,
+			id:   lsp.NewIntID(1),
 			uri:  "file://testfile.go",
 			want: &lsp.TextDocumentCompletionResponse{
 				Response: lsp.Response{
 					RPC: "2.0",
-					ID:  1,
+					ID:  lsp.NewIntID(1),
 				},
 				Result: []lsp.CompletionItem{
 					{
-						Label:         "Custom completion",
-						Detail:        "Some super great details.",
-						Documentation: "This is a documentation tooltip. In a real app, this would be useful information.",
+						Label:  "Custom completion",
+						Detail: "Some super great details.",
+						Documentation: &lsp.MarkupContent{
+							Kind:  lsp.MarkupKindPlainText,
+							Value: "This is a documentation tooltip. In a real app, this would be useful information.",
+						},
+					},
+					{
+						Label:            "link",
+						Detail:           "Markdown link",
+						InsertText:       "[text](url)",
+						InsertTextFormat: lsp.InsertTextFormatPlainText,
+					},
+				},
+			},
+		},
+		{
+			name: "Markdown documentation and snippets",
+			capabilities: lsp.ClientCapabilities{TextDocument: &lsp.TextDocumentClientCapabilities{
+				Completion: &lsp.CompletionClientCapabilities{CompletionItem: &lsp.CompletionItemClientCapabilities{
+					SnippetSupport:      true,
+					DocumentationFormat: []lsp.MarkupKind{lsp.MarkupKindMarkdown},
+				}},
+			}},
+			id:  lsp.NewIntID(2),
+			uri: "file://testfile.go",
+			want: &lsp.TextDocumentCompletionResponse{
+				Response: lsp.Response{
+					RPC: "2.0",
+					ID:  lsp.NewIntID(2),
+				},
+				Result: []lsp.CompletionItem{
+					{
+						Label:  "Custom completion",
+						Detail: "Some super great details.",
+						Documentation: &lsp.MarkupContent{
+							Kind:  lsp.MarkupKindMarkdown,
+							Value: "This is a *documentation* tooltip. In a real app, this would be **useful** information.",
+						},
+					},
+					{
+						Label:            "link",
+						Detail:           "Markdown link",
+						InsertText:       "[${1:text}](${2:url})",
+						InsertTextFormat: lsp.InsertTextFormatSnippet,
 					},
 				},
 			},
@@ -459,11 +1048,31 @@ func TestTextDocumentCompletion(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 			s := &State{}
+			s.SetClientCapabilities(tt.capabilities)
 
-			got := s.TextDocumentCompletion(tt.id, tt.uri)
+			got := s.TextDocumentCompletion(context.Background(), tt.id, tt.uri)
 			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("TextDocumentCompletion got = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+// newTestState creates a state holding the given URIs and their text contents.
+func newTestState(texts map[lsp.DocumentURI]string) *State {
+	state := &State{
+		documents: make(map[lsp.DocumentURI]*Document, len(texts)),
+		trees:     make(map[lsp.DocumentURI]*markdown.Node, len(texts)),
+		workspace: newWorkspaceIndex(),
+	}
+	for uri, text := range texts {
+		state.documents[uri] = NewDocument(text, lsp.PositionEncodingUTF16)
+		state.trees[uri] = markdown.Parse(text)
+	}
+	return state
+}
+
+// codeActionLiteralCapabilities are the capabilities of a client that supports code action literals.
+var codeActionLiteralCapabilities = lsp.ClientCapabilities{TextDocument: &lsp.TextDocumentClientCapabilities{
+	CodeAction: &lsp.CodeActionClientCapabilities{CodeActionLiteralSupport: &lsp.CodeActionLiteralSupport{}},
+}}
@@ -0,0 +1,131 @@
+package compiler
+
+import (
+	"strings"
+
+	"github.com/sebastian-nunez/golang-language-server-protocol/lsp"
+	"github.com/sebastian-nunez/golang-language-server-protocol/markdown"
+)
+
+// section is a heading and the content that follows it, up to the next heading of the same or a
+// higher level. Start and End are the byte offsets of the section in the document.
+type section struct {
+	heading    *markdown.Node
+	name       string
+	start, end int
+	children   []*section
+}
+
+// sections returns the top-level sections of the document, with their subsections nested in them.
+func sections(text string, tree *markdown.Node) []*section {
+	if tree == nil {
+		return nil
+	}
+
+	headings := headings(tree)
+	var roots []*section
+	var open []*section
+	for i, heading := range headings {
+		s := &section{heading: heading, name: headingName(heading), start: heading.Start}
+
+		// The section ends with the last non-blank line before the next heading of the same or a
+		// higher level.
+		end := len(text)
+		for _, next := range headings[i+1:] {
+			if next.Level <= heading.Level {
+				end = strings.LastIndexByte(text[:next.Start], '\n') + 1
+				break
+			}
+		}
+		s.end = max(len(strings.TrimRight(text[:end], " \t\r\n")), heading.End)
+
+		for len(open) > 0 && open[len(open)-1].heading.Level >= heading.Level {
+			open = open[:len(open)-1]
+		}
+		if len(open) == 0 {
+			roots = append(roots, s)
+		} else {
+			parent := open[len(open)-1]
+			parent.children = append(parent.children, s)
+		}
+		open = append(open, s)
+	}
+	return roots
+}
+
+// headings returns the headings of the document, in order, including those nested in other blocks.
+func headings(tree *markdown.Node) []*markdown.Node {
+	var headings []*markdown.Node
+	markdown.Walk(tree, func(n *markdown.Node) bool {
+		if n.Kind == markdown.KindHeading {
+			headings = append(headings, n)
+		}
+		return n.Kind.IsBlock() && n.Kind != markdown.KindHeading
+	})
+	return headings
+}
+
+// headingName returns the text of a heading, without its markers and formatting. Empty headings
+// are named after their level, as symbols must have a name.
+func headingName(heading *markdown.Node) string {
+	if name := headingText(heading); name != "" {
+		return name
+	}
+	return strings.Repeat("#", heading.Level)
+}
+
+// headingText returns the text of a heading, without its markers and formatting.
+func headingText(heading *markdown.Node) string {
+	var sb strings.Builder
+	markdown.Walk(heading, func(n *markdown.Node) bool {
+		switch n.Kind {
+		case markdown.KindText, markdown.KindCodeSpan, markdown.KindAutolink:
+			sb.WriteString(n.Literal)
+		case markdown.KindSoftBreak, markdown.KindHardBreak:
+			sb.WriteString(" ")
+		}
+		return true
+	})
+	return strings.TrimSpace(sb.String())
+}
+
+// selectionRange returns the range of the text of a heading, or of the whole heading if it is empty.
+func (s *section) selectionRange(doc *Document) lsp.Range {
+	children := s.heading.Children
+	if len(children) == 0 {
+		return doc.rangeAt(s.heading.Start, s.heading.End)
+	}
+	return doc.rangeAt(children[0].Start, children[len(children)-1].End)
+}
+
+// documentSymbols converts sections into symbols, nested like the sections.
+func documentSymbols(doc *Document, sections []*section) []lsp.DocumentSymbol {
+	var symbols []lsp.DocumentSymbol
+	for _, s := range sections {
+		symbols = append(symbols, lsp.DocumentSymbol{
+			Name:           s.name,
+			Kind:           lsp.SymbolKindString,
+			Range:          doc.rangeAt(s.start, s.end),
+			SelectionRange: s.selectionRange(doc),
+			Children:       documentSymbols(doc, s.children),
+		})
+	}
+	return symbols
+}
+
+// symbolInformation flattens sections into symbols, in document order, that refer to the section
+// they are nested in by name.
+func symbolInformation(uri lsp.DocumentURI, doc *Document, sections []*section, container string) []lsp.SymbolInformation {
+	var symbols []lsp.SymbolInformation
+	for _, s := range sections {
+		r := doc.rangeAt(s.start, s.end)
+		symbols = append(symbols, lsp.SymbolInformation{
+			Name:          s.name,
+			Kind:          lsp.SymbolKindString,
+			Location:      lsp.Location{URI: uri, Range: &r},
+			ContainerName: container,
+		})
+		symbols = append(symbols, symbolInformation(uri, doc, s.children, s.name)...)
+	}
+	return symbols
+}
@@ -0,0 +1,81 @@
+package compiler
+
+import (
+	"strings"
+
+	"github.com/sebastian-nunez/golang-language-server-protocol/lsp"
+	"github.com/sebastian-nunez/golang-language-server-protocol/markdown"
+)
+
+// textMatches returns the range of every occurrence of the word in the prose of the document.
+// Only text nodes are searched, so code, link destinations, HTML and front matter are skipped.
+func textMatches(doc *Document, tree *markdown.Node, word string) []lsp.Range {
+	if tree == nil {
+		return nil
+	}
+
+	text := doc.Text()
+	var ranges []lsp.Range
+	markdown.Walk(tree, func(n *markdown.Node) bool {
+		if n.Kind != markdown.KindText {
+			return true
+		}
+		for offset := n.Start; ; {
+			idx := strings.Index(text[offset:n.End], word)
+			if idx < 0 {
+				break
+			}
+			start := offset + idx
+			ranges = append(ranges, doc.rangeAt(start, start+len(word)))
+			offset = start + len(word)
+		}
+		return false
+	})
+	return ranges
+}
+
+// textChange merges the content changes applied to a document into a single edit, which spans the
+// text between the longest prefix and suffix of the document that none of them touched.
+type textChange struct {
+	oldLen int
+	prefix int
+	suffix int
+	// full is true when the whole document was replaced.
+	full bool
+}
+
+func newTextChange(doc *Document) *textChange {
+	return &textChange{oldLen: doc.Len(), prefix: doc.Len(), suffix: doc.Len()}
+}
+
+// add records a change, before it is applied to the document.
+func (c *textChange) add(doc *Document, change lsp.TextDocumentContentChangeEvent) {
+	if change.Range == nil {
+		c.full = true
+		return
+	}
+
+	start := doc.OffsetAt(change.Range.Start)
+	end := doc.OffsetAt(change.Range.End)
+	if end < start {
+		start, end = end, start
+	}
+	c.prefix = min(c.prefix, start)
+	c.suffix = min(c.suffix, doc.Len()-end)
+}
+
+// reparse returns the syntax tree of the text of the document once the changes were applied,
+// given its tree from before the changes.
+func (c *textChange) reparse(tree *markdown.Node, text string) *markdown.Node {
+	if c.full || tree == nil {
+		return markdown.Parse(text)
+	}
+
+	// Changes that undo each other can leave a prefix and suffix that overlap.
+	suffix := min(c.suffix, c.oldLen-c.prefix, len(text)-c.prefix)
+	return markdown.Reparse(tree, text, markdown.Edit{
+		Start:  c.prefix,
+		OldEnd: c.oldLen - suffix,
+		NewEnd: len(text) - suffix,
+	})
+}
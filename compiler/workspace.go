@@ -0,0 +1,252 @@
+package compiler
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sebastian-nunez/golang-language-server-protocol/lsp"
+	"github.com/sebastian-nunez/golang-language-server-protocol/markdown"
+)
+
+// workspaceIndex caches the symbols of the markdown files under the workspace folders. Files are
+// only read and parsed again when their size or modification time changes.
+type workspaceIndex struct {
+	mu    sync.Mutex
+	roots []string
+	// generation is incremented whenever the roots change, so that the files indexed for the old
+	// roots are not cached.
+	generation int
+	files      map[string]indexedFile
+}
+
+type indexedFile struct {
+	size     int64
+	modTime  time.Time
+	encoding lsp.PositionEncodingKind
+	symbols  []lsp.SymbolInformation
+}
+
+func newWorkspaceIndex() *workspaceIndex {
+	return &workspaceIndex{files: make(map[string]indexedFile)}
+}
+
+// setRoots replaces the workspace folders and drops the symbols of the files indexed so far.
+func (w *workspaceIndex) setRoots(roots []string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.roots = roots
+	w.generation++
+	w.files = make(map[string]indexedFile)
+}
+
+// folders returns the workspace folders. The slice is replaced, never modified, when they change.
+func (w *workspaceIndex) folders() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.roots
+}
+
+// rootOf returns the innermost workspace folder that contains the path.
+func rootOf(roots []string, path string) (string, bool) {
+	var found string
+	for _, root := range roots {
+		rel, err := filepath.Rel(root, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && len(root) > len(found) {
+			found = root
+		}
+	}
+	return found, found != ""
+}
+
+// symbols returns the symbols of every markdown file under the workspace folders, except for the
+// skipped ones (e.g. the open documents, whose symbols come from their current contents).
+//
+// The folders are walked without holding the lock, so that concurrent searches do not wait for
+// each other. The files they index are cached once the walk is done.
+func (w *workspaceIndex) symbols(ctx context.Context, encoding lsp.PositionEncodingKind, skip map[string]bool) ([]lsp.SymbolInformation, error) {
+	w.mu.Lock()
+	roots, generation := w.roots, w.generation
+	w.mu.Unlock()
+
+	var symbols []lsp.SymbolInformation
+	files := make(map[string]indexedFile)
+	for _, root := range roots {
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err != nil {
+				// Unreadable files and folders are left out of the index.
+				return nil
+			}
+			if entry.IsDir() {
+				if path != root && skipDir(entry.Name()) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if _, seen := files[path]; !isMarkdownFile(path) || skip[path] || seen {
+				return nil
+			}
+
+			if file, ok := w.index(path, entry, encoding); ok {
+				files[path] = file
+				symbols = append(symbols, file.symbols...)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.generation == generation {
+		// The files that were deleted are forgotten.
+		w.files = files
+	}
+	return symbols, nil
+}
+
+// index returns the symbols of a file, parsing it again if it changed since it was last indexed (or
+// if the ranges of its symbols are in another encoding).
+func (w *workspaceIndex) index(path string, entry fs.DirEntry, encoding lsp.PositionEncodingKind) (indexedFile, bool) {
+	info, err := entry.Info()
+	if err != nil {
+		return indexedFile{}, false
+	}
+	w.mu.Lock()
+	file, ok := w.files[path]
+	w.mu.Unlock()
+	if ok && file.size == info.Size() && file.modTime.Equal(info.ModTime()) && file.encoding == encoding {
+		return file, true
+	}
+
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return indexedFile{}, false
+	}
+	doc := NewDocument(string(text), encoding)
+	return indexedFile{
+		size:     info.Size(),
+		modTime:  info.ModTime(),
+		encoding: encoding,
+		symbols:  fileSymbols(lsp.URIFromPath(path), doc, markdown.Parse(string(text))),
+	}, true
+}
+
+// maxWorkspaceSymbols is the number of symbols returned by a workspace symbol search, at most.
+const maxWorkspaceSymbols = 100
+
+// rankSymbols returns the symbols that fuzzily match the query, the best matches first.
+func rankSymbols(query string, symbols []lsp.SymbolInformation) []lsp.SymbolInformation {
+	type match struct {
+		symbol lsp.SymbolInformation
+		score  int
+	}
+	var matches []match
+	for _, symbol := range symbols {
+		if score, ok := fuzzyMatch(query, symbol.Name); ok {
+			matches = append(matches, match{symbol: symbol, score: score})
+		}
+	}
+
+	// Equally good matches favour shorter names, then come in a stable order.
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		switch {
+		case a.score != b.score:
+			return a.score > b.score
+		case len(a.symbol.Name) != len(b.symbol.Name):
+			return len(a.symbol.Name) < len(b.symbol.Name)
+		case a.symbol.Name != b.symbol.Name:
+			return a.symbol.Name < b.symbol.Name
+		case a.symbol.Location.URI != b.symbol.Location.URI:
+			return a.symbol.Location.URI < b.symbol.Location.URI
+		}
+		return a.symbol.Location.Range.Start.Line < b.symbol.Location.Range.Start.Line
+	})
+
+	ranked := make([]lsp.SymbolInformation, 0, min(len(matches), maxWorkspaceSymbols))
+	for _, m := range matches[:min(len(matches), maxWorkspaceSymbols)] {
+		ranked = append(ranked, m.symbol)
+	}
+	return ranked
+}
+
+// skipDir reports whether a folder is left out of the workspace: hidden folders (such as `.git`)
+// and installed dependencies.
+func skipDir(name string) bool {
+	return strings.HasPrefix(name, ".") || name == "node_modules" || name == "vendor"
+}
+
+func isMarkdownFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		return true
+	}
+	return false
+}
+
+// fileSymbols returns the symbols of a document that can be searched from the workspace: its
+// headings, its link reference definitions and the title of its front matter.
+func fileSymbols(uri lsp.DocumentURI, doc *Document, tree *markdown.Node) []lsp.SymbolInformation {
+	if tree == nil {
+		return nil
+	}
+
+	text := doc.Text()
+	symbols := symbolInformation(uri, doc, sections(text, tree), "")
+	markdown.Walk(tree, func(n *markdown.Node) bool {
+		switch n.Kind {
+		case markdown.KindFrontMatter:
+			if title, start, ok := frontMatterTitle(text, n); ok {
+				r := doc.rangeAt(start, start+len(title))
+				symbols = append(symbols, lsp.SymbolInformation{
+					Name:     strings.Trim(title, `"'`),
+					Kind:     lsp.SymbolKindFile,
+					Location: lsp.Location{URI: uri, Range: &r},
+				})
+			}
+		case markdown.KindLinkReferenceDefinition:
+			r := doc.rangeAt(n.Start, n.End)
+			symbols = append(symbols, lsp.SymbolInformation{
+				Name:     n.Literal,
+				Kind:     lsp.SymbolKindKey,
+				Location: lsp.Location{URI: uri, Range: &r},
+			})
+		}
+		return n.Kind.IsBlock()
+	})
+	return symbols
+}
+
+// frontMatterTitle returns the value of the top-level `title` key of the front matter, as written
+// (with its quotes, if any), and its offset in the document.
+func frontMatterTitle(text string, frontMatter *markdown.Node) (string, int, bool) {
+	for start := frontMatter.Start; start < frontMatter.End; {
+		end := strings.IndexByte(text[start:frontMatter.End], '\n')
+		if end < 0 {
+			end = frontMatter.End - start
+		}
+		line := text[start : start+end]
+
+		if value, ok := strings.CutPrefix(line, "title:"); ok {
+			if title := strings.TrimSpace(value); title != "" {
+				leading := len(value) - len(strings.TrimLeft(value, " \t"))
+				return title, start + len("title:") + leading, true
+			}
+		}
+		start += end + 1
+	}
+	return "", 0, false
+}
@@ -0,0 +1,196 @@
+package compiler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/sebastian-nunez/golang-language-server-protocol/lsp"
+)
+
+func TestWorkspaceSymbol(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	files := map[string]string{
+		"README.md":                  "---\ntitle: \"Project Guide\"\n---\n# Getting started\n\n## Install\n\n[docs]: https://example.com\n",
+		"docs/config.md":             "# Configuration\n",
+		"docs/usage.markdown":        "# Usage\n",
+		"notes.txt":                  "# Not markdown\n",
+		".git/notes.md":              "# Hidden\n",
+		"node_modules/pkg/README.md": "# Dependency\n",
+	}
+	for name, text := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	state := NewState()
+	state.SetWorkspaceFolders([]lsp.DocumentURI{lsp.URIFromPath(root)})
+	// Open documents are searched as they are in the editor.
+	if _, err := state.OpenDocument(lsp.URIFromPath(filepath.Join(root, "docs", "config.md")), 1, "# Settings\n"); err != nil {
+		t.Fatalf("OpenDocument got unexpected error: %v", err)
+	}
+
+	testCases := []struct {
+		name      string
+		query     string
+		wantNames []string
+	}{
+		{
+			name:      "empty query lists every symbol",
+			query:     "",
+			wantNames: []string{"docs", "Usage", "Install", "Settings", "Project Guide", "Getting started"},
+		},
+		{
+			name:      "fuzzy query",
+			query:     "gs",
+			wantNames: []string{"Getting started", "Settings"},
+		},
+		{
+			name:      "front matter title",
+			query:     "guide",
+			wantNames: []string{"Project Guide"},
+		},
+		{
+			name:      "open document replaces the file on disk",
+			query:     "configuration",
+			wantNames: nil,
+		},
+		{
+			name:      "skipped files",
+			query:     "e",
+			wantNames: []string{"Settings", "Getting started", "Usage", "Project Guide"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			response, err := state.WorkspaceSymbol(context.Background(), lsp.NewIntID(1), tc.query)
+			if err != nil {
+				t.Fatalf("WorkspaceSymbol got unexpected error: %v", err)
+			}
+
+			var names []string
+			for _, symbol := range response.Result {
+				names = append(names, symbol.Name)
+			}
+			if !reflect.DeepEqual(names, tc.wantNames) {
+				t.Errorf("WorkspaceSymbol(%q) got %q, want %q", tc.query, names, tc.wantNames)
+			}
+		})
+	}
+
+	t.Run("symbol locations", func(t *testing.T) {
+		response, err := state.WorkspaceSymbol(context.Background(), lsp.NewIntID(1), "install")
+		if err != nil {
+			t.Fatalf("WorkspaceSymbol got unexpected error: %v", err)
+		}
+
+		r := lsp.Range{Start: lsp.Position{Line: 5, Character: 0}, End: lsp.Position{Line: 7, Character: 27}}
+		want := []lsp.SymbolInformation{{
+			Name:          "Install",
+			Kind:          lsp.SymbolKindString,
+			Location:      lsp.Location{URI: lsp.URIFromPath(filepath.Join(root, "README.md")), Range: &r},
+			ContainerName: "Getting started",
+		}}
+		if !reflect.DeepEqual(response.Result, want) {
+			t.Errorf("WorkspaceSymbol got %+v, want %+v", response.Result, want)
+		}
+	})
+
+	t.Run("concurrent searches", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				response, err := state.WorkspaceSymbol(context.Background(), lsp.NewIntID(1), "guide")
+				if err != nil {
+					t.Errorf("WorkspaceSymbol got unexpected error: %v", err)
+					return
+				}
+				if len(response.Result) != 1 || response.Result[0].Name != "Project Guide" {
+					t.Errorf("WorkspaceSymbol got %+v, want the front matter title", response.Result)
+				}
+			}()
+		}
+		wg.Wait()
+	})
+
+	t.Run("files changed on disk are indexed again", func(t *testing.T) {
+		if err := os.WriteFile(filepath.Join(root, "docs", "usage.markdown"), []byte("# Reference\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		response, err := state.WorkspaceSymbol(context.Background(), lsp.NewIntID(1), "reference")
+		if err != nil {
+			t.Fatalf("WorkspaceSymbol got unexpected error: %v", err)
+		}
+		if len(response.Result) != 1 || response.Result[0].Name != "Reference" {
+			t.Errorf("WorkspaceSymbol got %+v, want the new heading", response.Result)
+		}
+	})
+}
+
+func TestWorkspaceSymbolCancelled(t *testing.T) {
+	t.Parallel()
+
+	state := NewState()
+	state.SetWorkspaceFolders([]lsp.DocumentURI{lsp.URIFromPath(t.TempDir())})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := state.WorkspaceSymbol(ctx, lsp.NewIntID(1), ""); err != context.Canceled {
+		t.Errorf("WorkspaceSymbol got error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestRootOf(t *testing.T) {
+	t.Parallel()
+
+	roots := []string{filepath.FromSlash("/work"), filepath.FromSlash("/work/docs"), filepath.FromSlash("/other")}
+	testCases := []struct {
+		path string
+		want string
+	}{
+		{path: "/work/README.md", want: "/work"},
+		{path: "/work/docs/usage.md", want: "/work/docs"},
+		{path: "/other/notes.md", want: "/other"},
+		{path: "/workspace/README.md", want: ""},
+		{path: "/README.md", want: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			t.Parallel()
+
+			got, ok := rootOf(roots, filepath.FromSlash(tc.path))
+			if want := filepath.FromSlash(tc.want); got != want || ok != (tc.want != "") {
+				t.Errorf("rootOf(%q) = %q, %t, want %q", tc.path, got, ok, want)
+			}
+		})
+	}
+}
+
+func TestRankSymbols(t *testing.T) {
+	t.Parallel()
+
+	var symbols []lsp.SymbolInformation
+	for i := 0; i <= maxWorkspaceSymbols; i++ {
+		symbols = append(symbols, lsp.SymbolInformation{Name: "Section", Location: lsp.Location{Range: &lsp.Range{}}})
+	}
+	if got := rankSymbols("sec", symbols); len(got) != maxWorkspaceSymbols {
+		t.Errorf("rankSymbols got %d symbols, want %d", len(got), maxWorkspaceSymbols)
+	}
+	if got := rankSymbols("x", symbols); got == nil || len(got) != 0 {
+		t.Errorf("rankSymbols got %+v, want an empty list", got)
+	}
+}
@@ -0,0 +1,11 @@
+package lsp
+
+type CancelRequestNotification struct {
+	Notification
+	Params CancelParams `json:"params"`
+}
+
+type CancelParams struct {
+	// ID is the ID of the request to cancel.
+	ID ID `json:"id"`
+}
@@ -0,0 +1,18 @@
+package lsp
+
+type RegistrationRequest struct {
+	Request
+	Params RegistrationParams `json:"params"`
+}
+
+type RegistrationParams struct {
+	Registrations []Registration `json:"registrations"`
+}
+
+type Registration struct {
+	// ID is used to unregister the capability later on.
+	ID     string `json:"id"`
+	Method string `json:"method"`
+	// RegisterOptions are the options of the registration, which depend on the method.
+	RegisterOptions any `json:"registerOptions,omitempty"`
+}
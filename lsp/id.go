@@ -0,0 +1,87 @@
+package lsp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// idKind is the JSON type an ID was sent as.
+type idKind int
+
+const (
+	idNull idKind = iota
+	idNumber
+	idString
+)
+
+// ID identifies a request and its response. JSON-RPC allows it to be either an integer or a
+// string, and error responses for messages whose ID could not be read carry a null ID. The zero
+// value is the null ID.
+//
+// IDs are comparable, so they can be used as map keys.
+type ID struct {
+	kind   idKind
+	number int64
+	name   string
+}
+
+// NewIntID creates an ID that is encoded as a JSON number.
+func NewIntID(number int64) ID {
+	return ID{kind: idNumber, number: number}
+}
+
+// NewStringID creates an ID that is encoded as a JSON string.
+func NewStringID(name string) ID {
+	return ID{kind: idString, name: name}
+}
+
+// IsNull reports whether the ID is null.
+func (id ID) IsNull() bool {
+	return id.kind == idNull
+}
+
+// String returns a human readable form of the ID, meant for logging.
+func (id ID) String() string {
+	switch id.kind {
+	case idNumber:
+		return strconv.FormatInt(id.number, 10)
+	case idString:
+		return strconv.Quote(id.name)
+	default:
+		return "null"
+	}
+}
+
+func (id ID) MarshalJSON() ([]byte, error) {
+	switch id.kind {
+	case idNumber:
+		return strconv.AppendInt(nil, id.number, 10), nil
+	case idString:
+		return json.Marshal(id.name)
+	default:
+		return []byte("null"), nil
+	}
+}
+
+func (id *ID) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	switch {
+	case bytes.Equal(data, []byte("null")):
+		*id = ID{}
+	case len(data) > 0 && data[0] == '"':
+		var name string
+		if err := json.Unmarshal(data, &name); err != nil {
+			return err
+		}
+		*id = NewStringID(name)
+	default:
+		number, err := strconv.ParseInt(string(data), 10, 64)
+		if err != nil {
+			return fmt.Errorf("id must be an integer or a string, got %s", data)
+		}
+		*id = NewIntID(number)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package lsp
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestIDRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		json     string
+		want     ID
+		wantNull bool
+	}{
+		{
+			name: "integer",
+			json: `1`,
+			want: NewIntID(1),
+		},
+		{
+			name: "zero",
+			json: `0`,
+			want: NewIntID(0),
+		},
+		{
+			name: "large integer",
+			json: `9007199254740993`,
+			want: NewIntID(9007199254740993),
+		},
+		{
+			name: "string",
+			json: `"abc-1"`,
+			want: NewStringID("abc-1"),
+		},
+		{
+			name: "numeric string",
+			json: `"1"`,
+			want: NewStringID("1"),
+		},
+		{
+			name:     "null",
+			json:     `null`,
+			want:     ID{},
+			wantNull: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got ID
+			if err := json.Unmarshal([]byte(tc.json), &got); err != nil {
+				t.Fatalf("Unmarshal got unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("Unmarshal got = %v, want %v", got, tc.want)
+			}
+			if got.IsNull() != tc.wantNull {
+				t.Errorf("IsNull got = %v, want %v", got.IsNull(), tc.wantNull)
+			}
+
+			encoded, err := json.Marshal(got)
+			if err != nil {
+				t.Fatalf("Marshal got unexpected error: %v", err)
+			}
+			if string(encoded) != tc.json {
+				t.Errorf("Marshal got = %s, want %s", encoded, tc.json)
+			}
+		})
+	}
+}
+
+func TestIDUnmarshalInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, input := range []string{`1.5`, `true`, `{}`, `[1]`} {
+		var id ID
+		if err := json.Unmarshal([]byte(input), &id); err == nil {
+			t.Errorf("Unmarshal(%s) got nil error, want error", input)
+		}
+	}
+}
+
+func TestResponseNullID(t *testing.T) {
+	t.Parallel()
+
+	got, err := json.Marshal(NewErrorResponse(ID{}, ParseError, "bad json"))
+	if err != nil {
+		t.Fatalf("Marshal got unexpected error: %v", err)
+	}
+
+	want := `{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"bad json"}}`
+	if string(got) != want {
+		t.Errorf("Marshal got = %s, want %s", got, want)
+	}
+}
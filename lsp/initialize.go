@@ -1,30 +1,23 @@
 package lsp
 
-func NewInitializeResponse(id int) InitializeResponse {
-	version := "0.0.0-alpha.0"
-	textDocumentSync := TextDocumentSyncKind(TextDocumentSyncFull)
-	hoverProvider := true
-	definitionProvider := true
-	codeActionProvider := true
-	completionProvider := map[string]any{}
-
+func NewInitializeResponse(id ID, capabilities ServerCapabilities) InitializeResponse {
 	return InitializeResponse{
 		Response: Response{
 			RPC: "2.0",
 			ID:  id,
 		},
-		Result: InitializeResult{
-			Capabilities: ServerCapabilities{
-				TextDocumentSync:   &textDocumentSync,
-				HoverProvider:      &hoverProvider,
-				DefinitionProvider: &definitionProvider,
-				CodeActionProvider: &codeActionProvider,
-				CompletionProvider: &completionProvider,
-			},
-			ServerInfo: &ServerInfo{
-				Name:    "golang-lsp",
-				Version: &version,
-			},
+		Result: NewInitializeResult(capabilities),
+	}
+}
+
+func NewInitializeResult(capabilities ServerCapabilities) InitializeResult {
+	version := "0.0.0-alpha.0"
+
+	return InitializeResult{
+		Capabilities: capabilities,
+		ServerInfo: &ServerInfo{
+			Name:    "golang-lsp",
+			Version: &version,
 		},
 	}
 }
@@ -45,14 +38,34 @@ type InitializeResult struct {
 }
 
 type ServerCapabilities struct {
+	PositionEncoding   *PositionEncodingKind `json:"positionEncoding,omitempty"`
 	TextDocumentSync   *TextDocumentSyncKind `json:"textDocumentSync,omitempty"`
 	HoverProvider      *bool                 `json:"hoverProvider,omitempty"`
 	DefinitionProvider *bool                 `json:"definitionProvider,omitempty"`
 	CodeActionProvider *bool                 `json:"codeActionProvider,omitempty"`
 	CompletionProvider *map[string]any       `json:"completionProvider,omitempty"`
+	// ExecuteCommandProvider lists the commands handled by `workspace/executeCommand`.
+	ExecuteCommandProvider *ExecuteCommandOptions `json:"executeCommandProvider,omitempty"`
+	// DocumentSymbolProvider is true if the server handles `textDocument/documentSymbol`.
+	DocumentSymbolProvider *bool `json:"documentSymbolProvider,omitempty"`
+	// WorkspaceSymbolProvider is true if the server handles `workspace/symbol`.
+	WorkspaceSymbolProvider *bool `json:"workspaceSymbolProvider,omitempty"`
 	// Yea, not implementing all of this...
 }
 
+// PositionEncodingKind is the encoding used to count the characters of a `Position`.
+// Types are:
+// utf-8: characters are counted in bytes
+// utf-16: characters are counted in UTF-16 code units (the default)
+// utf-32: characters are counted in Unicode code points
+type PositionEncodingKind string
+
+const (
+	PositionEncodingUTF8  PositionEncodingKind = "utf-8"
+	PositionEncodingUTF16 PositionEncodingKind = "utf-16"
+	PositionEncodingUTF32 PositionEncodingKind = "utf-32"
+)
+
 // TextDocumentSyncKind represents the type of text document sync. Types are:
 // 0: None
 // 1: Full
@@ -78,7 +91,7 @@ type InitializeParams struct {
 	RootUri               *string            `json:"rootUri"`
 	InitializationOptions interface{}        `json:"initializationOptions,omitempty"`
 	Capabilities          ClientCapabilities `json:"capabilities"`
-	Trace                 *string            `json:"trace,omitempty"`
+	Trace                 *TraceValue        `json:"trace,omitempty"`
 	WorkspaceFolders      []WorkspaceFolder  `json:"workspaceFolders,omitempty"`
 }
 
@@ -88,7 +101,69 @@ type ClientInfo struct {
 }
 
 type ClientCapabilities struct {
-	// Yea, not implementing all of this...
+	Workspace    *WorkspaceClientCapabilities    `json:"workspace,omitempty"`
+	TextDocument *TextDocumentClientCapabilities `json:"textDocument,omitempty"`
+	General      *GeneralClientCapabilities      `json:"general,omitempty"`
+	// Only the capabilities the server tailors its responses to are modeled.
+}
+
+type WorkspaceClientCapabilities struct {
+	// ApplyEdit is true if the client supports `workspace/applyEdit` requests.
+	ApplyEdit bool `json:"applyEdit,omitempty"`
+	// Configuration is true if the client supports `workspace/configuration` requests.
+	Configuration bool `json:"configuration,omitempty"`
+}
+
+type TextDocumentClientCapabilities struct {
+	Hover              *HoverClientCapabilities              `json:"hover,omitempty"`
+	Completion         *CompletionClientCapabilities         `json:"completion,omitempty"`
+	CodeAction         *CodeActionClientCapabilities         `json:"codeAction,omitempty"`
+	PublishDiagnostics *PublishDiagnosticsClientCapabilities `json:"publishDiagnostics,omitempty"`
+	DocumentSymbol     *DocumentSymbolClientCapabilities     `json:"documentSymbol,omitempty"`
+}
+
+type HoverClientCapabilities struct {
+	// ContentFormat lists the formats supported for the hover contents, in order of preference.
+	ContentFormat []MarkupKind `json:"contentFormat,omitempty"`
+}
+
+type CompletionClientCapabilities struct {
+	CompletionItem *CompletionItemClientCapabilities `json:"completionItem,omitempty"`
+}
+
+type CompletionItemClientCapabilities struct {
+	// SnippetSupport is true if the client supports snippets as insert text.
+	SnippetSupport bool `json:"snippetSupport,omitempty"`
+	// DocumentationFormat lists the formats supported for the documentation, in order of preference.
+	DocumentationFormat []MarkupKind `json:"documentationFormat,omitempty"`
+}
+
+type CodeActionClientCapabilities struct {
+	// CodeActionLiteralSupport is set if the client supports `CodeAction` literals as results of
+	// `textDocument/codeAction`. Otherwise only `Command`s may be returned.
+	CodeActionLiteralSupport *CodeActionLiteralSupport `json:"codeActionLiteralSupport,omitempty"`
+}
+
+type CodeActionLiteralSupport struct {
+	CodeActionKind struct {
+		ValueSet []string `json:"valueSet"`
+	} `json:"codeActionKind"`
+}
+
+type PublishDiagnosticsClientCapabilities struct {
+	// RelatedInformation is true if the client supports the related information of diagnostics.
+	RelatedInformation bool `json:"relatedInformation,omitempty"`
+}
+
+type DocumentSymbolClientCapabilities struct {
+	// HierarchicalDocumentSymbolSupport is true if the client supports `DocumentSymbol`s as results
+	// of `textDocument/documentSymbol`. Otherwise only `SymbolInformation`s may be returned.
+	HierarchicalDocumentSymbolSupport bool `json:"hierarchicalDocumentSymbolSupport,omitempty"`
+}
+
+type GeneralClientCapabilities struct {
+	// PositionEncodings are the encodings supported by the client, in order of preference.
+	PositionEncodings []PositionEncodingKind `json:"positionEncodings,omitempty"`
 }
 
 type WorkspaceFolder struct {
@@ -97,7 +172,6 @@ type WorkspaceFolder struct {
 }
 
 type DocumentURI string
-type TraceValue string
 type MarkedString string
 
 type WorkspaceEdit struct {
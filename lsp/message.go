@@ -3,16 +3,35 @@ package lsp
 // Request is the structure that all LSP requests should follow.
 type Request struct {
 	RPC    string `json:"jsonrpc"`
-	ID     int    `json:"id"`
+	ID     ID     `json:"id"`
 	Method string `json:"method"`
 	// Params will be specified within each of the request types.
 }
 
 // Response is the structure that all LSP responses should follow.
 type Response struct {
-	RPC string `json:"jsonrpc"`
-	ID  int    `json:"id"` // Can be nil.
-	// Result and errors will be specified within each of the response types.
+	RPC   string         `json:"jsonrpc"`
+	ID    ID             `json:"id"` // Can be null.
+	Error *ResponseError `json:"error,omitempty"`
+	// Result will be specified within each of the response types.
+}
+
+// ResultResponse is a successful response carrying the result of any request.
+type ResultResponse struct {
+	Response
+	Result any `json:"result"`
+}
+
+// NewResultResponse creates a response for the request with the given ID. A nil result is
+// sent as `null`.
+func NewResultResponse(id ID, result any) ResultResponse {
+	return ResultResponse{
+		Response: Response{
+			RPC: "2.0",
+			ID:  id,
+		},
+		Result: result,
+	}
 }
 
 // Notification is the structure that all LSP notifications should follow.
@@ -20,3 +39,58 @@ type Notification struct {
 	RPC    string `json:"jsonrpc"`
 	Method string `json:"method"`
 }
+
+// NewErrorResponse creates a response reporting that the request with the given ID failed.
+func NewErrorResponse(id ID, code ErrorCode, message string) Response {
+	return Response{
+		RPC: "2.0",
+		ID:  id,
+		Error: &ResponseError{
+			Code:    code,
+			Message: message,
+		},
+	}
+}
+
+// ResponseError is the error returned in a response when a request fails.
+type ResponseError struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+	Data    any       `json:"data,omitempty"`
+}
+
+func (e *ResponseError) Error() string {
+	return e.Message
+}
+
+// ErrorCode is a JSON-RPC error code, including the codes reserved by the LSP specification.
+type ErrorCode int
+
+// Error codes defined by JSON-RPC and the LSP specification:
+// https://microsoft.github.io/language-server-protocol/specifications/lsp/3.17/specification/#errorCodes
+const (
+	// ParseError means invalid JSON was received.
+	ParseError ErrorCode = -32700
+	// InvalidRequest means the message sent is not a valid request.
+	InvalidRequest ErrorCode = -32600
+	// MethodNotFound means the method does not exist or is not supported.
+	MethodNotFound ErrorCode = -32601
+	// InvalidParams means the parameters of the request are invalid.
+	InvalidParams ErrorCode = -32602
+	// InternalError means the server failed for a reason unrelated to the request.
+	InternalError ErrorCode = -32603
+
+	// ServerNotInitialized means a request was received before the `initialize` request.
+	ServerNotInitialized ErrorCode = -32002
+	// UnknownErrorCode is used for errors that do not fit any other code.
+	UnknownErrorCode ErrorCode = -32001
+
+	// RequestFailed means the request was valid but could not be completed.
+	RequestFailed ErrorCode = -32803
+	// ServerCancelled means the server cancelled the request.
+	ServerCancelled ErrorCode = -32802
+	// ContentModified means the document changed while the request was being processed.
+	ContentModified ErrorCode = -32801
+	// RequestCancelled means the client cancelled the request.
+	RequestCancelled ErrorCode = -32800
+)
@@ -0,0 +1,21 @@
+package lsp
+
+func NewShutdownResponse(id ID) ShutdownResponse {
+	return ShutdownResponse{
+		Response: Response{
+			RPC: "2.0",
+			ID:  id,
+		},
+		Result: nil,
+	}
+}
+
+type ShutdownRequest struct {
+	Request
+}
+
+type ShutdownResponse struct {
+	Response
+	// Result is always null.
+	Result any `json:"result"`
+}
@@ -22,6 +22,22 @@ type TextDocumentPositionParams struct {
 }
 
 type Position struct {
-	Line      int `json:"line"`
+	Line int `json:"line"`
+	// Character is the offset within the line, counted in the negotiated `PositionEncodingKind`.
 	Character int `json:"character"`
 }
+
+// MarkupKind is the format of a `MarkupContent`. Types are:
+// plaintext: the content is shown as is
+// markdown: the content is rendered as GitHub flavored markdown
+type MarkupKind string
+
+const (
+	MarkupKindPlainText MarkupKind = "plaintext"
+	MarkupKindMarkdown  MarkupKind = "markdown"
+)
+
+type MarkupContent struct {
+	Kind  MarkupKind `json:"kind"`
+	Value string     `json:"value"`
+}
@@ -13,7 +13,8 @@ type TextDocumentCodeActionParams struct {
 
 type TextDocumentCodeActionResponse struct {
 	Response
-	Result []CodeAction `json:"result"`
+	// Result is a `[]CodeAction`, or a `[]Command` for clients without code action literal support.
+	Result any `json:"result"`
 }
 
 type CodeActionContext struct {
@@ -1,6 +1,6 @@
 package lsp
 
-func NewTextDocumentCompletionResponse(id int, contents MarkedString) *TextDocumentCompletionResponse {
+func NewTextDocumentCompletionResponse(id ID, contents MarkedString) *TextDocumentCompletionResponse {
 	return &TextDocumentCompletionResponse{
 		Response: Response{
 			RPC: "2.0",
@@ -25,7 +25,20 @@ type TextDocumentCompletionResponse struct {
 }
 
 type CompletionItem struct {
-	Label         string `json:"label"`
-	Detail        string `json:"detail,omitempty"`
-	Documentation string `json:"documentation,omitempty"`
+	Label         string         `json:"label"`
+	Detail        string         `json:"detail,omitempty"`
+	Documentation *MarkupContent `json:"documentation,omitempty"`
+	// InsertText is inserted instead of the label, if set.
+	InsertText       string           `json:"insertText,omitempty"`
+	InsertTextFormat InsertTextFormat `json:"insertTextFormat,omitempty"`
 }
+
+// InsertTextFormat is the format of the insert text of a completion item. Types are:
+// 1: PlainText
+// 2: Snippet, with tab stops (`$1`) and placeholders (`${1:text}`)
+type InsertTextFormat int
+
+const (
+	InsertTextFormatPlainText InsertTextFormat = 1
+	InsertTextFormatSnippet   InsertTextFormat = 2
+)
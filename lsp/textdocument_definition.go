@@ -1,15 +1,14 @@
 package lsp
 
-func NewTextDocumentDefinitionResponse(id int, uri DocumentURI, rang Range, contents MarkedString) *TextDocumentDefinitionResponse {
+// NewTextDocumentDefinitionResponse creates a response with the location of a definition, or with
+// a null result if the location is nil.
+func NewTextDocumentDefinitionResponse(id ID, location *Location) *TextDocumentDefinitionResponse {
 	return &TextDocumentDefinitionResponse{
 		Response: Response{
 			RPC: "2.0",
 			ID:  id,
 		},
-		Result: &Location{
-			URI:   uri,
-			Range: &rang,
-		},
+		Result: location,
 	}
 }
 
@@ -6,7 +6,9 @@ type PublishDiagnosticsNotification struct {
 }
 
 type PublishDiagnosticsParams struct {
-	URI         DocumentURI  `json:"uri"`
+	URI DocumentURI `json:"uri"`
+	// Version is the version of the document the diagnostics were computed for.
+	Version     *int         `json:"version,omitempty"`
 	Diagnostics []Diagnostic `json:"diagnostics"`
 }
 
@@ -16,6 +18,13 @@ type Diagnostic struct {
 	Code     *string            `json:"code,omitempty"`
 	Source   *string            `json:"source,omitempty"`
 	Message  string             `json:"message"`
+	// RelatedInformation points to other locations related to the diagnostic.
+	RelatedInformation []DiagnosticRelatedInformation `json:"relatedInformation,omitempty"`
+}
+
+type DiagnosticRelatedInformation struct {
+	Location Location `json:"location"`
+	Message  string   `json:"message"`
 }
 
 type DiagnosticSeverity float64
@@ -6,7 +6,7 @@ type TextDocumentDidChangeNotification struct {
 }
 
 type DidChangeTextDocumentParams struct {
-	TextDocument   TextDocumentIdentifier           `json:"textDocument"`
+	TextDocument   VersionTextDocumentIdentifier    `json:"textDocument"`
 	ContentChanges []TextDocumentContentChangeEvent `json:"contentChanges"`
 }
 
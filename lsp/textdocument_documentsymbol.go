@@ -0,0 +1,70 @@
+package lsp
+
+type DocumentSymbolRequest struct {
+	Request
+	Params DocumentSymbolParams `json:"params"`
+}
+
+type DocumentSymbolParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+type TextDocumentDocumentSymbolResponse struct {
+	Response
+	// Result is a `[]DocumentSymbol`, or a `[]SymbolInformation` for clients without hierarchical
+	// document symbol support.
+	Result any `json:"result"`
+}
+
+// DocumentSymbol is a symbol of a document, such as a heading, along with the symbols nested in it.
+type DocumentSymbol struct {
+	Name   string     `json:"name"`
+	Detail string     `json:"detail,omitempty"`
+	Kind   SymbolKind `json:"kind"`
+	// Range encloses the whole symbol, e.g. a heading and the content of its section, while
+	// SelectionRange is the part revealed when the symbol is selected, e.g. the heading text.
+	Range          Range            `json:"range"`
+	SelectionRange Range            `json:"selectionRange"`
+	Children       []DocumentSymbol `json:"children,omitempty"`
+}
+
+// SymbolInformation is a symbol without hierarchy. ContainerName is the name of the symbol it is
+// nested in, if any.
+type SymbolInformation struct {
+	Name          string     `json:"name"`
+	Kind          SymbolKind `json:"kind"`
+	Location      Location   `json:"location"`
+	ContainerName string     `json:"containerName,omitempty"`
+}
+
+// SymbolKind is the kind of a symbol. Types are numbered from 1 (File) to 26 (TypeParameter).
+type SymbolKind int
+
+const (
+	SymbolKindFile          SymbolKind = 1
+	SymbolKindModule        SymbolKind = 2
+	SymbolKindNamespace     SymbolKind = 3
+	SymbolKindPackage       SymbolKind = 4
+	SymbolKindClass         SymbolKind = 5
+	SymbolKindMethod        SymbolKind = 6
+	SymbolKindProperty      SymbolKind = 7
+	SymbolKindField         SymbolKind = 8
+	SymbolKindConstructor   SymbolKind = 9
+	SymbolKindEnum          SymbolKind = 10
+	SymbolKindInterface     SymbolKind = 11
+	SymbolKindFunction      SymbolKind = 12
+	SymbolKindVariable      SymbolKind = 13
+	SymbolKindConstant      SymbolKind = 14
+	SymbolKindString        SymbolKind = 15
+	SymbolKindNumber        SymbolKind = 16
+	SymbolKindBoolean       SymbolKind = 17
+	SymbolKindArray         SymbolKind = 18
+	SymbolKindObject        SymbolKind = 19
+	SymbolKindKey           SymbolKind = 20
+	SymbolKindNull          SymbolKind = 21
+	SymbolKindEnumMember    SymbolKind = 22
+	SymbolKindStruct        SymbolKind = 23
+	SymbolKindEvent         SymbolKind = 24
+	SymbolKindOperator      SymbolKind = 25
+	SymbolKindTypeParameter SymbolKind = 26
+)
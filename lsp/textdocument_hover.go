@@ -1,6 +1,6 @@
 package lsp
 
-func NewTextDocumentHoverResponse(id int, contents MarkedString) *TextDocumentHoverResponse {
+func NewTextDocumentHoverResponse(id ID, contents MarkupContent) *TextDocumentHoverResponse {
 	return &TextDocumentHoverResponse{
 		Response: Response{
 			RPC: "2.0",
@@ -27,6 +27,6 @@ type TextDocumentHoverResponse struct {
 }
 
 type HoverResult struct {
-	Contents MarkedString `json:"contents"`
-	Range    *Range       `json:"range,omitempty"`
+	Contents MarkupContent `json:"contents"`
+	Range    *Range        `json:"range,omitempty"`
 }
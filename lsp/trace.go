@@ -0,0 +1,30 @@
+package lsp
+
+// TraceValue is the level of the `$/logTrace` notifications the client wants to receive.
+type TraceValue string
+
+const (
+	TraceOff      TraceValue = "off"
+	TraceMessages TraceValue = "messages"
+	TraceVerbose  TraceValue = "verbose"
+)
+
+type SetTraceNotification struct {
+	Notification
+	Params SetTraceParams `json:"params"`
+}
+
+type SetTraceParams struct {
+	Value TraceValue `json:"value"`
+}
+
+type LogTraceNotification struct {
+	Notification
+	Params LogTraceParams `json:"params"`
+}
+
+type LogTraceParams struct {
+	Message string `json:"message"`
+	// Verbose holds additional details, only sent when the trace is set to `verbose`.
+	Verbose string `json:"verbose,omitempty"`
+}
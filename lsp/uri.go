@@ -0,0 +1,36 @@
+package lsp
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// URIFromPath returns the `file` URI of an absolute path.
+func URIFromPath(path string) DocumentURI {
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		// Windows paths start with a drive letter: `C:/dir` is `file:///C:/dir`.
+		path = "/" + path
+	}
+	return DocumentURI((&url.URL{Scheme: "file", Path: path}).String())
+}
+
+// Path returns the path of a `file` URI, with the separators of the operating system.
+func (u DocumentURI) Path() (string, error) {
+	parsed, err := url.Parse(string(u))
+	if err != nil {
+		return "", err
+	}
+	if parsed.Scheme != "file" {
+		return "", fmt.Errorf("not a file URI: %s", u)
+	}
+
+	path := parsed.Path
+	if len(path) >= 3 && path[0] == '/' && path[2] == ':' {
+		// Drop the slash in front of a Windows drive letter.
+		path = path[1:]
+	}
+	return filepath.FromSlash(path), nil
+}
@@ -0,0 +1,65 @@
+package lsp
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestDocumentURIPath(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		uri     DocumentURI
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "absolute path",
+			uri:  "file:///home/user/docs/README.md",
+			want: filepath.FromSlash("/home/user/docs/README.md"),
+		},
+		{
+			name: "escaped characters",
+			uri:  "file:///home/user/my%20docs/%C3%A9t%C3%A9.md",
+			want: filepath.FromSlash("/home/user/my docs/été.md"),
+		},
+		{
+			name: "windows drive letter",
+			uri:  "file:///C:/Users/docs/README.md",
+			want: filepath.FromSlash("C:/Users/docs/README.md"),
+		},
+		{
+			name:    "not a file",
+			uri:     "untitled:Untitled-1",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := tc.uri.Path()
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Path got error = %v, want error %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("Path got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestURIFromPath(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.FromSlash("/home/user/my docs/README.md")
+	uri := URIFromPath(path)
+	if want := DocumentURI("file:///home/user/my%20docs/README.md"); uri != want {
+		t.Errorf("URIFromPath got %q, want %q", uri, want)
+	}
+	if got, err := uri.Path(); err != nil || got != path {
+		t.Errorf("Path of %q got %q, %v, want %q", uri, got, err, path)
+	}
+}
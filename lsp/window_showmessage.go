@@ -0,0 +1,32 @@
+package lsp
+
+type ShowMessageRequest struct {
+	Request
+	Params ShowMessageRequestParams `json:"params"`
+}
+
+type ShowMessageRequestParams struct {
+	Type    MessageType `json:"type"`
+	Message string      `json:"message"`
+	// Actions are the buttons offered to the user.
+	Actions []MessageActionItem `json:"actions,omitempty"`
+}
+
+type ShowMessageResponse struct {
+	Response
+	// Result is the action picked by the user, or null if the message was dismissed.
+	Result *MessageActionItem `json:"result"`
+}
+
+type MessageActionItem struct {
+	Title string `json:"title"`
+}
+
+type MessageType int
+
+const (
+	MessageTypeError   MessageType = 1
+	MessageTypeWarning MessageType = 2
+	MessageTypeInfo    MessageType = 3
+	MessageTypeLog     MessageType = 4
+)
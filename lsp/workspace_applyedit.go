@@ -0,0 +1,25 @@
+package lsp
+
+type ApplyWorkspaceEditRequest struct {
+	Request
+	Params ApplyWorkspaceEditParams `json:"params"`
+}
+
+type ApplyWorkspaceEditParams struct {
+	// Label is presented in the user interface, e.g. on an undo stack.
+	Label string        `json:"label,omitempty"`
+	Edit  WorkspaceEdit `json:"edit"`
+}
+
+type ApplyWorkspaceEditResponse struct {
+	Response
+	Result ApplyWorkspaceEditResult `json:"result"`
+}
+
+type ApplyWorkspaceEditResult struct {
+	Applied bool `json:"applied"`
+	// FailureReason may be set by the client when the edit was not applied.
+	FailureReason string `json:"failureReason,omitempty"`
+	// FailedChange is the index of the change that failed, if the client supports it.
+	FailedChange *int `json:"failedChange,omitempty"`
+}
@@ -0,0 +1,24 @@
+package lsp
+
+type ConfigurationRequest struct {
+	Request
+	Params ConfigurationParams `json:"params"`
+}
+
+type ConfigurationParams struct {
+	Items []ConfigurationItem `json:"items"`
+}
+
+type ConfigurationItem struct {
+	// ScopeURI is the scope to get the configuration section for.
+	ScopeURI *DocumentURI `json:"scopeUri,omitempty"`
+	// Section is the configuration section asked for.
+	Section string `json:"section,omitempty"`
+}
+
+type ConfigurationResponse struct {
+	Response
+	// Result holds the configuration of every requested item, in order. An item is null if the
+	// client has no configuration for it.
+	Result []any `json:"result"`
+}
@@ -0,0 +1,17 @@
+package lsp
+
+import "encoding/json"
+
+type ExecuteCommandRequest struct {
+	Request
+	Params ExecuteCommandParams `json:"params"`
+}
+
+type ExecuteCommandParams struct {
+	Command   string            `json:"command"`
+	Arguments []json.RawMessage `json:"arguments,omitempty"`
+}
+
+type ExecuteCommandOptions struct {
+	Commands []string `json:"commands"`
+}
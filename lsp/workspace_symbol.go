@@ -0,0 +1,16 @@
+package lsp
+
+type WorkspaceSymbolRequest struct {
+	Request
+	Params WorkspaceSymbolParams `json:"params"`
+}
+
+type WorkspaceSymbolParams struct {
+	// Query filters the symbols. Clients may send an empty query to list every symbol.
+	Query string `json:"query"`
+}
+
+type WorkspaceSymbolResponse struct {
+	Response
+	Result []SymbolInformation `json:"result"`
+}
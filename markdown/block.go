@@ -0,0 +1,961 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+)
+
+// codeIndent is the indentation, in columns, of an indented code block.
+const codeIndent = 4
+
+var (
+	reMaybeSpecial        = regexp.MustCompile("^[#`~*+_=<>0-9\\[-]")
+	reFootnoteDefinition  = regexp.MustCompile(`^\[\^([^\] \t\r\n]+)\]:[ \t]*`)
+	reATXHeadingMarker    = regexp.MustCompile(`^#{1,6}(?:[ \t]+|$)`)
+	reATXClosingEmpty     = regexp.MustCompile(`^[ \t]*#+[ \t]*$`)
+	reATXClosingSequence  = regexp.MustCompile(`[ \t]+#+[ \t]*$`)
+	reSetextHeadingLine   = regexp.MustCompile(`^(?:=+|-+)[ \t]*$`)
+	reBulletListMarker    = regexp.MustCompile(`^[*+-]`)
+	reOrderedListMarker   = regexp.MustCompile(`^(\d{1,9})([.)])`)
+	reNonSpace            = regexp.MustCompile(`[^ \t\f\v\r\n]`)
+	reTrailingBlankLines  = regexp.MustCompile(`^[ \t]*$`)
+	reHTMLBlockCloseTypes = [...]*regexp.Regexp{
+		1: regexp.MustCompile(`(?i)</(?:script|pre|textarea|style)>`),
+		2: regexp.MustCompile(`-->`),
+		3: regexp.MustCompile(`\?>`),
+		4: regexp.MustCompile(`>`),
+		5: regexp.MustCompile(`\]\]>`),
+	}
+	reHTMLBlockOpenTypes = [...]*regexp.Regexp{
+		1: regexp.MustCompile(`(?i)^<(?:script|pre|textarea|style)(?:\s|>|$)`),
+		2: regexp.MustCompile(`^<!--`),
+		3: regexp.MustCompile(`^<[?]`),
+		4: regexp.MustCompile(`^<![A-Za-z]`),
+		5: regexp.MustCompile(`^<!\[CDATA\[`),
+		6: regexp.MustCompile(`(?i)^</?(?:address|article|aside|base|basefont|blockquote|body|caption|center|col|colgroup|dd|details|dialog|dir|div|dl|dt|fieldset|figcaption|figure|footer|form|frame|frameset|h[123456]|head|header|hr|html|iframe|legend|li|link|main|menu|menuitem|nav|noframes|ol|optgroup|option|p|param|search|section|summary|table|tbody|td|tfoot|th|thead|title|tr|track|ul)(?:\s|/?>|$)`),
+		7: regexp.MustCompile(`(?i)^(?:` + openTag + `|` + closeTag + `)\s*$`),
+	}
+)
+
+// line is a line of the content of a leaf block.
+type line struct {
+	// start and end are the offsets of the content in the source.
+	start, end int
+	// text is the content. It only differs from the source when a tab was partially consumed by
+	// the indentation of a container, in which case its remaining columns are added as spaces.
+	text string
+}
+
+// listData describes the marker of a list item.
+type listData struct {
+	ordered    bool
+	bulletChar byte
+	delimiter  byte
+	// markerOffset is the indentation of the marker and padding the width of the marker and the
+	// spaces that follow it: the content of the item starts at markerOffset+padding.
+	markerOffset int
+	padding      int
+}
+
+// block is a block being parsed, along with the state needed to decide which lines belong to it.
+type block struct {
+	node     *Node
+	parent   *block
+	children []*block
+	open     bool
+	// startLine and endLine are the indexes of the first and last lines of the block.
+	startLine, endLine int
+
+	// lines holds the content of paragraphs, headings, code and HTML blocks.
+	lines []line
+
+	fenced      bool
+	fenceChar   byte
+	fenceLength int
+	fenceOffset int
+	htmlType    int
+	list        listData
+}
+
+func (b *block) lastChild() *block {
+	if len(b.children) == 0 {
+		return nil
+	}
+	return b.children[len(b.children)-1]
+}
+
+// lineSpan is the position of a line in the source, excluding its line ending.
+type lineSpan struct {
+	start, end int
+}
+
+// blockParser builds the block structure of a document one line at a time, following the
+// parsing strategy of the CommonMark specification: each line first continues the open blocks it
+// matches, then may start new blocks, and its remaining text is added to the innermost one.
+//
+// https://spec.commonmark.org/0.31.2/#appendix-a-parsing-strategy
+type blockParser struct {
+	source string
+	lines  []lineSpan
+	doc    *block
+	tip    *block
+	// oldTip is the tip before the current line and lastMatched the last open block matched by it.
+	oldTip      *block
+	lastMatched *block
+	allClosed   bool
+	// refs are the link reference definitions, by normalized label.
+	refs map[string]reference
+	// footnotes are the normalized labels of the footnote definitions.
+	footnotes map[string]bool
+	// leaves are the paragraphs and headings whose inline content is parsed once every link
+	// reference definition is known.
+	leaves []*block
+
+	// line is the current line and lineStart its offset in the source.
+	line       string
+	lineStart  int
+	lineNumber int
+	// offset is the byte offset in the current line and column the matching column, with tabs
+	// expanded to the next multiple of 4.
+	offset               int
+	column               int
+	nextNonspace         int
+	nextNonspaceColumn   int
+	indent               int
+	indented             bool
+	blank                bool
+	partiallyConsumedTab bool
+}
+
+// Parse parses a CommonMark document. It never fails: any text is a valid markdown document.
+func Parse(source string) *Node {
+	p := newBlockParser(source, make(map[string]reference), make(map[string]bool))
+	p.lines = splitLines(source)
+
+	first := 0
+	if closing, ok := p.frontMatter(); ok {
+		first = closing + 1
+	}
+	for n := first; n < len(p.lines); n++ {
+		p.incorporateLine(n)
+	}
+	return p.finish()
+}
+
+func newBlockParser(source string, refs map[string]reference, footnotes map[string]bool) *blockParser {
+	p := &blockParser{
+		source:    source,
+		refs:      refs,
+		footnotes: footnotes,
+	}
+	p.doc = &block{node: &Node{Kind: KindDocument}, open: true}
+	p.tip = p.doc
+	p.oldTip = p.doc
+	p.lastMatched = p.doc
+	p.allClosed = true
+	return p
+}
+
+// finish closes the blocks that are still open and parses the inline content of the document.
+func (p *blockParser) finish() *Node {
+	for p.tip != nil {
+		p.finalize(p.tip, len(p.lines)-1)
+	}
+	p.doc.node.End = len(p.source)
+
+	for _, leaf := range p.leaves {
+		leaf.node.Children = parseInlines(leaf.lines, p.refs, p.footnotes)
+	}
+	return p.doc.node
+}
+
+// splitLines returns the position of every line of the source. A line ending at the end of the
+// source does not start another line.
+func splitLines(source string) []lineSpan {
+	var lines []lineSpan
+	for start := 0; start < len(source); {
+		var span lineSpan
+		span, start = nextLine(source, start)
+		lines = append(lines, span)
+	}
+	return lines
+}
+
+// nextLine returns the position of the line starting at the given offset and the offset of the
+// line that follows it.
+func nextLine(source string, start int) (lineSpan, int) {
+	end := strings.IndexByte(source[start:], '\n')
+	next := start + end + 1
+	if end < 0 {
+		end = len(source) - start
+		next = len(source)
+	}
+	return lineSpan{start: start, end: start + len(strings.TrimSuffix(source[start:start+end], "\r"))}, next
+}
+
+// lineEnd returns the offset of the end of the given line, excluding its line ending.
+func (p *blockParser) lineEnd(n int) int {
+	if n < 0 || n >= len(p.lines) {
+		return 0
+	}
+	return p.lines[n].end
+}
+
+// frontMatter adds the front matter at the start of the document, if any, and returns the index
+// of its closing line.
+func (p *blockParser) frontMatter() (int, bool) {
+	text := func(n int) string {
+		return strings.TrimRight(p.source[p.lines[n].start:p.lines[n].end], " \t")
+	}
+	if len(p.lines) == 0 || text(0) != "---" {
+		return 0, false
+	}
+	for n := 1; n < len(p.lines); n++ {
+		if t := text(n); t == "---" || t == "..." {
+			node := &Node{
+				Kind:    KindFrontMatter,
+				Start:   0,
+				End:     p.lines[n].end,
+				Literal: p.source[p.lines[0].end:p.lines[n].start],
+			}
+			node.Literal = strings.TrimPrefix(strings.TrimPrefix(node.Literal, "\r"), "\n")
+			p.doc.node.Children = append(p.doc.node.Children, node)
+			p.doc.children = append(p.doc.children, &block{node: node, parent: p.doc, startLine: 0, endLine: n})
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+// incorporateLine adds a line to the blocks it belongs to.
+func (p *blockParser) incorporateLine(n int) {
+	p.lineNumber = n
+	p.lineStart = p.lines[n].start
+	p.line = p.source[p.lines[n].start:p.lines[n].end]
+	p.offset = 0
+	p.column = 0
+	p.blank = false
+	p.partiallyConsumedTab = false
+	p.oldTip = p.tip
+
+	container := p.doc
+match:
+	for {
+		last := container.lastChild()
+		if last == nil || !last.open {
+			break
+		}
+		container = last
+		p.findNextNonspace()
+		switch p.continueBlock(container) {
+		case continueMatched:
+		case continueFailed:
+			container = container.parent
+			break match
+		case continueConsumed:
+			// The line closed a fenced code block and nothing else is left to do with it.
+			return
+		}
+	}
+
+	p.allClosed = container == p.oldTip
+	p.lastMatched = container
+
+	matchedLeaf := container.node.Kind != KindParagraph && acceptsLines(container.node.Kind)
+	for !matchedLeaf {
+		p.findNextNonspace()
+		if !p.indented && !reMaybeSpecial.MatchString(p.line[p.nextNonspace:]) {
+			p.advanceNextNonspace()
+			break
+		}
+
+		started := false
+		for _, start := range blockStarts {
+			switch start(p, container) {
+			case startContainer:
+				container = p.tip
+				started = true
+			case startLeaf:
+				container = p.tip
+				started = true
+				matchedLeaf = true
+			}
+			if started {
+				break
+			}
+		}
+		if !started {
+			p.advanceNextNonspace()
+			break
+		}
+	}
+
+	if !p.allClosed && !p.blank && p.tip.node.Kind == KindParagraph {
+		// Lazy continuation of a paragraph whose containers did not match the line.
+		p.addLine()
+		return
+	}
+
+	p.closeUnmatchedBlocks()
+	switch {
+	case acceptsLines(container.node.Kind):
+		p.addLine()
+		if t := container.htmlType; container.node.Kind == KindHTMLBlock && t >= 1 && t <= 5 && reHTMLBlockCloseTypes[t].MatchString(p.line[p.offset:]) {
+			p.finalize(container, n)
+		}
+	case p.offset < len(p.line) && !p.blank:
+		p.addChild(KindParagraph, p.offset)
+		p.advanceNextNonspace()
+		p.addLine()
+	}
+}
+
+// findNextNonspace finds the next character of the line that is not a space or a tab, and the
+// indentation of that character relative to the current column.
+func (p *blockParser) findNextNonspace() {
+	i := p.offset
+	cols := p.column
+	for i < len(p.line) {
+		if c := p.line[i]; c == ' ' {
+			i++
+			cols++
+		} else if c == '\t' {
+			i++
+			cols += 4 - cols%4
+		} else {
+			break
+		}
+	}
+	p.blank = i == len(p.line)
+	p.nextNonspace = i
+	p.nextNonspaceColumn = cols
+	p.indent = cols - p.column
+	p.indented = p.indent >= codeIndent
+}
+
+func (p *blockParser) advanceNextNonspace() {
+	p.offset = p.nextNonspace
+	p.column = p.nextNonspaceColumn
+	p.partiallyConsumedTab = false
+}
+
+// advanceOffset moves forward in the line by count characters or, if columns is true, by count
+// columns, in which case a tab may be consumed partially.
+func (p *blockParser) advanceOffset(count int, columns bool) {
+	for count > 0 && p.offset < len(p.line) {
+		if p.line[p.offset] != '\t' {
+			p.partiallyConsumedTab = false
+			p.offset++
+			p.column++
+			count--
+			continue
+		}
+
+		charsToTab := 4 - p.column%4
+		if columns {
+			p.partiallyConsumedTab = charsToTab > count
+			charsToAdvance := min(charsToTab, count)
+			p.column += charsToAdvance
+			if !p.partiallyConsumedTab {
+				p.offset++
+			}
+			count -= charsToAdvance
+		} else {
+			p.partiallyConsumedTab = false
+			p.column += charsToTab
+			p.offset++
+			count--
+		}
+	}
+}
+
+// addLine adds the rest of the current line to the tip.
+func (p *blockParser) addLine() {
+	text := p.line[p.offset:]
+	if p.partiallyConsumedTab {
+		// The columns of the tab that were not consumed are part of the content.
+		p.offset++
+		text = strings.Repeat(" ", 4-p.column%4) + p.line[p.offset:]
+	}
+	p.tip.lines = append(p.tip.lines, line{
+		start: p.lineStart + p.offset,
+		end:   p.lineStart + len(p.line),
+		text:  text,
+	})
+}
+
+// addChild adds a block starting at the given offset of the current line to the tip, closing the
+// blocks that cannot contain it.
+func (p *blockParser) addChild(kind Kind, offset int) *block {
+	for !canContain(p.tip.node.Kind, kind) {
+		p.finalize(p.tip, p.lineNumber-1)
+	}
+
+	b := &block{
+		node:      &Node{Kind: kind, Start: p.lineStart + offset},
+		parent:    p.tip,
+		open:      true,
+		startLine: p.lineNumber,
+	}
+	p.tip.children = append(p.tip.children, b)
+	p.tip.node.Children = append(p.tip.node.Children, b.node)
+	p.tip = b
+	return b
+}
+
+// closeUnmatchedBlocks closes the blocks that were open before the current line but did not match it.
+func (p *blockParser) closeUnmatchedBlocks() {
+	if p.allClosed {
+		return
+	}
+	for p.oldTip != p.lastMatched {
+		parent := p.oldTip.parent
+		p.finalize(p.oldTip, p.lineNumber-1)
+		p.oldTip = parent
+	}
+	p.allClosed = true
+}
+
+// finalize closes a block whose last line is the given one.
+func (p *blockParser) finalize(b *block, lineNumber int) {
+	b.open = false
+	b.endLine = max(lineNumber, b.startLine)
+	b.node.End = max(p.lineEnd(b.endLine), b.node.Start)
+
+	switch b.node.Kind {
+	case KindParagraph:
+		p.extractDefinitions(b)
+		if len(b.lines) == 0 {
+			p.unlink(b)
+		} else {
+			p.leaves = append(p.leaves, b)
+		}
+	case KindHeading:
+		p.leaves = append(p.leaves, b)
+	case KindCodeBlock:
+		finalizeCodeBlock(b)
+	case KindHTMLBlock:
+		texts := make([]string, len(b.lines))
+		for i, l := range b.lines {
+			texts[i] = l.text
+		}
+		b.node.Literal = strings.TrimRight(strings.Join(texts, "\n"), "\n")
+	case KindListItem:
+		if last := b.lastChild(); last != nil {
+			b.endLine = last.endLine
+			b.node.End = last.node.End
+		} else {
+			b.endLine = b.startLine
+			b.node.End = min(b.node.Start+b.list.padding, p.lineEnd(b.startLine))
+		}
+	case KindFootnoteDefinition:
+		if last := b.lastChild(); last != nil {
+			b.endLine = last.endLine
+			b.node.End = last.node.End
+		} else {
+			b.endLine = b.startLine
+			b.node.End = p.lineEnd(b.startLine)
+		}
+	case KindList:
+		b.node.Tight = true
+		if separatedByBlankLine(b.children) {
+			b.node.Tight = false
+		}
+		for _, item := range b.children {
+			if separatedByBlankLine(item.children) {
+				b.node.Tight = false
+			}
+		}
+		if last := b.lastChild(); last != nil {
+			b.endLine = last.endLine
+			b.node.End = last.node.End
+		}
+	}
+	p.tip = b.parent
+}
+
+// separatedByBlankLine reports whether any of the blocks is followed by a blank line and then by
+// another of the blocks.
+func separatedByBlankLine(blocks []*block) bool {
+	for i := 0; i+1 < len(blocks); i++ {
+		if blocks[i].endLine != blocks[i+1].startLine-1 {
+			return true
+		}
+	}
+	return false
+}
+
+func finalizeCodeBlock(b *block) {
+	lines := b.lines
+	if b.fenced {
+		if len(lines) > 0 {
+			b.node.Info = unescapeString(strings.TrimSpace(lines[0].text))
+			lines = lines[1:]
+		}
+	} else {
+		for len(lines) > 0 && reTrailingBlankLines.MatchString(lines[len(lines)-1].text) {
+			lines = lines[:len(lines)-1]
+		}
+		if len(lines) > 0 {
+			b.node.End = lines[len(lines)-1].end
+		}
+	}
+
+	var sb strings.Builder
+	for _, l := range lines {
+		sb.WriteString(l.text)
+		sb.WriteByte('\n')
+	}
+	b.node.Literal = sb.String()
+}
+
+// unlink removes a block from its parent.
+func (p *blockParser) unlink(b *block) {
+	parent := b.parent
+	for i, child := range parent.children {
+		if child == b {
+			parent.children = append(parent.children[:i], parent.children[i+1:]...)
+			parent.node.Children = append(parent.node.Children[:i], parent.node.Children[i+1:]...)
+			return
+		}
+	}
+}
+
+// extractDefinitions moves the link reference definitions at the start of a paragraph out of it,
+// as siblings preceding it.
+func (p *blockParser) extractDefinitions(b *block) {
+	if len(b.lines) == 0 || !strings.HasPrefix(b.lines[0].text, "[") {
+		return
+	}
+
+	s := newSubject(b.lines)
+	ip := &inlineParser{subject: s, refs: p.refs}
+	var definitions []*block
+	for ip.pos < len(s.text) && s.text[ip.pos] == '[' {
+		start := ip.pos
+		node, ok := ip.parseReference()
+		if !ok {
+			break
+		}
+		definitions = append(definitions, &block{
+			node:      node,
+			parent:    b.parent,
+			startLine: b.startLine + strings.Count(s.text[:start], "\n"),
+			endLine:   b.startLine + strings.Count(strings.TrimRight(s.text[:ip.pos], "\n"), "\n"),
+		})
+	}
+	if len(definitions) == 0 {
+		return
+	}
+
+	// Definitions always end at the end of a line, so whole lines are consumed.
+	used := strings.Count(s.text[:ip.pos], "\n")
+	if ip.pos == len(s.text) {
+		used = len(b.lines)
+	}
+	b.lines = b.lines[used:]
+	b.startLine += used
+	if len(b.lines) > 0 {
+		b.node.Start = b.lines[0].start
+	}
+
+	parent := b.parent
+	for i, child := range parent.children {
+		if child != b {
+			continue
+		}
+		blocks := make([]*block, 0, len(parent.children)+len(definitions))
+		blocks = append(blocks, parent.children[:i]...)
+		blocks = append(blocks, definitions...)
+		blocks = append(blocks, parent.children[i:]...)
+		parent.children = blocks
+
+		nodes := make([]*Node, 0, len(blocks))
+		for _, child := range blocks {
+			nodes = append(nodes, child.node)
+		}
+		parent.node.Children = nodes
+		break
+	}
+}
+
+func acceptsLines(kind Kind) bool {
+	return kind == KindParagraph || kind == KindCodeBlock || kind == KindHTMLBlock
+}
+
+func canContain(parent, child Kind) bool {
+	switch parent {
+	case KindDocument, KindBlockQuote, KindListItem, KindFootnoteDefinition:
+		return child != KindListItem
+	case KindList:
+		return child == KindListItem
+	default:
+		return false
+	}
+}
+
+// Results of continueBlock.
+const (
+	continueMatched = iota
+	continueFailed
+	// continueConsumed means that the line was fully handled by the block.
+	continueConsumed
+)
+
+// continueBlock reports whether the current line continues an open block, consuming the markers
+// required by the block (e.g. `>` for block quotes).
+func (p *blockParser) continueBlock(b *block) int {
+	switch b.node.Kind {
+	case KindBlockQuote:
+		if p.indented || p.nextNonspace >= len(p.line) || p.line[p.nextNonspace] != '>' {
+			return continueFailed
+		}
+		p.advanceNextNonspace()
+		p.advanceOffset(1, false)
+		if p.offset < len(p.line) && isSpaceOrTab(p.line[p.offset]) {
+			p.advanceOffset(1, true)
+		}
+	case KindListItem:
+		switch {
+		case p.blank:
+			if len(b.children) == 0 {
+				// A list item can begin with at most one blank line.
+				return continueFailed
+			}
+			p.advanceNextNonspace()
+		case p.indent >= b.list.markerOffset+b.list.padding:
+			p.advanceOffset(b.list.markerOffset+b.list.padding, true)
+		default:
+			return continueFailed
+		}
+	case KindFootnoteDefinition:
+		switch {
+		case p.blank:
+			p.advanceNextNonspace()
+		case p.indent >= codeIndent:
+			p.advanceOffset(codeIndent, true)
+		default:
+			return continueFailed
+		}
+	case KindHeading, KindThematicBreak:
+		return continueFailed
+	case KindCodeBlock:
+		if !b.fenced {
+			switch {
+			case p.indent >= codeIndent:
+				p.advanceOffset(codeIndent, true)
+			case p.blank:
+				p.advanceNextNonspace()
+			default:
+				return continueFailed
+			}
+			return continueMatched
+		}
+
+		rest := p.line[p.nextNonspace:]
+		if p.indent <= 3 && len(rest) > 0 && rest[0] == b.fenceChar {
+			if n := fenceLength(rest); n >= b.fenceLength && strings.Trim(rest[n:], " \t") == "" {
+				p.finalize(b, p.lineNumber)
+				return continueConsumed
+			}
+		}
+		// The indentation of the opening fence is removed from the content lines.
+		for i := b.fenceOffset; i > 0 && p.offset < len(p.line) && isSpaceOrTab(p.line[p.offset]); i-- {
+			p.advanceOffset(1, true)
+		}
+	case KindHTMLBlock:
+		if p.blank && (b.htmlType == 6 || b.htmlType == 7) {
+			return continueFailed
+		}
+	case KindParagraph:
+		if p.blank {
+			return continueFailed
+		}
+	}
+	return continueMatched
+}
+
+// Results of the block starts.
+const (
+	startNone = iota
+	// startContainer means that a container block was started, which may contain more blocks.
+	startContainer
+	// startLeaf means that a leaf block was started, which takes the rest of the line.
+	startLeaf
+)
+
+// blockStarts are tried in order on the text of a line that did not continue a leaf block.
+var blockStarts = []func(p *blockParser, container *block) int{
+	startBlockQuote,
+	startFootnoteDefinition,
+	startATXHeading,
+	startFencedCodeBlock,
+	startHTMLBlock,
+	startSetextHeading,
+	startThematicBreak,
+	startListItem,
+	startIndentedCodeBlock,
+}
+
+func startBlockQuote(p *blockParser, _ *block) int {
+	if p.indented || p.line[p.nextNonspace] != '>' {
+		return startNone
+	}
+	p.advanceNextNonspace()
+	p.advanceOffset(1, false)
+	if p.offset < len(p.line) && isSpaceOrTab(p.line[p.offset]) {
+		p.advanceOffset(1, true)
+	}
+	p.closeUnmatchedBlocks()
+	p.addChild(KindBlockQuote, p.nextNonspace)
+	return startContainer
+}
+
+// startFootnoteDefinition starts a footnote. The first definition of a label takes precedence, but
+// the others are still parsed as footnotes.
+//
+// https://github.github.com/gfm/#footnotes
+func startFootnoteDefinition(p *blockParser, _ *block) int {
+	if p.indented {
+		return startNone
+	}
+	m := reFootnoteDefinition.FindStringSubmatch(p.line[p.nextNonspace:])
+	if m == nil {
+		return startNone
+	}
+
+	p.closeUnmatchedBlocks()
+	footnote := p.addChild(KindFootnoteDefinition, p.nextNonspace)
+	footnote.node.Literal = m[1]
+	footnote.node.Label = normalizeFootnote(m[1])
+	p.footnotes[footnote.node.Label] = true
+	p.advanceNextNonspace()
+	p.advanceOffset(len(m[0]), false)
+	return startContainer
+}
+
+func startATXHeading(p *blockParser, _ *block) int {
+	if p.indented {
+		return startNone
+	}
+	marker := reATXHeadingMarker.FindString(p.line[p.nextNonspace:])
+	if marker == "" {
+		return startNone
+	}
+	p.advanceNextNonspace()
+	p.advanceOffset(len(marker), false)
+	p.closeUnmatchedBlocks()
+
+	heading := p.addChild(KindHeading, p.nextNonspace)
+	heading.node.Level = len(strings.TrimRight(marker, " \t"))
+	content := p.line[p.offset:]
+	content = reATXClosingEmpty.ReplaceAllString(content, "")
+	content = reATXClosingSequence.ReplaceAllString(content, "")
+	heading.lines = []line{{start: p.lineStart + p.offset, end: p.lineStart + p.offset + len(content), text: content}}
+	p.advanceOffset(len(p.line)-p.offset, false)
+	return startLeaf
+}
+
+func startFencedCodeBlock(p *blockParser, _ *block) int {
+	if p.indented {
+		return startNone
+	}
+	rest := p.line[p.nextNonspace:]
+	n := fenceLength(rest)
+	if n < 3 || rest[0] == '`' && strings.Contains(rest[n:], "`") {
+		return startNone
+	}
+	p.closeUnmatchedBlocks()
+
+	code := p.addChild(KindCodeBlock, p.nextNonspace)
+	code.fenced = true
+	code.fenceChar = rest[0]
+	code.fenceLength = n
+	code.fenceOffset = p.indent
+	p.advanceNextNonspace()
+	p.advanceOffset(n, false)
+	return startLeaf
+}
+
+// fenceLength returns the length of the run of backticks or tildes at the start of the text.
+func fenceLength(text string) int {
+	if len(text) == 0 || text[0] != '`' && text[0] != '~' {
+		return 0
+	}
+	n := 1
+	for n < len(text) && text[n] == text[0] {
+		n++
+	}
+	return n
+}
+
+func startHTMLBlock(p *blockParser, container *block) int {
+	if p.indented || p.line[p.nextNonspace] != '<' {
+		return startNone
+	}
+	rest := p.line[p.nextNonspace:]
+	for t := 1; t <= 7; t++ {
+		if !reHTMLBlockOpenTypes[t].MatchString(rest) {
+			continue
+		}
+		// Only the first six types may interrupt a paragraph.
+		lazyParagraph := !p.allClosed && !p.blank && p.tip.node.Kind == KindParagraph
+		if t == 7 && (container.node.Kind == KindParagraph || lazyParagraph) {
+			continue
+		}
+		p.closeUnmatchedBlocks()
+		html := p.addChild(KindHTMLBlock, p.offset)
+		html.htmlType = t
+		return startLeaf
+	}
+	return startNone
+}
+
+func startSetextHeading(p *blockParser, container *block) int {
+	if p.indented || container.node.Kind != KindParagraph {
+		return startNone
+	}
+	underline := reSetextHeadingLine.FindString(p.line[p.nextNonspace:])
+	if underline == "" {
+		return startNone
+	}
+	p.closeUnmatchedBlocks()
+	p.extractDefinitions(container)
+	if len(container.lines) == 0 {
+		return startNone
+	}
+
+	container.node.Kind = KindHeading
+	container.node.Level = 2
+	if underline[0] == '=' {
+		container.node.Level = 1
+	}
+	p.advanceOffset(len(p.line)-p.offset, false)
+	return startLeaf
+}
+
+func startThematicBreak(p *blockParser, _ *block) int {
+	if p.indented || !isThematicBreak(p.line[p.nextNonspace:]) {
+		return startNone
+	}
+	p.closeUnmatchedBlocks()
+	p.addChild(KindThematicBreak, p.nextNonspace)
+	p.advanceOffset(len(p.line)-p.offset, false)
+	return startLeaf
+}
+
+// isThematicBreak reports whether the text is made of three or more `*`, `-` or `_`, optionally
+// separated by spaces or tabs.
+func isThematicBreak(text string) bool {
+	if len(text) == 0 || text[0] != '*' && text[0] != '-' && text[0] != '_' {
+		return false
+	}
+	count := 0
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case text[0]:
+			count++
+		case ' ', '\t':
+		default:
+			return false
+		}
+	}
+	return count >= 3
+}
+
+func startListItem(p *blockParser, container *block) int {
+	if p.indented && container.node.Kind != KindList {
+		return startNone
+	}
+	data, ok := p.parseListMarker(container)
+	if !ok {
+		return startNone
+	}
+	p.closeUnmatchedBlocks()
+
+	if p.tip.node.Kind != KindList || !listsMatch(p.tip.list, data) {
+		list := p.addChild(KindList, p.nextNonspace)
+		list.list = data
+		list.node.Ordered = data.ordered
+	}
+	item := p.addChild(KindListItem, p.nextNonspace)
+	item.list = data
+	return startContainer
+}
+
+// parseListMarker parses the marker of a list item and consumes it along with the spaces that
+// follow it.
+func (p *blockParser) parseListMarker(container *block) (listData, bool) {
+	if p.indent >= codeIndent {
+		return listData{}, false
+	}
+
+	rest := p.line[p.nextNonspace:]
+	data := listData{markerOffset: p.indent}
+	var marker string
+	if m := reBulletListMarker.FindString(rest); m != "" {
+		marker = m
+		data.bulletChar = m[0]
+	} else if m := reOrderedListMarker.FindStringSubmatch(rest); m != nil && (container.node.Kind != KindParagraph || m[1] == "1") {
+		// Only lists starting with 1 may interrupt a paragraph.
+		marker = m[0]
+		data.ordered = true
+		data.delimiter = m[2][0]
+	} else {
+		return listData{}, false
+	}
+
+	after := rest[len(marker):]
+	if len(after) > 0 && !isSpaceOrTab(after[0]) {
+		return listData{}, false
+	}
+	if container.node.Kind == KindParagraph && !reNonSpace.MatchString(after) {
+		// An empty list item cannot interrupt a paragraph.
+		return listData{}, false
+	}
+
+	p.advanceNextNonspace()
+	p.advanceOffset(len(marker), true)
+	spacesStartColumn := p.column
+	spacesStartOffset := p.offset
+	for {
+		p.advanceOffset(1, true)
+		if p.column-spacesStartColumn >= 5 || p.offset >= len(p.line) || !isSpaceOrTab(p.line[p.offset]) {
+			break
+		}
+	}
+	blankItem := p.offset >= len(p.line)
+	spacesAfterMarker := p.column - spacesStartColumn
+	if spacesAfterMarker >= 5 || spacesAfterMarker < 1 || blankItem {
+		// The content is an indented code block or the item is empty: only one space belongs to
+		// the marker.
+		data.padding = len(marker) + 1
+		p.column = spacesStartColumn
+		p.offset = spacesStartOffset
+		if p.offset < len(p.line) && isSpaceOrTab(p.line[p.offset]) {
+			p.advanceOffset(1, true)
+		}
+	} else {
+		data.padding = len(marker) + spacesAfterMarker
+	}
+	return data, true
+}
+
+// listsMatch reports whether two list items belong to the same list.
+func listsMatch(a, b listData) bool {
+	return a.ordered == b.ordered && a.delimiter == b.delimiter && a.bulletChar == b.bulletChar
+}
+
+func startIndentedCodeBlock(p *blockParser, _ *block) int {
+	if !p.indented || p.tip.node.Kind == KindParagraph || p.blank {
+		return startNone
+	}
+	p.advanceOffset(codeIndent, true)
+	p.closeUnmatchedBlocks()
+	p.addChild(KindCodeBlock, p.offset)
+	return startLeaf
+}
+
+func isSpaceOrTab(c byte) bool {
+	return c == ' ' || c == '\t'
+}
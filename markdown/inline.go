@@ -0,0 +1,967 @@
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	tagName            = `[A-Za-z][A-Za-z0-9-]*`
+	attributeName      = `[a-zA-Z_:][a-zA-Z0-9:._-]*`
+	unquotedValue      = "[^\"'=<>`\\x00-\\x20]+"
+	singleQuotedValue  = `'[^']*'`
+	doubleQuotedValue  = `"[^"]*"`
+	attributeValue     = `(?:` + unquotedValue + `|` + singleQuotedValue + `|` + doubleQuotedValue + `)`
+	attributeValueSpec = `(?:\s*=\s*` + attributeValue + `)`
+	attribute          = `(?:\s+` + attributeName + attributeValueSpec + `?)`
+	openTag            = `<` + tagName + attribute + `*\s*/?>`
+	closeTag           = `</` + tagName + `\s*[>]`
+	htmlComment        = `<!-->|<!--->|<!--[\s\S]*?-->`
+	processingInstr    = `[<][?][\s\S]*?[?][>]`
+	declaration        = `<![A-Za-z]+[^>]*>`
+	cdata              = `<!\[CDATA\[[\s\S]*?\]\]>`
+	htmlTag            = `(?:` + openTag + `|` + closeTag + `|` + htmlComment + `|` + processingInstr + `|` + declaration + `|` + cdata + `)`
+	escapable          = "[!\"#$%&'()*+,./:;<=>?@[\\\\\\]^_`{|}~-]"
+	entity             = `&(?:#[xX][a-fA-F0-9]{1,6}|#[0-9]{1,7}|[a-zA-Z][a-zA-Z0-9]{1,31});`
+)
+
+var (
+	reHTMLTag               = regexp.MustCompile(`(?i)^` + htmlTag)
+	reEntityHere            = regexp.MustCompile(`^` + entity)
+	reEntityOrEscapedChar   = regexp.MustCompile(`\\` + escapable + `|` + entity)
+	reMain                  = regexp.MustCompile("^[^\n`\\[\\]\\\\!<&*_]+")
+	reLinkDestinationBraces = regexp.MustCompile(`^<(?:[^<>\n\\\x00]|\\.)*>`)
+	reLinkTitle             = regexp.MustCompile(`^(?:"(?:\\[\s\S]|[^\\"\x00])*"|'(?:\\[\s\S]|[^\\'\x00])*'|\((?:\\[\s\S]|[^\\()\x00])*\))`)
+	reSpnl                  = regexp.MustCompile(`^[ \t]*(?:\n[ \t]*)?`)
+	reSpaceAtEndOfLine      = regexp.MustCompile(`^[ \t]*(?:\n|$)`)
+	reInitialSpace          = regexp.MustCompile(`^[ \t]*`)
+	reEmailAutolink         = regexp.MustCompile(`^<([a-zA-Z0-9.!#$%&'*+/=?^_` + "`" + `{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*)>`)
+	reAutolink              = regexp.MustCompile(`^<[A-Za-z][A-Za-z0-9.+-]{1,31}:[^<>\x00-\x20]*>`)
+	reWhitespaceRun         = regexp.MustCompile(`[ \t\r\n]+`)
+	reEscapableChar         = regexp.MustCompile(`^` + escapable)
+	reFinalSpace            = regexp.MustCompile(` *$`)
+)
+
+// reference is a link reference definition.
+type reference struct {
+	destination string
+	title       string
+}
+
+// subject is the inline content of a leaf block, with its lines joined by `\n`, and the position
+// of each of its lines in the source.
+type subject struct {
+	text  string
+	lines []subjectLine
+}
+
+type subjectLine struct {
+	// at is the index of the line in the text and start its offset in the source.
+	at, start int
+}
+
+func newSubject(lines []line) *subject {
+	s := &subject{lines: make([]subjectLine, 0, len(lines))}
+	var sb strings.Builder
+	for i, l := range lines {
+		if i > 0 {
+			sb.WriteByte('\n')
+		}
+		s.lines = append(s.lines, subjectLine{at: sb.Len(), start: l.start})
+		sb.WriteString(l.text)
+	}
+
+	text := sb.String()
+	if trimmed := strings.TrimLeft(text, " \t\n"); len(trimmed) < len(text) && len(s.lines) > 0 {
+		lead := len(text) - len(trimmed)
+		for i := range s.lines {
+			s.lines[i].at -= lead
+		}
+		s.lines[0].start -= s.lines[0].at
+		s.lines[0].at = 0
+		text = trimmed
+	}
+	s.text = strings.TrimRight(text, " \t\n")
+	return s
+}
+
+// source returns the offset in the source of the given index of the text.
+func (s *subject) source(i int) int {
+	k := sort.Search(len(s.lines), func(k int) bool { return s.lines[k].at > i }) - 1
+	k = max(k, 0)
+	return s.lines[k].start + i - s.lines[k].at
+}
+
+// span returns the offsets in the source of the text between the given indexes.
+func (s *subject) span(start, end int) (int, int) {
+	if end <= start {
+		return s.source(start), s.source(start)
+	}
+	return s.source(start), s.source(end-1) + 1
+}
+
+// inline is a node being parsed. Inlines are linked lists, as the processing of emphasis and links
+// moves runs of nodes around.
+type inline struct {
+	node        *Node
+	parent      *inline
+	prev, next  *inline
+	first, last *inline
+}
+
+func (in *inline) appendChild(child *inline) {
+	child.unlink()
+	child.parent = in
+	if in.last != nil {
+		in.last.next = child
+		child.prev = in.last
+	} else {
+		in.first = child
+	}
+	in.last = child
+}
+
+func (in *inline) unlink() {
+	if in.prev != nil {
+		in.prev.next = in.next
+	} else if in.parent != nil {
+		in.parent.first = in.next
+	}
+	if in.next != nil {
+		in.next.prev = in.prev
+	} else if in.parent != nil {
+		in.parent.last = in.prev
+	}
+	in.parent, in.prev, in.next = nil, nil, nil
+}
+
+func (in *inline) insertAfter(sibling *inline) {
+	sibling.unlink()
+	sibling.next = in.next
+	if sibling.next != nil {
+		sibling.next.prev = sibling
+	}
+	sibling.prev = in
+	in.next = sibling
+	sibling.parent = in.parent
+	if sibling.next == nil && sibling.parent != nil {
+		sibling.parent.last = sibling
+	}
+}
+
+// build converts the children of the inline into nodes, merging adjacent runs of text.
+func (in *inline) build() []*Node {
+	var nodes []*Node
+	// run holds the literals of the text nodes merged into the last node.
+	var run []string
+	flush := func() {
+		if len(run) > 1 {
+			nodes[len(nodes)-1].Literal = strings.Join(run, "")
+		}
+		run = run[:0]
+	}
+	for child := in.first; child != nil; child = child.next {
+		node := child.node
+		if node.Kind == KindText && node.Literal == "" && node.Start == node.End {
+			continue
+		}
+		if n := len(nodes); n > 0 && node.Kind == KindText && nodes[n-1].Kind == KindText && nodes[n-1].End == node.Start {
+			run = append(run, node.Literal)
+			nodes[n-1].End = node.End
+			continue
+		}
+		flush()
+		node.Children = child.build()
+		nodes = append(nodes, node)
+		if node.Kind == KindText {
+			run = append(run, node.Literal)
+		}
+	}
+	flush()
+	return nodes
+}
+
+// delimiter is a run of `*` or `_` that may open or close emphasis.
+type delimiter struct {
+	char       byte
+	count      int
+	origCount  int
+	node       *inline
+	prev, next *delimiter
+	canOpen    bool
+	canClose   bool
+}
+
+// bracket is a `[` or `![` that may open a link or an image.
+type bracket struct {
+	node          *inline
+	prev          *bracket
+	prevDelimiter *delimiter
+	// index is the position of the `[` in the subject.
+	index        int
+	image        bool
+	active       bool
+	bracketAfter bool
+}
+
+// inlineParser parses the inline content of a leaf block.
+//
+// https://spec.commonmark.org/0.31.2/#inlines
+type inlineParser struct {
+	subject    *subject
+	pos        int
+	refs       map[string]reference
+	footnotes  map[string]bool
+	root       *inline
+	delimiters *delimiter
+	brackets   *bracket
+}
+
+// parseInlines parses the content of a paragraph or heading.
+func parseInlines(lines []line, refs map[string]reference, footnotes map[string]bool) []*Node {
+	p := &inlineParser{
+		subject:   newSubject(lines),
+		refs:      refs,
+		footnotes: footnotes,
+		root:      &inline{node: &Node{}},
+	}
+	for p.parseInline() {
+	}
+	p.processEmphasis(nil)
+	return p.root.build()
+}
+
+func (p *inlineParser) peek() byte {
+	if p.pos < len(p.subject.text) {
+		return p.subject.text[p.pos]
+	}
+	return 0
+}
+
+// match consumes the text matched by the regexp at the current position, if any.
+func (p *inlineParser) match(re *regexp.Regexp) (string, bool) {
+	m := re.FindString(p.subject.text[p.pos:])
+	if m == "" && !re.MatchString(p.subject.text[p.pos:]) {
+		return "", false
+	}
+	p.pos += len(m)
+	return m, true
+}
+
+// newNode creates a node spanning the given indexes of the subject.
+func (p *inlineParser) newNode(kind Kind, start, end int) *inline {
+	nodeStart, nodeEnd := p.subject.span(start, end)
+	return &inline{node: &Node{Kind: kind, Start: nodeStart, End: nodeEnd}}
+}
+
+// text appends a text node spanning the given indexes of the subject.
+func (p *inlineParser) text(start, end int, literal string) *inline {
+	node := p.newNode(KindText, start, end)
+	node.node.Literal = literal
+	p.root.appendChild(node)
+	return node
+}
+
+// parseInline parses the next inline and reports whether there is anything left to parse.
+func (p *inlineParser) parseInline() bool {
+	if p.pos >= len(p.subject.text) {
+		return false
+	}
+
+	var handled bool
+	switch c := p.peek(); c {
+	case '\n':
+		handled = p.parseNewline()
+	case '\\':
+		handled = p.parseBackslash()
+	case '`':
+		handled = p.parseBackticks()
+	case '*', '_':
+		handled = p.handleDelim(c)
+	case '[':
+		handled = p.parseOpenBracket()
+	case '!':
+		handled = p.parseBang()
+	case ']':
+		handled = p.parseCloseBracket()
+	case '<':
+		handled = p.parseAutolink() || p.parseHTMLTag()
+	case '&':
+		handled = p.parseEntity()
+	default:
+		handled = p.parseString()
+	}
+	if !handled {
+		p.pos++
+		p.text(p.pos-1, p.pos, p.subject.text[p.pos-1:p.pos])
+	}
+	return true
+}
+
+func (p *inlineParser) parseNewline() bool {
+	start := p.pos
+	p.pos++
+	kind := KindSoftBreak
+	if last := p.root.last; last != nil && last.node.Kind == KindText && strings.HasSuffix(last.node.Literal, " ") {
+		if strings.HasSuffix(last.node.Literal, "  ") {
+			kind = KindHardBreak
+		}
+		trimmed := reFinalSpace.ReplaceAllString(last.node.Literal, "")
+		removed := len(last.node.Literal) - len(trimmed)
+		last.node.Literal = trimmed
+		last.node.End -= removed
+		start -= removed
+	}
+	p.root.appendChild(p.newNode(kind, start, p.pos))
+	// Spaces at the start of the next line are ignored.
+	p.match(reInitialSpace)
+	return true
+}
+
+func (p *inlineParser) parseBackslash() bool {
+	start := p.pos
+	p.pos++
+	switch {
+	case p.peek() == '\n':
+		p.pos++
+		p.root.appendChild(p.newNode(KindHardBreak, start, p.pos))
+	case reEscapableChar.MatchString(p.subject.text[p.pos:]):
+		p.pos++
+		p.text(start, p.pos, p.subject.text[p.pos-1:p.pos])
+	default:
+		p.text(start, p.pos, `\`)
+	}
+	return true
+}
+
+func (p *inlineParser) parseBackticks() bool {
+	start := p.pos
+	ticks := fenceLength(p.subject.text[p.pos:])
+	p.pos += ticks
+	afterOpenTicks := p.pos
+
+	for {
+		i := strings.IndexByte(p.subject.text[p.pos:], '`')
+		if i < 0 {
+			break
+		}
+		closeStart := p.pos + i
+		n := fenceLength(p.subject.text[closeStart:])
+		p.pos = closeStart + n
+		if n != ticks {
+			continue
+		}
+
+		contents := strings.ReplaceAll(p.subject.text[afterOpenTicks:closeStart], "\n", " ")
+		if len(contents) > 2 && contents[0] == ' ' && contents[len(contents)-1] == ' ' && strings.Trim(contents, " ") != "" {
+			contents = contents[1 : len(contents)-1]
+		}
+		code := p.newNode(KindCodeSpan, start, p.pos)
+		code.node.Literal = contents
+		p.root.appendChild(code)
+		return true
+	}
+
+	// Without a matching closing run, the backticks are literal.
+	p.pos = afterOpenTicks
+	p.text(start, p.pos, p.subject.text[start:p.pos])
+	return true
+}
+
+// scanDelims counts the delimiters at the current position and reports whether the run can open
+// or close emphasis.
+//
+// https://spec.commonmark.org/0.31.2/#left-flanking-delimiter-run
+func (p *inlineParser) scanDelims(c byte) (count int, canOpen, canClose bool) {
+	text := p.subject.text
+	start := p.pos
+	end := start
+	for end < len(text) && text[end] == c {
+		end++
+	}
+	count = end - start
+
+	before, after := '\n', '\n'
+	if start > 0 {
+		before, _ = utf8.DecodeLastRuneInString(text[:start])
+	}
+	if end < len(text) {
+		after, _ = utf8.DecodeRuneInString(text[end:])
+	}
+
+	afterIsWhitespace := isUnicodeWhitespace(after)
+	afterIsPunctuation := isUnicodePunctuation(after)
+	beforeIsWhitespace := isUnicodeWhitespace(before)
+	beforeIsPunctuation := isUnicodePunctuation(before)
+
+	leftFlanking := !afterIsWhitespace && (!afterIsPunctuation || beforeIsWhitespace || beforeIsPunctuation)
+	rightFlanking := !beforeIsWhitespace && (!beforeIsPunctuation || afterIsWhitespace || afterIsPunctuation)
+	if c == '_' {
+		canOpen = leftFlanking && (!rightFlanking || beforeIsPunctuation)
+		canClose = rightFlanking && (!leftFlanking || afterIsPunctuation)
+	} else {
+		canOpen = leftFlanking
+		canClose = rightFlanking
+	}
+	return count, canOpen, canClose
+}
+
+func isUnicodeWhitespace(r rune) bool {
+	return unicode.Is(unicode.Zs, r) || strings.ContainsRune("\t\n\f\r", r)
+}
+
+func isUnicodePunctuation(r rune) bool {
+	return unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
+
+func (p *inlineParser) handleDelim(c byte) bool {
+	count, canOpen, canClose := p.scanDelims(c)
+	start := p.pos
+	p.pos += count
+	node := p.text(start, p.pos, p.subject.text[start:p.pos])
+
+	if canOpen || canClose {
+		d := &delimiter{
+			char:      c,
+			count:     count,
+			origCount: count,
+			node:      node,
+			prev:      p.delimiters,
+			canOpen:   canOpen,
+			canClose:  canClose,
+		}
+		if d.prev != nil {
+			d.prev.next = d
+		}
+		p.delimiters = d
+	}
+	return true
+}
+
+func (p *inlineParser) removeDelimiter(d *delimiter) {
+	if d.prev != nil {
+		d.prev.next = d.next
+	}
+	if d.next == nil {
+		p.delimiters = d.prev
+	} else {
+		d.next.prev = d.prev
+	}
+}
+
+// processEmphasis matches the delimiters above the bottom of the stack into emphasis.
+//
+// https://spec.commonmark.org/0.31.2/#process-emphasis
+func (p *inlineParser) processEmphasis(bottom *delimiter) {
+	// openersBottom holds, for each type of closer, the delimiter below which no opener matches.
+	var openersBottom [12]*delimiter
+	for i := range openersBottom {
+		openersBottom[i] = bottom
+	}
+
+	closer := p.delimiters
+	for closer != nil && closer.prev != bottom {
+		closer = closer.prev
+	}
+
+	for closer != nil {
+		if !closer.canClose {
+			closer = closer.next
+			continue
+		}
+
+		index := closer.origCount % 3
+		if closer.canOpen {
+			index += 3
+		}
+		if closer.char == '*' {
+			index += 6
+		}
+
+		opener := closer.prev
+		found := false
+		for opener != nil && opener != bottom && opener != openersBottom[index] {
+			oddMatch := (closer.canOpen || opener.canClose) && closer.origCount%3 != 0 && (opener.origCount+closer.origCount)%3 == 0
+			if opener.char == closer.char && opener.canOpen && !oddMatch {
+				found = true
+				break
+			}
+			opener = opener.prev
+		}
+
+		oldCloser := closer
+		if found {
+			used := 1
+			if closer.count >= 2 && opener.count >= 2 {
+				used = 2
+			}
+			openerNode, closerNode := opener.node, closer.node
+			opener.count -= used
+			closer.count -= used
+			openerNode.node.Literal = openerNode.node.Literal[:len(openerNode.node.Literal)-used]
+			closerNode.node.Literal = closerNode.node.Literal[:len(closerNode.node.Literal)-used]
+			openerNode.node.End -= used
+			closerNode.node.Start += used
+
+			kind := KindEmphasis
+			if used == 2 {
+				kind = KindStrong
+			}
+			emphasis := &inline{node: &Node{Kind: kind, Start: openerNode.node.End, End: closerNode.node.Start}}
+			for child := openerNode.next; child != nil && child != closerNode; {
+				next := child.next
+				emphasis.appendChild(child)
+				child = next
+			}
+			openerNode.insertAfter(emphasis)
+
+			// The delimiters between the opener and the closer can no longer match.
+			if opener.next != closer {
+				opener.next = closer
+				closer.prev = opener
+			}
+			if opener.count == 0 {
+				openerNode.unlink()
+				p.removeDelimiter(opener)
+			}
+			if closer.count == 0 {
+				closerNode.unlink()
+				next := closer.next
+				p.removeDelimiter(closer)
+				closer = next
+			}
+		} else {
+			closer = closer.next
+			openersBottom[index] = oldCloser.prev
+			if !oldCloser.canOpen {
+				// A closer that cannot open will never match an opener.
+				p.removeDelimiter(oldCloser)
+			}
+		}
+	}
+
+	for p.delimiters != nil && p.delimiters != bottom {
+		p.removeDelimiter(p.delimiters)
+	}
+}
+
+func (p *inlineParser) addBracket(node *inline, index int, image bool) {
+	if p.brackets != nil {
+		p.brackets.bracketAfter = true
+	}
+	p.brackets = &bracket{
+		node:          node,
+		prev:          p.brackets,
+		prevDelimiter: p.delimiters,
+		index:         index,
+		image:         image,
+		active:        true,
+	}
+}
+
+func (p *inlineParser) removeBracket() {
+	p.brackets = p.brackets.prev
+}
+
+func (p *inlineParser) parseOpenBracket() bool {
+	start := p.pos
+	p.pos++
+	node := p.text(start, p.pos, "[")
+	p.addBracket(node, start, false)
+	return true
+}
+
+func (p *inlineParser) parseBang() bool {
+	start := p.pos
+	p.pos++
+	if p.peek() != '[' {
+		p.text(start, p.pos, "!")
+		return true
+	}
+	p.pos++
+	node := p.text(start, p.pos, "![")
+	p.addBracket(node, start+1, true)
+	return true
+}
+
+// parseCloseBracket matches a `]` with the last opening bracket, making a link or an image if it
+// is followed by a destination or matches a link reference definition.
+//
+// https://spec.commonmark.org/0.31.2/#look-for-link-or-image
+func (p *inlineParser) parseCloseBracket() bool {
+	p.pos++
+	start := p.pos
+
+	opener := p.brackets
+	if opener == nil {
+		p.text(start-1, start, "]")
+		return true
+	}
+	if !opener.active {
+		p.text(start-1, start, "]")
+		p.removeBracket()
+		return true
+	}
+
+	var destination, title, label string
+	matched := false
+	if p.peek() == '(' {
+		p.pos++
+		p.spnl()
+		if dest, ok := p.parseLinkDestination(); ok {
+			destination = dest
+			beforeTitle := p.pos
+			p.spnl()
+			// A title must be separated from the destination by whitespace.
+			if p.pos > beforeTitle {
+				if t, ok := p.parseLinkTitle(); ok {
+					title = t
+				}
+			}
+			p.spnl()
+			if p.peek() == ')' {
+				p.pos++
+				matched = true
+			}
+		}
+		if !matched {
+			p.pos = start
+		}
+	}
+
+	if !matched {
+		beforeLabel := p.pos
+		n := p.parseLinkLabel()
+		var rawLabel string
+		if n > 2 {
+			rawLabel = p.subject.text[beforeLabel : beforeLabel+n]
+		} else if !opener.bracketAfter {
+			// Collapsed or shortcut reference: the text of the link is its label.
+			rawLabel = p.subject.text[opener.index:start]
+		}
+		if n == 0 {
+			p.pos = start
+		}
+		if rawLabel != "" {
+			if ref, ok := p.refs[normalizeReference(rawLabel)]; ok {
+				destination = ref.destination
+				title = ref.title
+				label = normalizeReference(rawLabel)
+				matched = true
+			}
+		}
+	}
+
+	if !matched && p.parseFootnoteReference(opener, start) {
+		return true
+	}
+	if !matched {
+		p.removeBracket()
+		p.pos = start
+		p.text(start-1, start, "]")
+		return true
+	}
+
+	kind := KindLink
+	if opener.image {
+		kind = KindImage
+	}
+	link := &inline{node: &Node{
+		Kind:        kind,
+		Start:       opener.node.node.Start,
+		End:         p.subject.source(p.pos-1) + 1,
+		Destination: destination,
+		Title:       title,
+		Label:       label,
+	}}
+	for child := opener.node.next; child != nil; {
+		next := child.next
+		link.appendChild(child)
+		child = next
+	}
+	p.root.appendChild(link)
+	p.processEmphasis(opener.prevDelimiter)
+	p.removeBracket()
+	opener.node.unlink()
+
+	// Links may not contain other links, so the brackets before a link can no longer open one.
+	if !opener.image {
+		for b := p.brackets; b != nil; b = b.prev {
+			if !b.image {
+				b.active = false
+			}
+		}
+	}
+	return true
+}
+
+// parseFootnoteReference replaces the text between the opener and the closing bracket that ends
+// before the given position with a footnote reference, if it is the label of a footnote.
+func (p *inlineParser) parseFootnoteReference(opener *bracket, end int) bool {
+	text := p.subject.text[opener.index+1 : end-1]
+	if opener.image || len(text) < 2 || text[0] != '^' || !p.footnotes[normalizeFootnote(text[1:])] {
+		return false
+	}
+
+	p.pos = end
+	reference := &inline{node: &Node{
+		Kind:    KindFootnoteReference,
+		Start:   opener.node.node.Start,
+		End:     p.subject.source(end-1) + 1,
+		Literal: text[1:],
+		Label:   normalizeFootnote(text[1:]),
+	}}
+	p.processEmphasis(opener.prevDelimiter)
+	for child := opener.node.next; child != nil; {
+		next := child.next
+		child.unlink()
+		child = next
+	}
+	p.removeBracket()
+	opener.node.unlink()
+	p.root.appendChild(reference)
+	return true
+}
+
+func (p *inlineParser) spnl() {
+	p.match(reSpnl)
+}
+
+// maxNestedParens bounds the nesting of parentheses in link destinations, as the specification
+// allows, so that parsing them stays linear.
+const maxNestedParens = 32
+
+// parseLinkDestination parses a destination in angle brackets or a run of characters with
+// balanced parentheses.
+//
+// https://spec.commonmark.org/0.31.2/#link-destination
+func (p *inlineParser) parseLinkDestination() (string, bool) {
+	if m, ok := p.match(reLinkDestinationBraces); ok {
+		return unescapeString(m[1 : len(m)-1]), true
+	}
+	if p.peek() == '<' {
+		return "", false
+	}
+
+	text := p.subject.text
+	start := p.pos
+	openParens := 0
+	for p.pos < len(text) {
+		c := text[p.pos]
+		if c == '\\' && p.pos+1 < len(text) && reEscapableChar.MatchString(text[p.pos+1:]) {
+			p.pos += 2
+		} else if c == '(' {
+			p.pos++
+			openParens++
+			if openParens > maxNestedParens {
+				p.pos = start
+				return "", false
+			}
+		} else if c == ')' {
+			if openParens < 1 {
+				break
+			}
+			p.pos++
+			openParens--
+		} else if c <= ' ' {
+			break
+		} else {
+			p.pos++
+		}
+	}
+	if p.pos == start && p.peek() != ')' || openParens != 0 {
+		p.pos = start
+		return "", false
+	}
+	return unescapeString(text[start:p.pos]), true
+}
+
+func (p *inlineParser) parseLinkTitle() (string, bool) {
+	m, ok := p.match(reLinkTitle)
+	if !ok {
+		return "", false
+	}
+	return unescapeString(m[1 : len(m)-1]), true
+}
+
+// parseLinkLabel consumes a link label and returns its length, including the brackets, or 0 if
+// there is none. Labels may not contain unescaped brackets nor be longer than 999 characters.
+func (p *inlineParser) parseLinkLabel() int {
+	text := p.subject.text
+	if p.peek() != '[' {
+		return 0
+	}
+	for i := p.pos + 1; i < len(text) && i-p.pos <= 1000; i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '[':
+			return 0
+		case ']':
+			n := i + 1 - p.pos
+			p.pos = i + 1
+			return n
+		}
+	}
+	return 0
+}
+
+// parseReference parses a link reference definition at the current position and records it.
+//
+// https://spec.commonmark.org/0.31.2/#link-reference-definitions
+func (p *inlineParser) parseReference() (*Node, bool) {
+	start := p.pos
+	fail := func() (*Node, bool) {
+		p.pos = start
+		return nil, false
+	}
+
+	n := p.parseLinkLabel()
+	if n == 0 {
+		return fail()
+	}
+	rawLabel := p.subject.text[start : start+n]
+	if p.peek() != ':' {
+		return fail()
+	}
+	p.pos++
+
+	p.spnl()
+	destination, ok := p.parseLinkDestination()
+	if !ok {
+		return fail()
+	}
+
+	beforeTitle := p.pos
+	p.spnl()
+	title := ""
+	hasTitle := false
+	if p.pos != beforeTitle {
+		title, hasTitle = p.parseLinkTitle()
+	}
+	if !hasTitle {
+		p.pos = beforeTitle
+	}
+
+	end := p.pos
+	if _, ok := p.match(reSpaceAtEndOfLine); !ok {
+		if !hasTitle {
+			return fail()
+		}
+		// The title is not followed by the end of the line, but the definition is still valid
+		// without it.
+		title = ""
+		p.pos = beforeTitle
+		end = p.pos
+		if _, ok := p.match(reSpaceAtEndOfLine); !ok {
+			return fail()
+		}
+	}
+
+	label := normalizeReference(rawLabel)
+	if label == "" {
+		return fail()
+	}
+	if _, ok := p.refs[label]; !ok {
+		// The first definition of a label takes precedence.
+		p.refs[label] = reference{destination: destination, title: title}
+	}
+
+	nodeStart, nodeEnd := p.subject.span(start, end)
+	return &Node{
+		Kind:        KindLinkReferenceDefinition,
+		Start:       nodeStart,
+		End:         nodeEnd,
+		Literal:     strings.TrimSpace(rawLabel[1 : len(rawLabel)-1]),
+		Destination: destination,
+		Title:       title,
+		Label:       label,
+	}, true
+}
+
+func (p *inlineParser) parseAutolink() bool {
+	start := p.pos
+	var destination string
+	if m, ok := p.match(reEmailAutolink); ok {
+		destination = "mailto:" + m[1:len(m)-1]
+	} else if m, ok := p.match(reAutolink); ok {
+		destination = m[1 : len(m)-1]
+	} else {
+		return false
+	}
+
+	link := p.newNode(KindAutolink, start, p.pos)
+	link.node.Literal = p.subject.text[start+1 : p.pos-1]
+	link.node.Destination = destination
+	p.root.appendChild(link)
+	return true
+}
+
+func (p *inlineParser) parseHTMLTag() bool {
+	start := p.pos
+	m, ok := p.match(reHTMLTag)
+	if !ok {
+		return false
+	}
+	tag := p.newNode(KindHTMLInline, start, p.pos)
+	tag.node.Literal = m
+	p.root.appendChild(tag)
+	return true
+}
+
+func (p *inlineParser) parseEntity() bool {
+	start := p.pos
+	m, ok := p.match(reEntityHere)
+	if !ok {
+		return false
+	}
+	decoded := html.UnescapeString(m)
+	if decoded == m {
+		// Unknown entities are literal text.
+		p.pos = start
+		return false
+	}
+	p.text(start, p.pos, decoded)
+	return true
+}
+
+func (p *inlineParser) parseString() bool {
+	start := p.pos
+	m, ok := p.match(reMain)
+	if !ok {
+		return false
+	}
+	p.text(start, p.pos, m)
+	return true
+}
+
+// normalizeReference normalizes a link label, with its brackets, so that labels can be matched
+// case-insensitively and regardless of whitespace.
+func normalizeReference(label string) string {
+	label = strings.TrimSpace(label[1 : len(label)-1])
+	label = reWhitespaceRun.ReplaceAllString(label, " ")
+	return strings.ToUpper(strings.ToLower(label))
+}
+
+// normalizeFootnote normalizes the label of a footnote, without its brackets and caret, like the
+// label of a link reference.
+func normalizeFootnote(label string) string {
+	return normalizeReference("[" + label + "]")
+}
+
+// unescapeString decodes the backslash escapes and entities of a string.
+func unescapeString(s string) string {
+	if !strings.ContainsAny(s, `\&`) {
+		return s
+	}
+	return reEntityOrEscapedChar.ReplaceAllStringFunc(s, func(m string) string {
+		if m[0] == '\\' {
+			return m[1:]
+		}
+		return html.UnescapeString(m)
+	})
+}
@@ -0,0 +1,393 @@
+package markdown
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"testing"
+)
+
+// renderHTML renders a tree the way the CommonMark reference implementation does, so that the
+// parser can be checked against the examples of the specification.
+func renderHTML(root *Node) string {
+	r := &htmlRenderer{}
+	r.renderBlocks(root, false)
+	return r.sb.String()
+}
+
+type htmlRenderer struct {
+	sb strings.Builder
+}
+
+// cr starts a new line, unless the output already ends with one.
+func (r *htmlRenderer) cr() {
+	if s := r.sb.String(); s != "" && !strings.HasSuffix(s, "\n") {
+		r.sb.WriteByte('\n')
+	}
+}
+
+func (r *htmlRenderer) children(n *Node) {
+	for _, child := range n.Children {
+		r.renderInline(child)
+	}
+}
+
+func (r *htmlRenderer) renderInline(n *Node) {
+	switch n.Kind {
+	case KindText:
+		r.sb.WriteString(html.EscapeString(n.Literal))
+	case KindSoftBreak:
+		r.sb.WriteString("\n")
+	case KindHardBreak:
+		r.sb.WriteString("<br />\n")
+	case KindCodeSpan:
+		fmt.Fprintf(&r.sb, "<code>%s</code>", html.EscapeString(n.Literal))
+	case KindEmphasis:
+		r.sb.WriteString("<em>")
+		r.children(n)
+		r.sb.WriteString("</em>")
+	case KindStrong:
+		r.sb.WriteString("<strong>")
+		r.children(n)
+		r.sb.WriteString("</strong>")
+	case KindLink:
+		fmt.Fprintf(&r.sb, `<a href="%s"`, html.EscapeString(n.Destination))
+		if n.Title != "" {
+			fmt.Fprintf(&r.sb, ` title="%s"`, html.EscapeString(n.Title))
+		}
+		r.sb.WriteString(">")
+		r.children(n)
+		r.sb.WriteString("</a>")
+	case KindImage:
+		fmt.Fprintf(&r.sb, `<img src="%s" alt="%s"`, html.EscapeString(n.Destination), html.EscapeString(plainText(n)))
+		if n.Title != "" {
+			fmt.Fprintf(&r.sb, ` title="%s"`, html.EscapeString(n.Title))
+		}
+		r.sb.WriteString(" />")
+	case KindAutolink:
+		fmt.Fprintf(&r.sb, `<a href="%s">%s</a>`, html.EscapeString(n.Destination), html.EscapeString(n.Literal))
+	case KindHTMLInline:
+		r.sb.WriteString(n.Literal)
+	}
+}
+
+// renderBlocks renders the children of a block. The paragraphs of the items of tight lists are
+// rendered without `<p>` tags.
+func (r *htmlRenderer) renderBlocks(n *Node, tight bool) {
+	for _, child := range n.Children {
+		r.renderBlock(child, tight)
+	}
+}
+
+func (r *htmlRenderer) renderBlock(n *Node, tight bool) {
+	switch n.Kind {
+	case KindParagraph:
+		if !tight {
+			r.cr()
+			r.sb.WriteString("<p>")
+		}
+		r.children(n)
+		if !tight {
+			r.sb.WriteString("</p>")
+			r.cr()
+		}
+	case KindHeading:
+		r.cr()
+		fmt.Fprintf(&r.sb, "<h%d>", n.Level)
+		r.children(n)
+		fmt.Fprintf(&r.sb, "</h%d>", n.Level)
+		r.cr()
+	case KindCodeBlock:
+		r.cr()
+		r.sb.WriteString("<pre><code")
+		if info := strings.Fields(n.Info); len(info) > 0 {
+			fmt.Fprintf(&r.sb, ` class="language-%s"`, html.EscapeString(info[0]))
+		}
+		fmt.Fprintf(&r.sb, ">%s</code></pre>", html.EscapeString(n.Literal))
+		r.cr()
+	case KindHTMLBlock:
+		r.cr()
+		r.sb.WriteString(n.Literal)
+		r.cr()
+	case KindThematicBreak:
+		r.cr()
+		r.sb.WriteString("<hr />")
+		r.cr()
+	case KindBlockQuote:
+		r.cr()
+		r.sb.WriteString("<blockquote>")
+		r.cr()
+		r.renderBlocks(n, false)
+		r.cr()
+		r.sb.WriteString("</blockquote>")
+		r.cr()
+	case KindList:
+		tag := "ul"
+		if n.Ordered {
+			tag = "ol"
+		}
+		r.cr()
+		fmt.Fprintf(&r.sb, "<%s>", tag)
+		r.cr()
+		r.renderBlocks(n, n.Tight)
+		r.cr()
+		fmt.Fprintf(&r.sb, "</%s>", tag)
+		r.cr()
+	case KindListItem:
+		r.sb.WriteString("<li>")
+		r.renderBlocks(n, tight)
+		r.sb.WriteString("</li>")
+		r.cr()
+	}
+}
+
+// plainText returns the text of the inlines of a node, as used for the alt text of images.
+func plainText(n *Node) string {
+	var sb strings.Builder
+	Walk(n, func(child *Node) bool {
+		switch child.Kind {
+		case KindText, KindCodeSpan:
+			sb.WriteString(child.Literal)
+		case KindSoftBreak, KindHardBreak:
+			sb.WriteString("\n")
+		}
+		return true
+	})
+	return sb.String()
+}
+
+func TestParseSpecExamples(t *testing.T) {
+	t.Parallel()
+
+	// Examples of the CommonMark specification, whose URLs need no percent-encoding.
+	tests := []struct {
+		markdown string
+		want     string
+	}{
+		// Tabs.
+		{"\tfoo\tbaz\t\tbim\n", "<pre><code>foo\tbaz\t\tbim\n</code></pre>\n"},
+		{"- foo\n\n\tbar\n", "<ul>\n<li>\n<p>foo</p>\n<p>bar</p>\n</li>\n</ul>\n"},
+		{">\t\tfoo\n", "<blockquote>\n<pre><code>  foo\n</code></pre>\n</blockquote>\n"},
+		{"*\t*\t*\t\n", "<hr />\n"},
+		// Backslash escapes and entities.
+		{"\\*not emphasized*\n", "<p>*not emphasized*</p>\n"},
+		{"foo\\\nbar\n", "<p>foo<br />\nbar</p>\n"},
+		{"&amp; &copy; &#35; &#x22;\n", "<p>&amp; © # &#34;</p>\n"},
+		// Thematic breaks.
+		{"***\n---\n___\n", "<hr />\n<hr />\n<hr />\n"},
+		{"Foo\n***\nbar\n", "<p>Foo</p>\n<hr />\n<p>bar</p>\n"},
+		// ATX headings.
+		{"# foo *bar* \\*baz\\*\n", "<h1>foo <em>bar</em> *baz*</h1>\n"},
+		{"## foo ##\n  ###   bar    ###\n", "<h2>foo</h2>\n<h3>bar</h3>\n"},
+		{"#5 bolt\n\n#hashtag\n", "<p>#5 bolt</p>\n<p>#hashtag</p>\n"},
+		// Setext headings.
+		{"Foo *bar\nbaz*\n====\n", "<h1>Foo <em>bar\nbaz</em></h1>\n"},
+		{"Foo\n---\nbar\n", "<h2>Foo</h2>\n<p>bar</p>\n"},
+		{"> foo\nbar\n===\n", "<blockquote>\n<p>foo\nbar\n===</p>\n</blockquote>\n"},
+		// Code blocks.
+		{"    a simple\n      indented code block\n", "<pre><code>a simple\n  indented code block\n</code></pre>\n"},
+		{"```\n<\n >\n```\n", "<pre><code>&lt;\n &gt;\n</code></pre>\n"},
+		{"  ```\n aaa\naaa\n  ```\n", "<pre><code>aaa\naaa\n</code></pre>\n"},
+		{"```ruby startline=3\ndef foo(x)\n```\n", "<pre><code class=\"language-ruby\">def foo(x)\n</code></pre>\n"},
+		{"``` ```\naaa\n", "<p><code> </code>\naaa</p>\n"},
+		// HTML blocks.
+		{"<div>\n*hello*\n\n*world*\n", "<div>\n*hello*\n<p><em>world</em></p>\n"},
+		{"<!-- foo -->*bar*\n*baz*\n", "<!-- foo -->*bar*\n<p><em>baz</em></p>\n"},
+		// Link reference definitions.
+		{"[foo]: /url \"title\"\n\n[foo]\n", "<p><a href=\"/url\" title=\"title\">foo</a></p>\n"},
+		{"[foo]\n\n[foo]: /url\n", "<p><a href=\"/url\">foo</a></p>\n"},
+		{"[foo]: /url \"title\" ok\n", "<p>[foo]: /url &#34;title&#34; ok</p>\n"},
+		{"[foo]: /first\n[foo]: /second\n\n[foo]\n", "<p><a href=\"/first\">foo</a></p>\n"},
+		{"[foo]: /url\n===\n[foo]\n", "<p>===\n<a href=\"/url\">foo</a></p>\n"},
+		// Block quotes.
+		{"> # Foo\n> bar\n> baz\n", "<blockquote>\n<h1>Foo</h1>\n<p>bar\nbaz</p>\n</blockquote>\n"},
+		{"> - foo\n- bar\n", "<blockquote>\n<ul>\n<li>foo</li>\n</ul>\n</blockquote>\n<ul>\n<li>bar</li>\n</ul>\n"},
+		// Lists.
+		{"1.  A paragraph\n    with two lines.\n\n        indented code\n\n    > A block quote.\n", "<ol>\n<li>\n<p>A paragraph\nwith two lines.</p>\n<pre><code>indented code\n</code></pre>\n<blockquote>\n<p>A block quote.</p>\n</blockquote>\n</li>\n</ol>\n"},
+		{"- foo\n- bar\n+ baz\n", "<ul>\n<li>foo</li>\n<li>bar</li>\n</ul>\n<ul>\n<li>baz</li>\n</ul>\n"},
+		{"- a\n- b\n\n- c\n", "<ul>\n<li>\n<p>a</p>\n</li>\n<li>\n<p>b</p>\n</li>\n<li>\n<p>c</p>\n</li>\n</ul>\n"},
+		{"- a\n - b\n  - c\n   - d\n  - e\n - f\n- g\n", "<ul>\n<li>a</li>\n<li>b</li>\n<li>c</li>\n<li>d</li>\n<li>e</li>\n<li>f</li>\n<li>g</li>\n</ul>\n"},
+		{"- # Foo\n- Bar\n  ---\n  baz\n", "<ul>\n<li>\n<h1>Foo</h1>\n</li>\n<li>\n<h2>Bar</h2>\nbaz</li>\n</ul>\n"},
+		{"The number of windows in my house is\n14.  The number of doors is 6.\n", "<p>The number of windows in my house is\n14.  The number of doors is 6.</p>\n"},
+		{"-\n  foo\n", "<ul>\n<li>foo</li>\n</ul>\n"},
+		// Code spans.
+		{"`` foo ` bar ``\n", "<p><code>foo ` bar</code></p>\n"},
+		{"`foo   bar \nbaz`\n", "<p><code>foo   bar  baz</code></p>\n"},
+		{"```foo``\n", "<p>```foo``</p>\n"},
+		// Emphasis.
+		{"*foo*bar\n", "<p><em>foo</em>bar</p>\n"},
+		{"_foo_bar\n", "<p>_foo_bar</p>\n"},
+		{"*foo**bar**baz*\n", "<p><em>foo<strong>bar</strong>baz</em></p>\n"},
+		{"foo***bar***baz\n", "<p>foo<em><strong>bar</strong></em>baz</p>\n"},
+		{"**foo*\n", "<p>*<em>foo</em></p>\n"},
+		{"*foo**bar*\n", "<p><em>foo**bar</em></p>\n"},
+		{"a * foo bar*\n", "<p>a * foo bar*</p>\n"},
+		{"**foo \"*bar*\" foo**\n", "<p><strong>foo &#34;<em>bar</em>&#34; foo</strong></p>\n"},
+		// Links and images.
+		{"[link](/uri \"title\")\n", "<p><a href=\"/uri\" title=\"title\">link</a></p>\n"},
+		{"[link](</my uri>)\n", "<p><a href=\"/my uri\">link</a></p>\n"},
+		{"[link](foo(and(bar)))\n", "<p><a href=\"foo(and(bar))\">link</a></p>\n"},
+		{"[link [foo [bar]]](/uri)\n", "<p><a href=\"/uri\">link [foo [bar]]</a></p>\n"},
+		{"[link [bar](/uri)\n", "<p>[link <a href=\"/uri\">bar</a></p>\n"},
+		{"[foo [bar](/uri)](/uri)\n", "<p>[foo <a href=\"/uri\">bar</a>](/uri)</p>\n"},
+		{"![foo [bar](/uri)](/uri2)\n", "<p><img src=\"/uri2\" alt=\"foo bar\" /></p>\n"},
+		{"*[foo*](/uri)\n", "<p>*<a href=\"/uri\">foo*</a></p>\n"},
+		{"[Foo\n  bar]: /url\n\n[Baz][Foo bar]\n", "<p><a href=\"/url\">Baz</a></p>\n"},
+		// Autolinks and raw HTML.
+		{"<http://foo.bar.baz>\n", "<p><a href=\"http://foo.bar.baz\">http://foo.bar.baz</a></p>\n"},
+		{"<foo@bar.example.com>\n", "<p><a href=\"mailto:foo@bar.example.com\">foo@bar.example.com</a></p>\n"},
+		{"<a  /><b2\ndata=\"foo\" >\n", "<p><a  /><b2\ndata=\"foo\" ></p>\n"},
+		// Line breaks.
+		{"foo  \nbar\n", "<p>foo<br />\nbar</p>\n"},
+		{"foo \n bar\n", "<p>foo\nbar</p>\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.markdown, func(t *testing.T) {
+			t.Parallel()
+
+			if got := renderHTML(Parse(test.markdown)); got != test.want {
+				t.Errorf("Parse(%q) rendered\n%q\nwant\n%q", test.markdown, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseSourcePositions(t *testing.T) {
+	t.Parallel()
+
+	source := "---\ntitle: Editors\n---\n\n# Editors\n\n> I use *VS Code*\n> and [Neovim][nvim].\n\n- `code`\n- <https://neovim.io>\n\n[nvim]: https://neovim.io \"Neovim\"\n"
+	root := Parse(source)
+
+	want := []string{
+		"FrontMatter ---\ntitle: Editors\n---",
+		"Heading # Editors",
+		"Text Editors",
+		"BlockQuote > I use *VS Code*\n> and [Neovim][nvim].",
+		"Paragraph I use *VS Code*\n> and [Neovim][nvim].",
+		"Text I use ",
+		"Emphasis *VS Code*",
+		"Text VS Code",
+		"SoftBreak \n",
+		"Text and ",
+		"Link [Neovim][nvim]",
+		"Text Neovim",
+		"Text .",
+		"List - `code`\n- <https://neovim.io>",
+		"ListItem - `code`",
+		"Paragraph `code`",
+		"CodeSpan `code`",
+		"ListItem - <https://neovim.io>",
+		"Paragraph <https://neovim.io>",
+		"Autolink <https://neovim.io>",
+		"LinkReferenceDefinition [nvim]: https://neovim.io \"Neovim\"",
+	}
+	var got []string
+	Walk(root, func(n *Node) bool {
+		if n.Kind != KindDocument {
+			got = append(got, n.Kind.String()+" "+source[n.Start:n.End])
+		}
+		return true
+	})
+	if strings.Join(got, "\n|") != strings.Join(want, "\n|") {
+		t.Errorf("Parse got nodes\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestParseFootnotes(t *testing.T) {
+	t.Parallel()
+
+	source := "Editors[^vim] and [^none].\n\n[^vim]: Neovim, *mostly*.\n\n    And Vim.\nlazy\n\nafter\n"
+	root := Parse(source)
+
+	want := []string{
+		"Paragraph Editors[^vim] and [^none].",
+		"Text Editors",
+		"FootnoteReference [^vim] VIM",
+		"Text  and [^none].",
+		"FootnoteDefinition [^vim]: Neovim, *mostly*.\n\n    And Vim.\nlazy VIM",
+		"Paragraph Neovim, *mostly*.",
+		"Text Neovim, ",
+		"Emphasis *mostly*",
+		"Text mostly",
+		"Text .",
+		"Paragraph And Vim.\nlazy",
+		"Text And Vim.",
+		"SoftBreak \n",
+		"Text lazy",
+		"Paragraph after",
+		"Text after",
+	}
+	var got []string
+	Walk(root, func(n *Node) bool {
+		switch n.Kind {
+		case KindDocument:
+		case KindFootnoteDefinition, KindFootnoteReference:
+			got = append(got, n.Kind.String()+" "+source[n.Start:n.End]+" "+n.Label)
+		default:
+			got = append(got, n.Kind.String()+" "+source[n.Start:n.End])
+		}
+		return true
+	})
+	if strings.Join(got, "\n|") != strings.Join(want, "\n|") {
+		t.Errorf("Parse got nodes\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestParseFrontMatter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			name:   "closed by dashes",
+			source: "---\ntitle: VS Code\n---\ntext\n",
+			want:   "title: VS Code\n",
+		},
+		{
+			name:   "closed by dots",
+			source: "---\ntitle: VS Code\n...\ntext\n",
+			want:   "title: VS Code\n",
+		},
+		{
+			name:   "never closed",
+			source: "---\ntitle: VS Code\n",
+		},
+		{
+			name:   "not at the start",
+			source: "text\n---\ntitle: VS Code\n---\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			root := Parse(test.source)
+			var got string
+			if first := root.Children[0]; first.Kind == KindFrontMatter {
+				got = first.Literal
+			}
+			if got != test.want {
+				t.Errorf("Parse got front matter %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	for _, seed := range []string{"", "# a\n", "> - `b`\n\t*c*", "[d]: <e> 'f'\n[d]", "---\ng\n---", "***h**_i_\r\nj", "*"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, source string) {
+		Walk(Parse(source), func(n *Node) bool {
+			if n.Start < 0 || n.End > len(source) || n.Start > n.End {
+				t.Fatalf("Parse(%q) got %s spanning [%d, %d)", source, n.Kind, n.Start, n.End)
+			}
+			return true
+		})
+	})
+}
@@ -0,0 +1,118 @@
+// Package markdown parses CommonMark documents into a syntax tree whose nodes keep their position
+// in the source, so that language features can work on the structure of a document rather than
+// on its raw lines.
+//
+// https://spec.commonmark.org/0.31.2/
+package markdown
+
+// Kind is the type of a node.
+type Kind int
+
+const (
+	KindDocument Kind = iota
+	// KindFrontMatter is a YAML block delimited by `---` lines at the very start of the document.
+	// It is not part of CommonMark, but is common enough that it must not be parsed as markdown.
+	KindFrontMatter
+	KindBlockQuote
+	KindList
+	KindListItem
+	KindParagraph
+	KindHeading
+	KindThematicBreak
+	KindCodeBlock
+	KindHTMLBlock
+	KindLinkReferenceDefinition
+	// KindFootnoteDefinition is a footnote, as in GitHub Flavored Markdown: `[^label]: body`, where
+	// the lines of the body after the first one are indented.
+	KindFootnoteDefinition
+
+	KindText
+	KindSoftBreak
+	KindHardBreak
+	KindCodeSpan
+	KindEmphasis
+	KindStrong
+	KindLink
+	KindImage
+	KindAutolink
+	KindHTMLInline
+	// KindFootnoteReference is a `[^label]` that refers to a footnote definition.
+	KindFootnoteReference
+)
+
+var kindNames = [...]string{
+	KindDocument:                "Document",
+	KindFrontMatter:             "FrontMatter",
+	KindBlockQuote:              "BlockQuote",
+	KindList:                    "List",
+	KindListItem:                "ListItem",
+	KindParagraph:               "Paragraph",
+	KindHeading:                 "Heading",
+	KindThematicBreak:           "ThematicBreak",
+	KindCodeBlock:               "CodeBlock",
+	KindHTMLBlock:               "HTMLBlock",
+	KindLinkReferenceDefinition: "LinkReferenceDefinition",
+	KindFootnoteDefinition:      "FootnoteDefinition",
+	KindText:                    "Text",
+	KindSoftBreak:               "SoftBreak",
+	KindHardBreak:               "HardBreak",
+	KindCodeSpan:                "CodeSpan",
+	KindEmphasis:                "Emphasis",
+	KindStrong:                  "Strong",
+	KindLink:                    "Link",
+	KindImage:                   "Image",
+	KindAutolink:                "Autolink",
+	KindHTMLInline:              "HTMLInline",
+	KindFootnoteReference:       "FootnoteReference",
+}
+
+func (k Kind) String() string {
+	if k < 0 || int(k) >= len(kindNames) {
+		return "Unknown"
+	}
+	return kindNames[k]
+}
+
+// IsBlock reports whether nodes of this kind are blocks rather than inlines.
+func (k Kind) IsBlock() bool {
+	return k < KindText
+}
+
+// Node is a node of the syntax tree. Start and End are the byte offsets of the node in the source:
+// `source[Start:End]` is the markdown the node was parsed from, including its markers.
+type Node struct {
+	Kind     Kind
+	Start    int
+	End      int
+	Children []*Node
+
+	// Level is the level of a heading, from 1 to 6.
+	Level int
+	// Literal is the content of text, code, HTML and front matter nodes, and the label of link
+	// reference definitions, footnote definitions and footnote references as written. Backslash
+	// escapes and entities are decoded in text nodes.
+	Literal string
+	// Info is the info string of a fenced code block, e.g. its language.
+	Info string
+	// Destination and Title are set on links, images, autolinks and link reference definitions.
+	Destination string
+	Title       string
+	// Label is the normalized label of link reference definitions and footnote definitions, and of
+	// the links, images and footnote references that refer to them. It is empty for inline links.
+	Label string
+	// Ordered is true for ordered lists. Tight is true for lists whose items are not separated by
+	// blank lines.
+	Ordered bool
+	Tight   bool
+}
+
+// Walk calls fn for the node and then, if fn returned true, for every one of its descendants in
+// the order they appear in the document.
+func Walk(n *Node, fn func(n *Node) bool) {
+	if !fn(n) {
+		return
+	}
+	for _, child := range n.Children {
+		Walk(child, fn)
+	}
+}
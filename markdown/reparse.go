@@ -0,0 +1,168 @@
+package markdown
+
+import "strings"
+
+// Edit describes a change to a source: the bytes in [Start, OldEnd) of the old source were replaced
+// by the bytes in [Start, NewEnd) of the new one.
+type Edit struct {
+	Start  int
+	OldEnd int
+	NewEnd int
+}
+
+// Reparse returns the syntax tree of the source, which must be the source the tree was parsed from
+// with the edit applied. Only the top-level blocks affected by the edit are parsed again: parsing
+// starts at the last block that began on a line before the edit and stops at the first block that
+// begins after it on the same line as before the edit, from where the rest of the document is known
+// to parse the same way. The other blocks are reused, so the tree must not be used afterwards.
+//
+// Edits that can change the meaning of the whole document, such as edits to the front matter, to
+// link reference definitions or to footnote definitions, cause the document to be parsed from scratch.
+func Reparse(tree *Node, source string, edit Edit) *Node {
+	delta := edit.NewEnd - edit.OldEnd
+	if tree == nil || tree.End+delta != len(source) || edit.Start < 0 || edit.OldEnd < edit.Start || edit.NewEnd < edit.Start || edit.NewEnd > len(source) {
+		return Parse(source)
+	}
+
+	children := tree.Children
+	first, restart := 0, 0
+	if len(children) > 0 && children[0].Kind == KindFrontMatter {
+		if edit.Start <= children[0].End {
+			return Parse(source)
+		}
+		first = 1
+		if _, restart = nextLine(source, children[0].End); edit.Start < restart {
+			return Parse(source)
+		}
+	} else if opensFrontMatter(source) {
+		return Parse(source)
+	}
+
+	// The blocks that began on a line before the edit were closed by a line that the edit did not
+	// touch, except for the last one, which may go on through the edited lines.
+	editLine := strings.LastIndexByte(source[:edit.Start], '\n') + 1
+	r := first
+	for r+1 < len(children) && children[r+1].Start < editLine {
+		r++
+	}
+	// Definitions are split from the start of a paragraph, so the blocks that follow them may not
+	// have begun on a line of their own.
+	for r > first && children[r-1].Kind == KindLinkReferenceDefinition {
+		r--
+	}
+	if r < len(children) && children[r].Start < editLine {
+		restart = strings.LastIndexByte(source[:children[r].Start], '\n') + 1
+	}
+
+	refs, footnotes := references(tree)
+	p := newBlockParser(source, refs, footnotes)
+	reused, synced := r, false
+	for start, n := restart, 0; start < len(source); n++ {
+		var span lineSpan
+		span, start = nextLine(source, start)
+		p.lines = append(p.lines, span)
+		p.incorporateLine(n)
+
+		// Past the edit, a block that begins on the same line as an old one is parsed from the same
+		// state and text as it was: the old one and those that follow it can be reused.
+		b := p.doc.lastChild()
+		if b == nil || b.startLine != n || span.start <= edit.NewEnd {
+			continue
+		}
+		for reused < len(children) && children[reused].Start+delta < span.start {
+			reused++
+		}
+		if reused < len(children) && (reused == 0 || children[reused-1].Kind != KindLinkReferenceDefinition) &&
+			strings.IndexByte(source[span.start:children[reused].Start+delta], '\n') < 0 {
+			p.drop(b)
+			synced = true
+			break
+		}
+	}
+	if !synced {
+		// The end of the document was reached before the old blocks could be reused.
+		reused = len(children)
+	}
+
+	parsed := p.finish()
+	if containsDefinition(children[r:reused]) || containsDefinition(parsed.Children) {
+		return Parse(source)
+	}
+	for _, child := range children[reused:] {
+		Walk(child, func(n *Node) bool {
+			n.Start += delta
+			n.End += delta
+			return true
+		})
+	}
+
+	var blocks []*Node
+	blocks = append(blocks, children[:r]...)
+	blocks = append(blocks, parsed.Children...)
+	blocks = append(blocks, children[reused:]...)
+	tree.Children = blocks
+	tree.End = len(source)
+	return tree
+}
+
+// drop removes a top-level block that was just added, along with its content, and closes the
+// document.
+func (p *blockParser) drop(b *block) {
+	p.unlink(b)
+	leaves := p.leaves[:0]
+	for _, leaf := range p.leaves {
+		if leaf.node.Start < b.node.Start {
+			leaves = append(leaves, leaf)
+		}
+	}
+	p.leaves = leaves
+	p.doc.open = false
+	p.tip = nil
+}
+
+// opensFrontMatter reports whether the first line of the source could open front matter.
+func opensFrontMatter(source string) bool {
+	span, _ := nextLine(source, 0)
+	return strings.TrimRight(source[:span.end], " \t") == "---"
+}
+
+// references returns the link reference definitions of a document, by normalized label, and the
+// normalized labels of its footnote definitions.
+func references(tree *Node) (map[string]reference, map[string]bool) {
+	refs := make(map[string]reference)
+	footnotes := make(map[string]bool)
+	walkBlocks(tree.Children, func(n *Node) {
+		switch n.Kind {
+		case KindLinkReferenceDefinition:
+			if _, ok := refs[n.Label]; !ok {
+				refs[n.Label] = reference{destination: n.Destination, title: n.Title}
+			}
+		case KindFootnoteDefinition:
+			footnotes[n.Label] = true
+		}
+	})
+	return refs, footnotes
+}
+
+// containsDefinition reports whether any of the blocks is or contains a link reference definition
+// or a footnote definition.
+func containsDefinition(blocks []*Node) bool {
+	found := false
+	walkBlocks(blocks, func(n *Node) {
+		found = found || n.Kind == KindLinkReferenceDefinition || n.Kind == KindFootnoteDefinition
+	})
+	return found
+}
+
+// walkBlocks calls fn for the blocks and the blocks they contain, without descending into inlines.
+func walkBlocks(blocks []*Node, fn func(n *Node)) {
+	for _, b := range blocks {
+		Walk(b, func(n *Node) bool {
+			if !n.Kind.IsBlock() {
+				return false
+			}
+			fn(n)
+			return true
+		})
+	}
+}
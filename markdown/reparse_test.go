@@ -0,0 +1,195 @@
+package markdown
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReparse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		source string
+		// old is replaced by new at the first occurrence of old in the source.
+		old string
+		new string
+	}{
+		{
+			name:   "edit inside a paragraph",
+			source: "# Title\n\nSome *text*.\n\nMore text.\n",
+			old:    "text*",
+			new:    "VS Code*",
+		},
+		{
+			name:   "join two paragraphs",
+			source: "one\n\ntwo\n\nthree\n",
+			old:    "one\n\n",
+			new:    "one\n",
+		},
+		{
+			name:   "split a paragraph",
+			source: "one\ntwo\n\nthree\n",
+			old:    "one\n",
+			new:    "one\n\n",
+		},
+		{
+			name:   "setext underline",
+			source: "Title\n\ntext\n",
+			old:    "\n\n",
+			new:    "\n===\n",
+		},
+		{
+			name:   "open a fence",
+			source: "text\n\n# a\n\n- b\n\n> c\n",
+			old:    "text\n",
+			new:    "```\n",
+		},
+		{
+			name:   "close a fence",
+			source: "```\ncode\n\n# a\n\n- b\n",
+			old:    "code\n",
+			new:    "code\n```\n",
+		},
+		{
+			name:   "join two lists",
+			source: "- a\n\ntext\n\n- b\n\n# c\n",
+			old:    "text\n\n",
+			new:    "",
+		},
+		{
+			name:   "loosen a list",
+			source: "- a\n- b\n- c\n\n# d\n",
+			old:    "- b\n",
+			new:    "\n- b\n",
+		},
+		{
+			name:   "lazy continuation",
+			source: "> quote\n\nnext\n\n# a\n",
+			old:    "\n\nnext",
+			new:    "\nnext",
+		},
+		{
+			name:   "append to the end",
+			source: "# a\n\ntext",
+			old:    "text",
+			new:    "text\n\n- b",
+		},
+		{
+			name:   "delete everything",
+			source: "# a\n\ntext\n",
+			old:    "# a\n\ntext\n",
+			new:    "",
+		},
+		{
+			name:   "add a definition",
+			source: "[a]\n\ntext\n",
+			old:    "text",
+			new:    "[a]: /url",
+		},
+		{
+			name:   "remove a definition",
+			source: "[a]\n\n[a]: /url\n\ntext\n",
+			old:    "[a]: /url",
+			new:    "",
+		},
+		{
+			name:   "edit after definitions",
+			source: "[a]\n\n[a]: /url\ntext\n\nmore\n",
+			old:    "more",
+			new:    "more [a]",
+		},
+		{
+			name:   "add a footnote",
+			source: "text[^1]\n\nmore\n",
+			old:    "more",
+			new:    "[^1]: note",
+		},
+		{
+			name:   "edit a footnote",
+			source: "text[^1]\n\n[^1]: note\n\n    more\n\n# a\n",
+			old:    "more",
+			new:    "*more*",
+		},
+		{
+			name:   "edit after front matter",
+			source: "---\ntitle: a\n---\n# a\n\ntext\n",
+			old:    "text",
+			new:    "*text*",
+		},
+		{
+			name:   "close front matter",
+			source: "---\ntitle: a\n\ntext\n",
+			old:    "text",
+			new:    "---",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			start := strings.Index(test.source, test.old)
+			if start < 0 {
+				t.Fatalf("source %q does not contain %q", test.source, test.old)
+			}
+			source := test.source[:start] + test.new + test.source[start+len(test.old):]
+			edit := Edit{Start: start, OldEnd: start + len(test.old), NewEnd: start + len(test.new)}
+
+			got := Reparse(Parse(test.source), source, edit)
+			if want := Parse(source); !reflect.DeepEqual(got, want) {
+				t.Errorf("Reparse(%q) got a different tree than Parse", source)
+			}
+		})
+	}
+}
+
+func FuzzReparse(f *testing.F) {
+	f.Add("# a\n\nb *c*\n\n- d\n- e\n\n```\nf\n```\n", uint(10), uint(2), "\n\n> g")
+	f.Add("a\n\n[b]: /c\n\n[b]\n", uint(3), uint(0), "[d]\n")
+	f.Add("---\na\n---\n\nb\n", uint(0), uint(4), "")
+	f.Add("---\n---", uint(0), uint(7), "")
+	f.Fuzz(func(t *testing.T, old string, offset, size uint, text string) {
+		start := int(offset % uint(len(old)+1))
+		length := int(size % uint(len(old)-start+1))
+		source := old[:start] + text + old[start+length:]
+		edit := Edit{Start: start, OldEnd: start + length, NewEnd: start + len(text)}
+
+		got := Reparse(Parse(old), source, edit)
+		if want := Parse(source); !reflect.DeepEqual(got, want) {
+			t.Fatalf("Reparse(%q) of %q got a different tree than Parse", source, old)
+		}
+	})
+}
+
+// benchmarkSource returns a document of about 10k lines mixing the most common blocks.
+func benchmarkSource() string {
+	section := "## Section\n\nSome *text* with a [link](https://neovim.io) and `code`.\nIt goes on for another line.\n\n- an item\n- another item\n\n```go\nfunc main() {}\n```\n\n"
+	return strings.Repeat(section, 10_000/strings.Count(section, "\n"))
+}
+
+func BenchmarkParse(b *testing.B) {
+	source := benchmarkSource()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Parse(source)
+	}
+}
+
+func BenchmarkReparse(b *testing.B) {
+	old := benchmarkSource()
+	start := strings.Index(old[len(old)/2:], "text") + len(old)/2
+	source := old[:start] + "VS Code " + old[start:]
+	insert := Edit{Start: start, OldEnd: start, NewEnd: start + len("VS Code ")}
+	remove := Edit{Start: start, OldEnd: start + len("VS Code "), NewEnd: start}
+	tree := Parse(old)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// Every iteration types the text and then deletes it, reparsing after each edit.
+		tree = Reparse(tree, source, insert)
+		tree = Reparse(tree, old, remove)
+	}
+}
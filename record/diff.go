@@ -0,0 +1,109 @@
+package record
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// Mismatch is a message of a replay that differs from the recording. Want or Got is nil if the
+// message is missing from the replay or the recording, respectively.
+type Mismatch struct {
+	// Key identifies the message: `response <id>` or `<method> #<n>` for the nth message sent with
+	// that method.
+	Key  string
+	Want json.RawMessage
+	Got  json.RawMessage
+}
+
+func (m Mismatch) String() string {
+	switch {
+	case m.Got == nil:
+		return fmt.Sprintf("%s: missing, want %s", m.Key, m.Want)
+	case m.Want == nil:
+		return fmt.Sprintf("%s: unexpected %s", m.Key, m.Got)
+	default:
+		return fmt.Sprintf("%s:\n  got  %s\n  want %s", m.Key, m.Got, m.Want)
+	}
+}
+
+// Diff compares the messages sent by the server during a replay with the recorded ones. Responses
+// are matched by ID, since concurrent requests may be answered in any order, and other messages by
+// method, in order. Messages are compared as JSON values, so formatting and key order do not matter.
+// `$/logTrace` notifications hold timings, which differ on every run, so they are not compared.
+func Diff(want, got []json.RawMessage) []Mismatch {
+	wantKeys, wantByKey := indexMessages(want)
+	gotKeys, gotByKey := indexMessages(got)
+
+	var mismatches []Mismatch
+	for _, key := range wantKeys {
+		gotMsg, ok := gotByKey[key]
+		if !ok {
+			mismatches = append(mismatches, Mismatch{Key: key, Want: wantByKey[key]})
+			continue
+		}
+		if !equalJSON(wantByKey[key], gotMsg) {
+			mismatches = append(mismatches, Mismatch{Key: key, Want: wantByKey[key], Got: gotMsg})
+		}
+	}
+	for _, key := range gotKeys {
+		if _, ok := wantByKey[key]; !ok {
+			mismatches = append(mismatches, Mismatch{Key: key, Got: gotByKey[key]})
+		}
+	}
+	return mismatches
+}
+
+// indexMessages keys every message (see `Mismatch.Key`) and returns the keys in order.
+func indexMessages(messages []json.RawMessage) ([]string, map[string]json.RawMessage) {
+	keys := make([]string, 0, len(messages))
+	byKey := make(map[string]json.RawMessage, len(messages))
+	counts := map[string]int{}
+	for _, msg := range messages {
+		base, isResponse := messageKey(msg)
+		if base == "$/logTrace" {
+			continue
+		}
+		counts[base]++
+		key := base
+		if !isResponse || counts[base] > 1 {
+			key = fmt.Sprintf("%s #%d", base, counts[base])
+		}
+		keys = append(keys, key)
+		byKey[key] = msg
+	}
+	return keys, byKey
+}
+
+// messageKey returns `response <id>` for responses and the method of any other message. Batches
+// are keyed as a whole.
+func messageKey(msg json.RawMessage) (key string, isResponse bool) {
+	if trimmed := bytes.TrimSpace(msg); len(trimmed) > 0 && trimmed[0] == '[' {
+		return "batch", false
+	}
+
+	var message struct {
+		ID     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+	}
+	if err := json.Unmarshal(msg, &message); err != nil {
+		return "invalid", false
+	}
+	if message.Method != "" {
+		return message.Method, false
+	}
+	if message.ID == nil {
+		return "response null", true
+	}
+	return "response " + string(message.ID), true
+}
+
+// equalJSON reports whether both messages hold the same JSON value.
+func equalJSON(a, b json.RawMessage) bool {
+	var va, vb any
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return bytes.Equal(a, b)
+	}
+	return reflect.DeepEqual(va, vb)
+}
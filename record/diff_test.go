@@ -0,0 +1,76 @@
+package record
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func messages(msgs ...string) []json.RawMessage {
+	raw := make([]json.RawMessage, len(msgs))
+	for i, msg := range msgs {
+		raw[i] = json.RawMessage(msg)
+	}
+	return raw
+}
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		want []json.RawMessage
+		got  []json.RawMessage
+		keys []string
+	}{
+		"identical": {
+			want: messages(`{"id":1,"result":null}`, `{"method":"window/logMessage","params":{}}`),
+			got:  messages(`{"id":1,"result":null}`, `{"method":"window/logMessage","params":{}}`),
+		},
+		"formatting and key order": {
+			want: messages(`{"id":1,"result":{"a":1,"b":2}}`),
+			got:  messages(`{ "result": {"b": 2, "a": 1}, "id": 1 }`),
+		},
+		"responses in another order": {
+			want: messages(`{"id":1,"result":1}`, `{"id":2,"result":2}`),
+			got:  messages(`{"id":2,"result":2}`, `{"id":1,"result":1}`),
+		},
+		"changed response": {
+			want: messages(`{"id":1,"result":1}`),
+			got:  messages(`{"id":1,"result":2}`),
+			keys: []string{"response 1"},
+		},
+		"missing notification": {
+			want: messages(`{"method":"a","params":1}`, `{"method":"a","params":2}`),
+			got:  messages(`{"method":"a","params":1}`),
+			keys: []string{"a #2"},
+		},
+		"unexpected message": {
+			want: messages(),
+			got:  messages(`{"id":"x","result":null}`),
+			keys: []string{`response "x"`},
+		},
+		"trace timings": {
+			want: messages(`{"method":"$/logTrace","params":{"message":"Handled request 'shutdown - (1)' in 0ms."}}`),
+			got:  messages(`{"method":"$/logTrace","params":{"message":"Handled request 'shutdown - (1)' in 2ms."}}`),
+		},
+		"batches": {
+			want: messages(`[{"id":1,"result":1}]`),
+			got:  messages(`[{"id":1,"result":3}]`),
+			keys: []string{"batch #1"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var keys []string
+			for _, mismatch := range Diff(test.want, test.got) {
+				keys = append(keys, mismatch.Key)
+			}
+			if !reflect.DeepEqual(keys, test.keys) {
+				t.Errorf("Diff got mismatches %q, want %q", keys, test.keys)
+			}
+		})
+	}
+}
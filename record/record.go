@@ -0,0 +1,98 @@
+// Package record records the messages exchanged during an LSP session to a JSONL trace file, so
+// that a session reported in a bug can be replayed against a fresh server.
+package record
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Direction tells whether a message was sent by the client or by the server.
+type Direction string
+
+const (
+	// Inbound messages are sent by the client to the server.
+	Inbound Direction = "in"
+	// Outbound messages are sent by the server to the client.
+	Outbound Direction = "out"
+)
+
+// Entry is a single line of a trace file.
+type Entry struct {
+	Time      time.Time       `json:"time"`
+	Direction Direction       `json:"direction"`
+	Message   json.RawMessage `json:"message"`
+}
+
+// Recorder writes the messages of a session to a trace file, one JSON entry per line. It is safe
+// for concurrent use.
+type Recorder struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+	// now returns the time of the entries. It is replaced in tests.
+	now func() time.Time
+}
+
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{
+		encoder: json.NewEncoder(w),
+		now:     time.Now,
+	}
+}
+
+// Record appends the content of a message to the trace.
+func (r *Recorder) Record(direction Direction, message []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err := r.encoder.Encode(Entry{
+		Time:      r.now().UTC(),
+		Direction: direction,
+		Message:   message,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to record %s message: %w", direction, err)
+	}
+	return nil
+}
+
+// ReadTrace reads every entry of a trace file.
+func ReadTrace(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	scanner := bufio.NewScanner(r)
+	// Messages hold whole documents, so lines may be much longer than the default limit.
+	scanner.Buffer(nil, 64<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("invalid trace entry on line %d: %w", line, err)
+		}
+		if entry.Direction != Inbound && entry.Direction != Outbound {
+			return nil, fmt.Errorf("invalid trace entry on line %d: unknown direction '%s'", line, entry.Direction)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read trace: %w", err)
+	}
+	return entries, nil
+}
+
+// Messages returns the messages of the entries sent in the given direction, in order.
+func Messages(entries []Entry, direction Direction) []json.RawMessage {
+	var messages []json.RawMessage
+	for _, entry := range entries {
+		if entry.Direction == direction {
+			messages = append(messages, entry.Message)
+		}
+	}
+	return messages
+}
@@ -0,0 +1,109 @@
+package record
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecorder(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	recorder := NewRecorder(&buf)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	recorder.now = func() time.Time { return now }
+
+	if err := recorder.Record(Inbound, []byte(`{"jsonrpc":"2.0","id":1,"method":"shutdown"}`)); err != nil {
+		t.Fatalf("Record got unexpected error: %v", err)
+	}
+	if err := recorder.Record(Outbound, []byte(`{"jsonrpc":"2.0","id":1,"result":null}`)); err != nil {
+		t.Fatalf("Record got unexpected error: %v", err)
+	}
+
+	want := `{"time":"2024-05-01T12:00:00Z","direction":"in","message":{"jsonrpc":"2.0","id":1,"method":"shutdown"}}
+{"time":"2024-05-01T12:00:00Z","direction":"out","message":{"jsonrpc":"2.0","id":1,"result":null}}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("Record got\n%s\nwant\n%s", got, want)
+	}
+
+	entries, err := ReadTrace(&buf)
+	if err != nil {
+		t.Fatalf("ReadTrace got unexpected error: %v", err)
+	}
+	wantEntries := []Entry{
+		{Time: now, Direction: Inbound, Message: json.RawMessage(`{"jsonrpc":"2.0","id":1,"method":"shutdown"}`)},
+		{Time: now, Direction: Outbound, Message: json.RawMessage(`{"jsonrpc":"2.0","id":1,"result":null}`)},
+	}
+	if !reflect.DeepEqual(entries, wantEntries) {
+		t.Errorf("ReadTrace got %+v, want %+v", entries, wantEntries)
+	}
+}
+
+func TestRecorderInvalidMessage(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	if err := NewRecorder(&buf).Record(Inbound, []byte(`{"jsonrpc":`)); err == nil {
+		t.Error("Record got nil error, want an error")
+	}
+}
+
+func TestReadTrace(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		trace   string
+		want    int
+		wantErr bool
+	}{
+		"empty": {
+			trace: "",
+			want:  0,
+		},
+		"skips blank lines": {
+			trace: `{"direction":"in","message":{}}` + "\n\n" + `{"direction":"out","message":{}}` + "\n",
+			want:  2,
+		},
+		"invalid json": {
+			trace:   `{"direction":"in",`,
+			wantErr: true,
+		},
+		"unknown direction": {
+			trace:   `{"direction":"sideways","message":{}}`,
+			wantErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			entries, err := ReadTrace(strings.NewReader(test.trace))
+			if (err != nil) != test.wantErr {
+				t.Fatalf("ReadTrace got error %v, wantErr %v", err, test.wantErr)
+			}
+			if len(entries) != test.want {
+				t.Errorf("ReadTrace got %d entries, want %d", len(entries), test.want)
+			}
+		})
+	}
+}
+
+func TestMessages(t *testing.T) {
+	t.Parallel()
+
+	entries := []Entry{
+		{Direction: Inbound, Message: json.RawMessage(`1`)},
+		{Direction: Outbound, Message: json.RawMessage(`2`)},
+		{Direction: Inbound, Message: json.RawMessage(`3`)},
+	}
+	want := []json.RawMessage{json.RawMessage(`1`), json.RawMessage(`3`)}
+	if got := Messages(entries, Inbound); !reflect.DeepEqual(got, want) {
+		t.Errorf("Messages got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,27 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// IsBatch reports whether the content part of a message is a JSON-RPC batch, i.e. an array of
+// messages rather than a single one.
+func IsBatch(content []byte) bool {
+	content = bytes.TrimLeft(content, " \t\r\n")
+	return len(content) > 0 && content[0] == '['
+}
+
+// DecodeBatch splits the content of a batch into its messages, which can then be decoded with
+// `DecodeContent`. The messages are not checked, so they may be any JSON value.
+//
+// As per the JSON-RPC specification, a batch is an array of requests and notifications:
+// https://www.jsonrpc.org/specification#batch
+func DecodeBatch(content []byte) ([]json.RawMessage, error) {
+	var messages []json.RawMessage
+	if err := json.Unmarshal(content, &messages); err != nil {
+		return nil, fmt.Errorf("unable to parse batch '%s': %w", content, err)
+	}
+	return messages, nil
+}
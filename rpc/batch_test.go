@@ -0,0 +1,93 @@
+package rpc
+
+import (
+	"testing"
+)
+
+func TestIsBatch(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		content string
+		want    bool
+	}{
+		{
+			name:    "single message",
+			content: `{"method":"initialized"}`,
+			want:    false,
+		},
+		{
+			name:    "batch",
+			content: `[{"method":"initialized"}]`,
+			want:    true,
+		},
+		{
+			name:    "batch with leading whitespace",
+			content: "\r\n  [{\"method\":\"initialized\"}]",
+			want:    true,
+		},
+		{
+			name:    "empty content",
+			content: "",
+			want:    false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := IsBatch([]byte(tc.content)); got != tc.want {
+				t.Errorf("IsBatch got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDecodeBatch(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		content string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "requests and notifications",
+			content: `[{"id":1,"method":"a"}, {"method":"b"}]`,
+			want:    []string{`{"id":1,"method":"a"}`, `{"method":"b"}`},
+		},
+		{
+			name:    "invalid messages are kept",
+			content: `[1,"two",null]`,
+			want:    []string{`1`, `"two"`, `null`},
+		},
+		{
+			name:    "empty batch",
+			content: `[]`,
+			want:    []string{},
+		},
+		{
+			name:    "malformed batch",
+			content: `[{"id":1,"method":"a"},`,
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := DecodeBatch([]byte(tc.content))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("DecodeBatch got error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("DecodeBatch got %d messages, want %d", len(got), len(tc.want))
+			}
+			for i := range got {
+				if string(got[i]) != tc.want[i] {
+					t.Errorf("DecodeBatch message #%d got %s, want %s", i, got[i], tc.want[i])
+				}
+			}
+		})
+	}
+}
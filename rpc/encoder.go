@@ -0,0 +1,64 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// ErrEncoding is returned when a message cannot be encoded as JSON, e.g. because it holds a NaN.
+// Nothing is written to the stream in that case, so it is safe to keep using it.
+var ErrEncoding = errors.New("unable to encode message")
+
+// Encoder writes framed messages to a stream. The messages are encoded into a buffer that is
+// reused between calls, so encoding does not allocate a new string for every message.
+//
+// An Encoder is not safe for concurrent use, see `Writer` for that.
+type Encoder struct {
+	writer  io.Writer
+	buf     bytes.Buffer
+	content bytes.Buffer
+	json    *json.Encoder
+}
+
+func NewEncoder(w io.Writer) *Encoder {
+	e := &Encoder{
+		writer: w,
+	}
+	e.json = json.NewEncoder(&e.content)
+	return e
+}
+
+// Encode writes the messages to the stream with a single write. If any of them cannot be encoded,
+// an error wrapping `ErrEncoding` is returned and none of them are written.
+func (e *Encoder) Encode(msgs ...any) error {
+	e.buf.Reset()
+	for _, msg := range msgs {
+		if err := e.encode(msg); err != nil {
+			return err
+		}
+	}
+
+	_, err := e.writer.Write(e.buf.Bytes())
+	return err
+}
+
+// encode appends the framed message to the buffer.
+func (e *Encoder) encode(msg any) error {
+	e.content.Reset()
+	if err := e.json.Encode(msg); err != nil {
+		return fmt.Errorf("%w: %w", ErrEncoding, err)
+	}
+	// `json.Encoder` terminates every value with a newline, which is not part of the content.
+	content := bytes.TrimSuffix(e.content.Bytes(), []byte("\n"))
+
+	var length [20]byte
+	e.buf.WriteString(ContentLength)
+	e.buf.Write(strconv.AppendInt(length[:0], int64(len(content)), 10))
+	e.buf.WriteString(Separator)
+	e.buf.Write(content)
+	return nil
+}
@@ -0,0 +1,86 @@
+package rpc
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestEncoderEncode(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name       string
+		msgs       []any
+		want       string
+		wantWrites int
+		wantErr    error
+	}{
+		{
+			name:       "single message",
+			msgs:       []any{"hello"},
+			want:       "Content-Length: 7\r\n\r\n\"hello\"",
+			wantWrites: 1,
+		},
+		{
+			name:       "batch of messages in a single write",
+			msgs:       []any{"hello", map[string]string{"key": "value"}},
+			want:       "Content-Length: 7\r\n\r\n\"hello\"Content-Length: 15\r\n\r\n{\"key\":\"value\"}",
+			wantWrites: 1,
+		},
+		{
+			name:       "html is escaped like json.Marshal",
+			msgs:       []any{"<a>"},
+			want:       "Content-Length: 15\r\n\r\n\"\\u003ca\\u003e\"",
+			wantWrites: 1,
+		},
+		{
+			name:    "nothing is written if a message cannot be encoded",
+			msgs:    []any{"hello", math.Inf(1)},
+			wantErr: ErrEncoding,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out countingWriter
+			err := NewEncoder(&out).Encode(tc.msgs...)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("Encode got error = %v, want %v", err, tc.wantErr)
+			}
+			if got := out.String(); got != tc.want {
+				t.Errorf("Encode got %q, want %q", got, tc.want)
+			}
+			if out.writes != tc.wantWrites {
+				t.Errorf("Encode got %d writes, want %d", out.writes, tc.wantWrites)
+			}
+		})
+	}
+}
+
+func TestEncoderReuse(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	encoder := NewEncoder(&out)
+	for _, msg := range []any{"a long message", "short", math.NaN(), 1} {
+		encoder.Encode(msg)
+	}
+
+	want := "Content-Length: 16\r\n\r\n\"a long message\"Content-Length: 7\r\n\r\n\"short\"Content-Length: 1\r\n\r\n1"
+	if got := out.String(); got != want {
+		t.Errorf("Encode got %q, want %q", got, want)
+	}
+}
+
+// countingWriter records the number of calls to Write.
+type countingWriter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return w.Buffer.Write(p)
+}
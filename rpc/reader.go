@@ -0,0 +1,109 @@
+package rpc
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DefaultMaxMessageSize is the largest content length accepted by a `Reader` by default.
+const DefaultMaxMessageSize = 64 << 20 // 64 MiB
+
+// maxHeaderSize is the largest header part accepted by a `Reader`.
+const maxHeaderSize = 8 << 10 // 8 KiB
+
+// ErrMessageTooLarge is returned when the content of a message is larger than the maximum size.
+var ErrMessageTooLarge = errors.New("message is too large")
+
+// Reader reads messages from a stream. Unlike a `bufio.Scanner`, it reads the header part first
+// and then exactly `Content-Length` bytes of content, so message sizes are only limited by
+// `MaxMessageSize`.
+type Reader struct {
+	reader *bufio.Reader
+	// MaxMessageSize is the largest content length accepted. Zero means `DefaultMaxMessageSize`.
+	MaxMessageSize int
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{
+		reader: bufio.NewReader(r),
+	}
+}
+
+// Read reads the next message and returns its content part. It returns `io.EOF` once the stream
+// ends between two messages.
+//
+// Errors wrapping `ErrMessageTooLarge` or `ErrUnsupportedCharset` only concern the message that
+// was skipped, and reading can go on. Any other error leaves the stream in an unknown state.
+func (r *Reader) Read() ([]byte, error) {
+	header, err := r.readHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := ParseHeader(header)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse header '%s': %w", header, err)
+	}
+
+	maxSize := r.MaxMessageSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxMessageSize
+	}
+	if h.ContentLength > maxSize {
+		// Skip the content so that the next message can still be read.
+		if _, err := io.CopyN(io.Discard, r.reader, int64(h.ContentLength)); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		return nil, fmt.Errorf("%w: %d bytes, the maximum is %d bytes", ErrMessageTooLarge, h.ContentLength, maxSize)
+	}
+
+	content := make([]byte, h.ContentLength)
+	if _, err := io.ReadFull(r.reader, content); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if err := h.CheckCharset(); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// readHeader reads the header part of a message, up to and excluding the separator.
+func (r *Reader) readHeader() ([]byte, error) {
+	var header []byte
+	for {
+		line, err := r.reader.ReadSlice('\n')
+		if err != nil {
+			if errors.Is(err, io.EOF) && len(header) == 0 && len(line) == 0 {
+				return nil, io.EOF
+			}
+			if errors.Is(err, bufio.ErrBufferFull) {
+				return nil, fmt.Errorf("header field is too large")
+			}
+			return nil, unexpectedEOF(err)
+		}
+
+		if bytes.Equal(line, []byte(FieldSeparator)) {
+			if len(header) == 0 {
+				// Tolerate stray line breaks between messages.
+				continue
+			}
+			return bytes.TrimSuffix(header, []byte(FieldSeparator)), nil
+		}
+
+		header = append(header, line...)
+		if len(header) > maxHeaderSize {
+			return nil, fmt.Errorf("header is larger than %d bytes", maxHeaderSize)
+		}
+	}
+}
+
+// unexpectedEOF converts an `io.EOF` in the middle of a message into `io.ErrUnexpectedEOF`.
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,82 @@
+package rpc
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReaderRead(t *testing.T) {
+	t.Parallel()
+
+	large := `{"method":"` + strings.Repeat("a", 100_000) + `"}`
+	testCases := []struct {
+		name           string
+		input          string
+		maxMessageSize int
+		want           []string
+		wantErrs       []error
+	}{
+		{
+			name:     "single message",
+			input:    "Content-Length: 17\r\n\r\n{\"Method\":\"post\"}",
+			want:     []string{`{"Method":"post"}`},
+			wantErrs: []error{nil, io.EOF},
+		},
+		{
+			name:     "consecutive messages with extra headers",
+			input:    "Content-Length: 2\r\n\r\n{}Content-Type: application/vscode-jsonrpc; charset=utf-8\r\ncontent-length: 4\r\n\r\nnull",
+			want:     []string{`{}`, `null`},
+			wantErrs: []error{nil, nil, io.EOF},
+		},
+		{
+			name:     "message larger than a scanner token",
+			input:    "Content-Length: 100013\r\n\r\n" + large,
+			want:     []string{large},
+			wantErrs: []error{nil, io.EOF},
+		},
+		{
+			name:           "message over the maximum size is skipped",
+			input:          "Content-Length: 10\r\n\r\n0123456789Content-Length: 2\r\n\r\n{}",
+			maxMessageSize: 5,
+			want:           []string{"", `{}`},
+			wantErrs:       []error{ErrMessageTooLarge, nil, io.EOF},
+		},
+		{
+			name:     "unsupported charset is skipped",
+			input:    "Content-Length: 2\r\nContent-Type: text/plain; charset=latin1\r\n\r\n{}Content-Length: 4\r\n\r\nnull",
+			want:     []string{"", `null`},
+			wantErrs: []error{ErrUnsupportedCharset, nil, io.EOF},
+		},
+		{
+			name:     "truncated content",
+			input:    "Content-Length: 10\r\n\r\n{}",
+			want:     []string{},
+			wantErrs: []error{io.ErrUnexpectedEOF},
+		},
+		{
+			name:     "truncated header",
+			input:    "Content-Length: 10\r\n",
+			want:     []string{},
+			wantErrs: []error{io.ErrUnexpectedEOF},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := NewReader(strings.NewReader(tc.input))
+			r.MaxMessageSize = tc.maxMessageSize
+
+			for i, wantErr := range tc.wantErrs {
+				got, err := r.Read()
+				if !errors.Is(err, wantErr) {
+					t.Fatalf("Read #%d got error = %v, want %v", i, err, wantErr)
+				}
+				if err == nil && string(got) != tc.want[i] {
+					t.Errorf("Read #%d got = %.40q, want %.40q", i, got, tc.want[i])
+				}
+			}
+		})
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"mime"
 	"strconv"
+	"strings"
 )
 
 const (
@@ -13,26 +15,96 @@ const (
 	ContentLength = "Content-Length: "
 	// Separator is the separator between the header and the content for an RPC message.
 	Separator = "\r\n\r\n"
+	// FieldSeparator is the separator between the fields of the header.
+	FieldSeparator = "\r\n"
 )
 
+// ErrUnsupportedCharset is returned when the `Content-Type` header asks for a charset other than UTF-8.
+var ErrUnsupportedCharset = errors.New("unsupported charset")
+
+// Header holds the fields of the header part of a message.
+type Header struct {
+	// ContentLength is the length of the content part in bytes.
+	ContentLength int
+	// ContentType is the media type of the content part, if it was sent.
+	ContentType string
+}
+
+// ParseHeader parses the header part of a message (without the trailing separator). It is made of
+// `\r\n` separated `name: value` fields, whose names are case insensitive and may come in any
+// order. Unknown fields are ignored, but `Content-Length` is required.
+func ParseHeader(header []byte) (Header, error) {
+	var h Header
+	hasContentLength := false
+	for _, field := range strings.Split(string(header), FieldSeparator) {
+		if field == "" {
+			continue
+		}
+
+		name, value, found := strings.Cut(field, ":")
+		if !found {
+			return Header{}, fmt.Errorf("malformed header field '%s'", field)
+		}
+		value = strings.TrimSpace(value)
+
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "content-length":
+			contentLength, err := strconv.Atoi(value)
+			if err != nil || contentLength < 0 {
+				return Header{}, fmt.Errorf("invalid content length '%s'", value)
+			}
+			h.ContentLength = contentLength
+			hasContentLength = true
+		case "content-type":
+			h.ContentType = value
+		}
+	}
+
+	if !hasContentLength {
+		return Header{}, errors.New("missing Content-Length header")
+	}
+	return h, nil
+}
+
+// CheckCharset returns an error wrapping `ErrUnsupportedCharset` if the content is not encoded in
+// UTF-8, which is the only encoding supported by the LSP specification. A missing `Content-Type`
+// or charset defaults to UTF-8.
+func (h Header) CheckCharset() error {
+	if h.ContentType == "" {
+		return nil
+	}
+
+	_, params, err := mime.ParseMediaType(h.ContentType)
+	if err != nil {
+		return fmt.Errorf("invalid Content-Type '%s': %w", h.ContentType, err)
+	}
+
+	// `utf8` is accepted for backwards compatibility, as recommended by the specification.
+	switch charset := strings.ToLower(params["charset"]); charset {
+	case "", "utf-8", "utf8":
+		return nil
+	default:
+		return fmt.Errorf("%w '%s': only utf-8 is supported", ErrUnsupportedCharset, charset)
+	}
+}
+
 // BaseMessage has the structure that all RPC messages should follow.
 type BaseMessage struct {
 	Method string "json:\"method\""
 }
 
-// EncodeMessage encodes the message into a format that can be sent over the network.
+// EncodeMessage encodes the message into a format that can be sent over the network. It returns
+// an error wrapping `ErrEncoding` if the message cannot be encoded as JSON.
 //
 // As per the LSP specification, the message should be in the following format:
 // https://microsoft.github.io/language-server-protocol/specifications/lsp/3.17/specification/#contentPart
-func EncodeMessage(msg any) string {
+func EncodeMessage(msg any) (string, error) {
 	content, err := json.Marshal(msg)
 	if err != nil {
-		// If we are unable to encode the messages, this would be a MAJOR issue.
-		// For now, this will NOT be handled gracefully.
-		panic(err)
+		return "", fmt.Errorf("%w: %w", ErrEncoding, err)
 	}
 
-	return fmt.Sprintf("%s%d%v%s", ContentLength, len(content), Separator, content)
+	return ContentLength + strconv.Itoa(len(content)) + Separator + string(content), nil
 }
 
 // DecodeMessage decodes the message from the network into a format that can be used by the application.
@@ -46,20 +118,39 @@ func DecodeMessage(msg []byte) (method string, content []byte, err error) {
 		return "", nil, errors.New("unable to find separator in message: " + string(msg))
 	}
 
-	// Header -> `Content-Length: <number>`
-	contentLengthBytes := bytes.TrimPrefix(header, []byte(ContentLength))
-	contentLength, err := strconv.Atoi(string(contentLengthBytes))
+	h, err := ParseHeader(header)
 	if err != nil {
-		return "", nil, fmt.Errorf("unable to parse content length from header '%v': %w", header, err)
+		return "", nil, fmt.Errorf("unable to parse header '%s': %w", header, err)
+	}
+	if err := h.CheckCharset(); err != nil {
+		return "", nil, err
+	}
+	if len(content) < h.ContentLength {
+		return "", nil, fmt.Errorf("content is shorter than its length: got %d bytes, want %d", len(content), h.ContentLength)
 	}
 
-	var baseMessage BaseMessage
-	actualContent := content[:contentLength]
-	if err := json.Unmarshal(actualContent, &baseMessage); err != nil {
-		return "", nil, fmt.Errorf("unable to parse content from message '%v': %w", content, err)
+	actualContent := content[:h.ContentLength]
+	if IsBatch(actualContent) {
+		// A batch has no method of its own, its messages are decoded with `DecodeBatch`.
+		if !json.Valid(actualContent) {
+			return "", nil, fmt.Errorf("unable to parse batch from message '%s'", actualContent)
+		}
+		return "", actualContent, nil
+	}
+	method, err = DecodeContent(actualContent)
+	if err != nil {
+		return "", nil, err
 	}
+	return method, actualContent, nil
+}
 
-	return baseMessage.Method, actualContent, nil
+// DecodeContent decodes the content part of a message and returns its method. Responses have no method.
+func DecodeContent(content []byte) (method string, err error) {
+	var baseMessage BaseMessage
+	if err := json.Unmarshal(content, &baseMessage); err != nil {
+		return "", fmt.Errorf("unable to parse content from message '%s': %w", content, err)
+	}
+	return baseMessage.Method, nil
 }
 
 // SplitMessage splits the message to be read by a `bufio.Scanner`.
@@ -71,18 +162,16 @@ func SplitMessage(data []byte, _ bool) (advance int, token []byte, err error) {
 		return 0, nil, nil
 	}
 
-	// Header -> `Content-Length: <number>`
-	contentLengthBytes := bytes.TrimPrefix(header, []byte(ContentLength))
-	contentLength, err := strconv.Atoi(string(contentLengthBytes))
+	h, err := ParseHeader(header)
 	if err != nil {
-		return 0, nil, fmt.Errorf("unable to parse content length from header '%v': %w", header, err)
+		return 0, nil, fmt.Errorf("unable to parse header '%s': %w", header, err)
 	}
 
 	// Data has not been fully received yet.
-	if len(content) < contentLength {
+	if len(content) < h.ContentLength {
 		return 0, nil, nil
 	}
 
-	totalLength := len(header) + len(sep) + contentLength
+	totalLength := len(header) + len(sep) + h.ContentLength
 	return totalLength, data[:totalLength], nil
 }
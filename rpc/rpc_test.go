@@ -2,6 +2,8 @@ package rpc
 
 import (
 	"bytes"
+	"errors"
+	"math"
 	"testing"
 )
 
@@ -9,9 +11,10 @@ func TestEncodeMessage(t *testing.T) {
 	t.Parallel()
 
 	testCases := []struct {
-		name string
-		msg  any
-		want string
+		name    string
+		msg     any
+		want    string
+		wantErr error
 	}{
 		{
 			name: "simple string",
@@ -36,11 +39,24 @@ func TestEncodeMessage(t *testing.T) {
 			}{"Alice", 30},
 			want: "Content-Length: 25\r\n\r\n{\"Name\":\"Alice\",\"Age\":30}",
 		},
+		{
+			name:    "unsupported value",
+			msg:     map[string]float64{"severity": math.NaN()},
+			wantErr: ErrEncoding,
+		},
+		{
+			name:    "unsupported type",
+			msg:     make(chan int),
+			wantErr: ErrEncoding,
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			got := EncodeMessage(tc.msg)
+			got, err := EncodeMessage(tc.msg)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("EncodeMessage got error = %v, want %v", err, tc.wantErr)
+			}
 			if got != tc.want {
 				t.Errorf("EncodeMessage got %v, want %v", got, tc.want)
 			}
@@ -57,6 +73,7 @@ func TestDecodeMessage(t *testing.T) {
 		wantContent       []byte
 		wantMethod        string
 		wantContentLength int
+		wantErr           error
 	}{
 		{
 			name:              "simple message",
@@ -65,13 +82,39 @@ func TestDecodeMessage(t *testing.T) {
 			wantMethod:        "post",
 			wantContentLength: 17,
 		},
+		{
+			name:              "content type before content length",
+			msg:               []byte("Content-Type: application/vscode-jsonrpc; charset=utf-8\r\nContent-Length: 17\r\n\r\n{\"Method\":\"post\"}"),
+			wantContent:       []byte("{\"Method\":\"post\"}"),
+			wantMethod:        "post",
+			wantContentLength: 17,
+		},
+		{
+			name:              "legacy utf8 charset",
+			msg:               []byte("Content-Length: 17\r\nContent-Type: application/vscode-jsonrpc; charset=utf8\r\n\r\n{\"Method\":\"post\"}"),
+			wantContent:       []byte("{\"Method\":\"post\"}"),
+			wantMethod:        "post",
+			wantContentLength: 17,
+		},
+		{
+			name:    "unsupported charset",
+			msg:     []byte("Content-Length: 17\r\nContent-Type: application/vscode-jsonrpc; charset=utf-16\r\n\r\n{\"Method\":\"post\"}"),
+			wantErr: ErrUnsupportedCharset,
+		},
+		{
+			name:              "batch",
+			msg:               []byte("Content-Length: 19\r\n\r\n[{\"Method\":\"post\"}]"),
+			wantContent:       []byte("[{\"Method\":\"post\"}]"),
+			wantMethod:        "",
+			wantContentLength: 19,
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			gotMethod, gotContent, err := DecodeMessage(tc.msg)
-			if err != nil {
-				t.Errorf("DecodeMessage got unexpected error: %v", err)
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("DecodeMessage got error = %v, want %v", err, tc.wantErr)
 			}
 
 			gotContentLength := len(gotContent)
@@ -128,6 +171,20 @@ func TestSplitMessage(t *testing.T) {
 			wantToken:   nil,
 			wantErr:     false,
 		},
+		{
+			name:        "Lowercase header name and extra headers",
+			msg:         []byte("X-Custom: 1\r\ncontent-length:17\r\n\r\n{\"Method\":\"post\"}"),
+			wantAdvance: 51,
+			wantToken:   []byte("X-Custom: 1\r\ncontent-length:17\r\n\r\n{\"Method\":\"post\"}"),
+			wantErr:     false,
+		},
+		{
+			name:        "Missing content length",
+			msg:         []byte("Content-Type: application/vscode-jsonrpc\r\n\r\n{}"),
+			wantAdvance: 0,
+			wantToken:   nil,
+			wantErr:     true,
+		},
 		{
 			name:        "Content length greater than actual content",
 			msg:         []byte("Content-Length: 10\r\n\r\nhello"),
@@ -152,3 +209,98 @@ func TestSplitMessage(t *testing.T) {
 		})
 	}
 }
+
+func TestParseHeader(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		header  string
+		want    Header
+		wantErr bool
+	}{
+		{
+			name:   "content length only",
+			header: "Content-Length: 42",
+			want:   Header{ContentLength: 42},
+		},
+		{
+			name:   "both fields in any order and case",
+			header: "content-type: application/vscode-jsonrpc; charset=utf-8\r\nCONTENT-LENGTH: 7",
+			want:   Header{ContentLength: 7, ContentType: "application/vscode-jsonrpc; charset=utf-8"},
+		},
+		{
+			name:   "unknown fields are ignored",
+			header: "X-Trace: abc\r\nContent-Length: 3\r\nUser-Agent: test",
+			want:   Header{ContentLength: 3},
+		},
+		{
+			name:    "missing content length",
+			header:  "Content-Type: application/vscode-jsonrpc",
+			wantErr: true,
+		},
+		{
+			name:    "negative content length",
+			header:  "Content-Length: -1",
+			wantErr: true,
+		},
+		{
+			name:    "malformed field",
+			header:  "Content-Length 42",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseHeader([]byte(tc.header))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ParseHeader got error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("ParseHeader got = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHeaderCheckCharset(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		contentType string
+		wantErr     error
+	}{
+		{
+			name:        "no content type",
+			contentType: "",
+		},
+		{
+			name:        "no charset",
+			contentType: "application/vscode-jsonrpc",
+		},
+		{
+			name:        "utf-8",
+			contentType: "application/vscode-jsonrpc; charset=UTF-8",
+		},
+		{
+			name:        "quoted utf8",
+			contentType: `application/vscode-jsonrpc; charset="utf8"`,
+		},
+		{
+			name:        "latin-1",
+			contentType: "application/vscode-jsonrpc; charset=iso-8859-1",
+			wantErr:     ErrUnsupportedCharset,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Header{ContentType: tc.contentType}.CheckCharset()
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("CheckCharset got error = %v, want %v", err, tc.wantErr)
+			}
+		})
+	}
+}
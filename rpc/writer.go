@@ -0,0 +1,27 @@
+package rpc
+
+import (
+	"io"
+	"sync"
+)
+
+// Writer writes messages to a stream. It is safe for concurrent use: every call is written
+// in a single write, so responses and notifications sent from different goroutines never interleave.
+type Writer struct {
+	mu      sync.Mutex
+	encoder *Encoder
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		encoder: NewEncoder(w),
+	}
+}
+
+// Write encodes the messages and writes them to the stream. If any of them cannot be encoded,
+// an error wrapping `ErrEncoding` is returned and none of them are written.
+func (w *Writer) Write(msgs ...any) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.encoder.Encode(msgs...)
+}
@@ -0,0 +1,74 @@
+package rpc
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestWriterWrite(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	w := NewWriter(&out)
+	if err := w.Write(map[string]string{"key": "value"}); err != nil {
+		t.Fatalf("Write got unexpected error: %v", err)
+	}
+
+	want := "Content-Length: 15\r\n\r\n{\"key\":\"value\"}"
+	if got := out.String(); got != want {
+		t.Errorf("Write got = %q, want %q", got, want)
+	}
+}
+
+func TestWriterConcurrentWrites(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	w := NewWriter(&out)
+
+	const writers = 50
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.Write(map[string]string{"method": fmt.Sprintf("method/%d", i)})
+		}()
+	}
+	wg.Wait()
+
+	// Every message must still be framed correctly.
+	scanner := bufio.NewScanner(&out)
+	scanner.Split(SplitMessage)
+	count := 0
+	for scanner.Scan() {
+		if _, _, err := DecodeMessage(scanner.Bytes()); err != nil {
+			t.Fatalf("DecodeMessage got unexpected error: %v", err)
+		}
+		count++
+	}
+	if count != writers {
+		t.Errorf("got %d messages, want %d", count, writers)
+	}
+}
+
+func TestWriterError(t *testing.T) {
+	t.Parallel()
+
+	w := NewWriter(failingWriter{})
+	if err := w.Write("hello"); !errors.Is(err, errWriteFailed) {
+		t.Errorf("Write got error = %v, want %v", err, errWriteFailed)
+	}
+}
+
+var errWriteFailed = errors.New("write failed")
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errWriteFailed
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/sebastian-nunez/golang-language-server-protocol/lsp"
+	"github.com/sebastian-nunez/golang-language-server-protocol/rpc"
+)
+
+// batch collects the replies to the requests of a JSON-RPC batch, which are sent back together in
+// a single array once every request of the batch has been answered.
+//
+// A nil batch is valid: it stands for a message that was not sent in a batch.
+type batch struct {
+	mu      sync.Mutex
+	replies []json.RawMessage
+	// traces are the `$/logTrace` notifications of the messages of the batch, sent after the
+	// replies.
+	traces []any
+	// running counts the requests of the batch that are still being handled concurrently.
+	running sync.WaitGroup
+}
+
+// begin records that a request of the batch started running concurrently.
+func (b *batch) begin() {
+	if b != nil {
+		b.running.Add(1)
+	}
+}
+
+// end records that a request of the batch was answered.
+func (b *batch) end() {
+	if b != nil {
+		b.running.Done()
+	}
+}
+
+// add encodes the reply and adds it to the batch. It is encoded right away so that a reply that
+// cannot be encoded does not prevent the rest of the batch from being sent.
+func (b *batch) add(msg any) error {
+	content, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("%w: %w", rpc.ErrEncoding, err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.replies = append(b.replies, content)
+	return nil
+}
+
+// addTrace adds a `$/logTrace` notification to send once the replies have been sent.
+func (b *batch) addTrace(msg any) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.traces = append(b.traces, msg)
+}
+
+// handleBatch handles every message of a batch, in order, and sends the replies to its requests
+// once they have all been answered. Nothing is sent back if the batch only holds notifications.
+//
+// As per the JSON-RPC specification: https://www.jsonrpc.org/specification#batch
+func (s *Server) handleBatch(content []byte) {
+	messages, err := rpc.DecodeBatch(content)
+	if err != nil {
+		s.logger.Printf("Error decoding batch: %v", err)
+		s.write(lsp.NewErrorResponse(lsp.ID{}, lsp.ParseError, err.Error()))
+		return
+	}
+	if len(messages) == 0 {
+		s.write(lsp.NewErrorResponse(lsp.ID{}, lsp.InvalidRequest, "empty batch"))
+		return
+	}
+
+	s.logger.Printf("Received batch: messages=%d", len(messages))
+	b := &batch{}
+	for _, msg := range messages {
+		if s.exited {
+			break
+		}
+		s.handleMessage(msg, b)
+	}
+
+	if s.exited {
+		// `exit` already waited for the requests of the batch, and `Serve` returns once the batch
+		// is handled: the replies, such as the response to `shutdown`, must be sent right away.
+		s.sendBatch(b)
+		return
+	}
+	// The replies are sent from another goroutine so that the next messages, such as a
+	// `$/cancelRequest` for a request of this batch, can be read in the meantime.
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.sendBatch(b)
+	}()
+}
+
+// sendBatch waits for every request of the batch to be answered and sends the replies, followed
+// by the traces of the batch.
+func (s *Server) sendBatch(b *batch) {
+	b.running.Wait()
+	if len(b.replies) > 0 {
+		s.write(b.replies)
+	}
+	for _, trace := range b.traces {
+		s.write(trace)
+	}
+}
+
+// reply sends the reply to a request, or adds it to the batch the request is part of.
+func (s *Server) reply(b *batch, msg any) error {
+	if b == nil {
+		return s.write(msg)
+	}
+	return b.add(msg)
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/sebastian-nunez/golang-language-server-protocol/lsp"
+	"github.com/sebastian-nunez/golang-language-server-protocol/rpc"
+)
+
+// readReplies decodes every message written to the buffer, keeping the batches apart. Single
+// messages are returned as batches of one.
+func readReplies(t *testing.T, out *bytes.Buffer) (batches [][]testMessage) {
+	t.Helper()
+
+	reader := rpc.NewReader(out)
+	for {
+		content, err := reader.Read()
+		if err != nil {
+			return batches
+		}
+
+		var messages []testMessage
+		if rpc.IsBatch(content) {
+			err = json.Unmarshal(content, &messages)
+		} else {
+			messages = make([]testMessage, 1)
+			err = json.Unmarshal(content, &messages[0])
+		}
+		if err != nil {
+			t.Fatalf("Unmarshal got unexpected error: %v", err)
+		}
+		batches = append(batches, messages)
+	}
+}
+
+func TestServerBatch(t *testing.T) {
+	t.Parallel()
+
+	initialize := `{"jsonrpc":"2.0","id":10,"method":"initialize","params":{"capabilities":{}}}`
+	testCases := []struct {
+		name     string
+		messages []string
+		// want holds the ID (or the error code, for null IDs) of every reply, grouped by batch.
+		want [][]string
+	}{
+		{
+			name: "requests and notifications",
+			messages: []string{
+				`[` + initialize + `,
+				  {"jsonrpc":"2.0","method":"initialized","params":{}},
+				  {"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"uri":"file:///a.md","languageId":"markdown","version":1,"text":"hello"}}},
+				  {"jsonrpc":"2.0","id":1,"method":"textDocument/hover","params":{"textDocument":{"uri":"file:///a.md"},"position":{"line":0,"character":0}}},
+				  {"jsonrpc":"2.0","id":2,"method":"textDocument/completion","params":{"textDocument":{"uri":"file:///a.md"},"position":{"line":0,"character":0}}}]`,
+			},
+			want: [][]string{{"textDocument/publishDiagnostics"}, {"10", "1", "2"}},
+		},
+		{
+			name: "only notifications",
+			messages: []string{
+				initialize,
+				`[{"jsonrpc":"2.0","method":"initialized","params":{}},{"jsonrpc":"2.0","method":"$/cancelRequest","params":{"id":1}}]`,
+			},
+			want: [][]string{{"10"}},
+		},
+		{
+			name: "invalid and unknown messages",
+			messages: []string{
+				initialize,
+				`[1,{"jsonrpc":"2.0","id":3,"method":"unknown/method"},{"jsonrpc":"2.0","method":"unknown/notification"}]`,
+			},
+			want: [][]string{{"10"}, {"InvalidRequest", "3"}},
+		},
+		{
+			name: "shutdown and exit",
+			messages: []string{
+				initialize,
+				`[{"jsonrpc":"2.0","id":2,"method":"shutdown"},{"jsonrpc":"2.0","method":"exit"}]`,
+			},
+			want: [][]string{{"10"}, {"2"}},
+		},
+		{
+			name:     "empty batch",
+			messages: []string{`[]`},
+			want:     [][]string{{"InvalidRequest"}},
+		},
+		{
+			name:     "malformed batch",
+			messages: []string{`[{"jsonrpc":"2.0","method":"initialized"},`},
+			want:     [][]string{{"ParseError"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, out := newTestServer(encodeMessages(tc.messages...))
+			s.Serve(context.Background())
+
+			batches := readReplies(t, out)
+			if len(batches) != len(tc.want) {
+				t.Fatalf("got %d replies, want %d: %+v", len(batches), len(tc.want), batches)
+			}
+			for i, batch := range batches {
+				var got []string
+				for _, msg := range batch {
+					got = append(got, replyKey(msg))
+				}
+				if !sameKeys(got, tc.want[i]) {
+					t.Errorf("reply #%d got %v, want %v", i, got, tc.want[i])
+				}
+			}
+		})
+	}
+}
+
+// replyKey identifies a reply by its ID, its error code if its ID is null or its method if it
+// is a notification.
+func replyKey(msg testMessage) string {
+	switch {
+	case msg.Method != "":
+		return msg.Method
+	case msg.ID != nil:
+		return msg.ID.String()
+	case msg.Error != nil && msg.Error.Code == lsp.ParseError:
+		return "ParseError"
+	case msg.Error != nil && msg.Error.Code == lsp.InvalidRequest:
+		return "InvalidRequest"
+	default:
+		return "unknown"
+	}
+}
+
+// sameKeys reports whether both lists hold the same keys, in any order. The replies of the
+// concurrent requests of a batch may come in any order.
+func sameKeys(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	counts := map[string]int{}
+	for _, key := range got {
+		counts[key]++
+	}
+	for _, key := range want {
+		counts[key]--
+		if counts[key] < 0 {
+			return false
+		}
+	}
+	return true
+}
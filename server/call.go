@@ -0,0 +1,152 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sebastian-nunez/golang-language-server-protocol/lsp"
+)
+
+// defaultCallTimeout is how long the server waits for the client to answer one of its requests.
+const defaultCallTimeout = 30 * time.Second
+
+// ErrSessionEnded is returned by `Call` when the session ends (or is shut down) before the client
+// answered.
+var ErrSessionEnded = errors.New("session ended")
+
+// outgoingRequest is a request sent by the server to the client.
+type outgoingRequest struct {
+	lsp.Request
+	Params any `json:"params,omitempty"`
+}
+
+// callResult is the response of the client to a request sent by the server.
+type callResult struct {
+	result json.RawMessage
+	err    *lsp.ResponseError
+}
+
+// Call sends a request to the client and waits for its response, whose result is decoded into
+// result (unless it is nil). If the client answers with an error, it is returned as an
+// `*lsp.ResponseError`.
+//
+// The call fails if the context is done, if the client does not answer within the call timeout
+// or if the session ends or is shut down. In the first two cases the client is sent a
+// `$/cancelRequest`.
+//
+// Call must not be used by handlers that run on the reading goroutine (notifications and in-order
+// requests), since the response could then never be read. They should call it from a new goroutine.
+func (s *Server) Call(ctx context.Context, method string, params, result any) error {
+	select {
+	case <-s.shuttingDown:
+		return fmt.Errorf("%s request: %w", method, ErrSessionEnded)
+	default:
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.callTimeout)
+	defer cancel()
+
+	id := lsp.NewIntID(s.nextID.Add(1))
+	done := make(chan callResult, 1)
+	s.mu.Lock()
+	s.pending[id] = done
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, id)
+		s.mu.Unlock()
+	}()
+
+	s.logger.Printf("Sending request: method=%v, id=%v", method, id)
+	err := s.write(outgoingRequest{
+		Request: lsp.Request{
+			RPC:    "2.0",
+			ID:     id,
+			Method: method,
+		},
+		Params: params,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to send %s request: %w", method, err)
+	}
+
+	select {
+	case res := <-done:
+		if res.err != nil {
+			return res.err
+		}
+		if result == nil {
+			return nil
+		}
+		if err := json.Unmarshal(res.result, result); err != nil {
+			return fmt.Errorf("invalid %s result: %w", method, err)
+		}
+		return nil
+	case <-ctx.Done():
+		// The client is told that the response is no longer awaited.
+		s.write(lsp.CancelRequestNotification{
+			Notification: lsp.Notification{
+				RPC:    "2.0",
+				Method: "$/cancelRequest",
+			},
+			Params: lsp.CancelParams{ID: id},
+		})
+		return fmt.Errorf("%s request %v: %w", method, id, ctx.Err())
+	case <-s.shuttingDown:
+		return fmt.Errorf("%s request %v: %w", method, id, ErrSessionEnded)
+	case <-s.done:
+		return fmt.Errorf("%s request %v: %w", method, id, ErrSessionEnded)
+	}
+}
+
+// handleResponse routes a response of the client to the `Call` waiting for it.
+func (s *Server) handleResponse(content []byte) {
+	var response struct {
+		ID     *lsp.ID            `json:"id"`
+		Result json.RawMessage    `json:"result"`
+		Error  *lsp.ResponseError `json:"error"`
+	}
+	if err := json.Unmarshal(content, &response); err != nil || response.ID == nil {
+		s.logger.Printf("Ignoring message without a method nor an ID: %s", content)
+		return
+	}
+
+	s.mu.Lock()
+	done, ok := s.pending[*response.ID]
+	delete(s.pending, *response.ID)
+	s.mu.Unlock()
+	if !ok {
+		s.logger.Printf("Ignoring response to an unknown or expired request: id=%v", *response.ID)
+		return
+	}
+	done <- callResult{result: response.Result, err: response.Error}
+}
+
+// Configuration asks the client for the configuration of every item (`workspace/configuration`).
+func (s *Server) Configuration(ctx context.Context, params lsp.ConfigurationParams) ([]json.RawMessage, error) {
+	var result []json.RawMessage
+	err := s.Call(ctx, "workspace/configuration", params, &result)
+	return result, err
+}
+
+// ApplyEdit asks the client to apply a workspace edit (`workspace/applyEdit`).
+func (s *Server) ApplyEdit(ctx context.Context, params lsp.ApplyWorkspaceEditParams) (lsp.ApplyWorkspaceEditResult, error) {
+	var result lsp.ApplyWorkspaceEditResult
+	err := s.Call(ctx, "workspace/applyEdit", params, &result)
+	return result, err
+}
+
+// ShowMessageRequest shows a message with actions to the user (`window/showMessageRequest`) and
+// returns the action that was picked, or nil if the message was dismissed.
+func (s *Server) ShowMessageRequest(ctx context.Context, params lsp.ShowMessageRequestParams) (*lsp.MessageActionItem, error) {
+	var result *lsp.MessageActionItem
+	err := s.Call(ctx, "window/showMessageRequest", params, &result)
+	return result, err
+}
+
+// RegisterCapability dynamically registers capabilities with the client (`client/registerCapability`).
+func (s *Server) RegisterCapability(ctx context.Context, params lsp.RegistrationParams) error {
+	return s.Call(ctx, "client/registerCapability", params, nil)
+}
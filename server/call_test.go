@@ -0,0 +1,247 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sebastian-nunez/golang-language-server-protocol/compiler"
+	"github.com/sebastian-nunez/golang-language-server-protocol/lsp"
+)
+
+// newCallTestServer serves a session over an in-memory connection and returns the client end.
+func newCallTestServer(t *testing.T) (*Server, *testClient) {
+	t.Helper()
+
+	serverConn, clientConn := net.Pipe()
+	s := New(serverConn, serverConn, log.New(io.Discard, "", 0), compiler.NewState())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Serve(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		clientConn.Close()
+		<-done
+	})
+	return s, newTestClient(t, clientConn)
+}
+
+// callMessage is a request sent by the server, as seen by the client.
+type callMessage struct {
+	ID     lsp.ID          `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+func (c *testClient) receiveCall() callMessage {
+	c.t.Helper()
+	content, err := c.reader.Read()
+	if err != nil {
+		c.t.Fatalf("Read got unexpected error: %v", err)
+	}
+
+	var msg callMessage
+	if err := json.Unmarshal(content, &msg); err != nil {
+		c.t.Fatalf("Unmarshal got unexpected error: %v", err)
+	}
+	return msg
+}
+
+func TestServerCall(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name       string
+		call       func(s *Server) (any, error)
+		wantMethod string
+		wantParams string
+		response   string
+		want       any
+		wantCode   *lsp.ErrorCode
+	}{
+		{
+			name: "configuration",
+			call: func(s *Server) (any, error) {
+				result, err := s.Configuration(context.Background(), lsp.ConfigurationParams{
+					Items: []lsp.ConfigurationItem{{Section: "markdown"}},
+				})
+				return fmt.Sprintf("%s", result), err
+			},
+			wantMethod: "workspace/configuration",
+			wantParams: `{"items":[{"section":"markdown"}]}`,
+			response:   `"result":[{"lint":true}]`,
+			want:       `[{"lint":true}]`,
+		},
+		{
+			name: "apply edit",
+			call: func(s *Server) (any, error) {
+				return s.ApplyEdit(context.Background(), lsp.ApplyWorkspaceEditParams{Label: "fix"})
+			},
+			wantMethod: "workspace/applyEdit",
+			wantParams: `{"label":"fix","edit":{"changes":null}}`,
+			response:   `"result":{"applied":false,"failureReason":"read only"}`,
+			want:       lsp.ApplyWorkspaceEditResult{Applied: false, FailureReason: "read only"},
+		},
+		{
+			name: "show message request dismissed",
+			call: func(s *Server) (any, error) {
+				return s.ShowMessageRequest(context.Background(), lsp.ShowMessageRequestParams{
+					Type:    lsp.MessageTypeInfo,
+					Message: "Reload?",
+					Actions: []lsp.MessageActionItem{{Title: "Yes"}},
+				})
+			},
+			wantMethod: "window/showMessageRequest",
+			wantParams: `{"type":3,"message":"Reload?","actions":[{"title":"Yes"}]}`,
+			response:   `"result":null`,
+			want:       (*lsp.MessageActionItem)(nil),
+		},
+		{
+			name: "register capability",
+			call: func(s *Server) (any, error) {
+				err := s.RegisterCapability(context.Background(), lsp.RegistrationParams{
+					Registrations: []lsp.Registration{{ID: "1", Method: "workspace/didChangeConfiguration"}},
+				})
+				return nil, err
+			},
+			wantMethod: "client/registerCapability",
+			wantParams: `{"registrations":[{"id":"1","method":"workspace/didChangeConfiguration"}]}`,
+			response:   `"result":null`,
+			want:       nil,
+		},
+		{
+			name: "error response",
+			call: func(s *Server) (any, error) {
+				return nil, s.Call(context.Background(), "custom/method", nil, nil)
+			},
+			wantMethod: "custom/method",
+			response:   `"error":{"code":-32601,"message":"method not found"}`,
+			wantCode:   errorCodePtr(lsp.MethodNotFound),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, client := newCallTestServer(t)
+
+			type outcome struct {
+				got any
+				err error
+			}
+			done := make(chan outcome, 1)
+			go func() {
+				got, err := tc.call(s)
+				done <- outcome{got, err}
+			}()
+
+			msg := client.receiveCall()
+			if msg.Method != tc.wantMethod {
+				t.Errorf("got method = %q, want %q", msg.Method, tc.wantMethod)
+			}
+			if string(msg.Params) != tc.wantParams {
+				t.Errorf("got params = %s, want %s", msg.Params, tc.wantParams)
+			}
+			id, _ := json.Marshal(msg.ID)
+			client.send(fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,%s}`, id, tc.response))
+
+			res := <-done
+			if tc.wantCode != nil {
+				var respErr *lsp.ResponseError
+				if !errors.As(res.err, &respErr) || respErr.Code != *tc.wantCode {
+					t.Fatalf("call got error = %v, want code %v", res.err, *tc.wantCode)
+				}
+				return
+			}
+			if res.err != nil {
+				t.Fatalf("call got unexpected error: %v", res.err)
+			}
+			if res.got != tc.want {
+				t.Errorf("call got = %#v, want %#v", res.got, tc.want)
+			}
+		})
+	}
+}
+
+func TestServerCallTimeout(t *testing.T) {
+	t.Parallel()
+
+	s, client := newCallTestServer(t)
+	s.callTimeout = 10 * time.Millisecond
+
+	done := make(chan error, 1)
+	go func() { done <- s.Call(context.Background(), "custom/slow", nil, nil) }()
+
+	call := client.receiveCall()
+	cancel := client.receiveCall()
+	if cancel.Method != "$/cancelRequest" || string(cancel.Params) != fmt.Sprintf(`{"id":%v}`, call.ID) {
+		t.Errorf("got message = %+v, want a cancellation of request %v", cancel, call.ID)
+	}
+	if err := <-done; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Call got error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// A late response is dropped and the session goes on.
+	client.send(fmt.Sprintf(`{"jsonrpc":"2.0","id":%v,"result":null}`, call.ID))
+	client.send(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"capabilities":{}}}`)
+	if msg := client.receive(); msg.ID == nil || *msg.ID != lsp.NewIntID(1) {
+		t.Errorf("got message = %+v, want the initialize response", msg)
+	}
+}
+
+func TestServerCallSessionEnded(t *testing.T) {
+	t.Parallel()
+
+	serverConn, clientConn := net.Pipe()
+	s := New(serverConn, serverConn, log.New(io.Discard, "", 0), compiler.NewState())
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		s.Serve(ctx)
+	}()
+
+	done := make(chan error, 1)
+	go func() { done <- s.Call(context.Background(), "custom/method", nil, nil) }()
+	newTestClient(t, clientConn).receiveCall()
+	cancel()
+	<-served
+
+	if err := <-done; !errors.Is(err, ErrSessionEnded) {
+		t.Errorf("Call got error = %v, want %v", err, ErrSessionEnded)
+	}
+}
+
+func TestServerCallShutdown(t *testing.T) {
+	t.Parallel()
+
+	s, client := newCallTestServer(t)
+	client.send(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"capabilities":{"workspace":{"applyEdit":true}}}}`)
+	client.receive()
+	client.send(`{"jsonrpc":"2.0","id":2,"method":"workspace/executeCommand","params":{"command":"golang-lsp.applyEdit","arguments":[{"changes":{}}]}}`)
+	call := client.receiveCall()
+
+	// The shutdown waits for the command, which is still waiting for the client to apply the edit.
+	client.send(`{"jsonrpc":"2.0","id":3,"method":"shutdown"}`)
+	msg := client.receive()
+	if msg.ID == nil || *msg.ID != lsp.NewIntID(2) || msg.Error == nil {
+		t.Fatalf("got message = %+v, want the executeCommand error", msg)
+	}
+	if msg := client.receive(); msg.ID == nil || *msg.ID != lsp.NewIntID(3) || msg.Error != nil {
+		t.Fatalf("got message = %+v, want the shutdown response", msg)
+	}
+
+	// The late answer of the client is dropped, and no more requests are sent to it.
+	client.send(fmt.Sprintf(`{"jsonrpc":"2.0","id":%v,"result":{"applied":true}}`, call.ID))
+	if err := s.Call(context.Background(), "custom/method", nil, nil); !errors.Is(err, ErrSessionEnded) {
+		t.Errorf("Call got error = %v, want %v", err, ErrSessionEnded)
+	}
+}
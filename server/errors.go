@@ -0,0 +1,30 @@
+package server
+
+import (
+	"context"
+	"errors"
+
+	"github.com/sebastian-nunez/golang-language-server-protocol/compiler"
+	"github.com/sebastian-nunez/golang-language-server-protocol/lsp"
+)
+
+// toResponseError maps an error returned while handling a request to the error sent to the client.
+func toResponseError(err error) *lsp.ResponseError {
+	var respErr *lsp.ResponseError
+	if errors.As(err, &respErr) {
+		return respErr
+	}
+
+	code := lsp.InternalError
+	switch {
+	case errors.Is(err, compiler.ErrDocumentNotFound):
+		code = lsp.RequestFailed
+	case errors.Is(err, compiler.ErrDocumentAlreadyOpened):
+		code = lsp.InvalidRequest
+	case errors.Is(err, compiler.ErrStaleVersion):
+		code = lsp.ContentModified
+	case errors.Is(err, context.Canceled):
+		code = lsp.RequestCancelled
+	}
+	return &lsp.ResponseError{Code: code, Message: err.Error()}
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/sebastian-nunez/golang-language-server-protocol/compiler"
+	"github.com/sebastian-nunez/golang-language-server-protocol/lsp"
+)
+
+func TestToResponseError(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		err         error
+		wantCode    lsp.ErrorCode
+		wantMessage string
+	}{
+		{
+			name:        "document not found",
+			err:         compiler.ErrDocumentNotFound,
+			wantCode:    lsp.RequestFailed,
+			wantMessage: "document was not opened",
+		},
+		{
+			name:        "wrapped stale version",
+			err:         fmt.Errorf("%w: got version 1", compiler.ErrStaleVersion),
+			wantCode:    lsp.ContentModified,
+			wantMessage: "document version is not newer than the current version: got version 1",
+		},
+		{
+			name:        "response error is kept as is",
+			err:         &lsp.ResponseError{Code: lsp.InvalidParams, Message: "bad params"},
+			wantCode:    lsp.InvalidParams,
+			wantMessage: "bad params",
+		},
+		{
+			name:        "unknown error",
+			err:         errors.New("boom"),
+			wantCode:    lsp.InternalError,
+			wantMessage: "boom",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := toResponseError(tc.err)
+			if got.Code != tc.wantCode {
+				t.Errorf("toResponseError got code = %v, want %v", got.Code, tc.wantCode)
+			}
+			if got.Message != tc.wantMessage {
+				t.Errorf("toResponseError got message = %v, want %v", got.Message, tc.wantMessage)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/sebastian-nunez/golang-language-server-protocol/compiler"
+	"github.com/sebastian-nunez/golang-language-server-protocol/lsp"
+)
+
+// register adds the handler of every method supported by the server to the registry.
+func (s *Server) register(r *registry) {
+	registerRequest(r, "initialize", s.initialize, inOrder())
+	registerNotification(r, "initialized", s.initialized)
+	registerRequest(r, "shutdown", s.shutdown, inOrder())
+	registerNotification(r, "exit", s.exitNotification)
+	registerNotification(r, "$/cancelRequest", s.cancelRequest)
+	registerNotification(r, "$/setTrace", s.setTrace)
+
+	registerNotification(r, "textDocument/didOpen", s.didOpen, withCapability(incrementalSync))
+	registerNotification(r, "textDocument/didChange", s.didChange, withCapability(incrementalSync))
+	registerRequest(r, "textDocument/hover", s.hover, withCapability(func(c *lsp.ServerCapabilities) {
+		hoverProvider := true
+		c.HoverProvider = &hoverProvider
+	}))
+	registerRequest(r, "textDocument/definition", s.definition, withCapability(func(c *lsp.ServerCapabilities) {
+		definitionProvider := true
+		c.DefinitionProvider = &definitionProvider
+	}))
+	registerRequest(r, "textDocument/codeAction", s.codeAction, withCapability(func(c *lsp.ServerCapabilities) {
+		codeActionProvider := true
+		c.CodeActionProvider = &codeActionProvider
+	}))
+	registerRequest(r, "textDocument/documentSymbol", s.documentSymbol, withCapability(func(c *lsp.ServerCapabilities) {
+		documentSymbolProvider := true
+		c.DocumentSymbolProvider = &documentSymbolProvider
+	}))
+	registerRequest(r, "textDocument/completion", s.completion, withCapability(func(c *lsp.ServerCapabilities) {
+		completionProvider := map[string]any{}
+		c.CompletionProvider = &completionProvider
+	}))
+	registerRequest(r, "workspace/symbol", s.workspaceSymbol, withCapability(func(c *lsp.ServerCapabilities) {
+		workspaceSymbolProvider := true
+		c.WorkspaceSymbolProvider = &workspaceSymbolProvider
+	}))
+	registerRequest(r, "workspace/executeCommand", s.executeCommand, withCapability(func(c *lsp.ServerCapabilities) {
+		c.ExecuteCommandProvider = &lsp.ExecuteCommandOptions{Commands: []string{compiler.ApplyEditCommand}}
+	}))
+}
+
+func incrementalSync(c *lsp.ServerCapabilities) {
+	textDocumentSync := lsp.TextDocumentSyncIncremental
+	c.TextDocumentSync = &textDocumentSync
+}
+
+func (s *Server) initialize(_ context.Context, _ lsp.ID, params lsp.InitializeParams) (lsp.InitializeResult, error) {
+	if info := params.ClientInfo; info != nil {
+		version := "unknown"
+		if info.Version != nil && *info.Version != "" {
+			version = *info.Version
+		}
+		s.logger.Printf("Connected to client: %s (version=%s)", info.Name, version)
+	}
+
+	var offered []lsp.PositionEncodingKind
+	if params.Capabilities.General != nil {
+		offered = params.Capabilities.General.PositionEncodings
+	}
+	encoding := compiler.NegotiatePositionEncoding(offered)
+	s.state.SetPositionEncoding(encoding)
+	s.state.SetClientCapabilities(params.Capabilities)
+	s.state.SetWorkspaceFolders(workspaceFolders(params))
+	s.clientCapabilities = params.Capabilities
+	if params.Trace != nil {
+		if validTrace(*params.Trace) {
+			s.trace.Store(*params.Trace)
+		} else {
+			s.logger.Printf("Ignoring unknown trace value: %s", *params.Trace)
+		}
+	}
+
+	capabilities := s.registry.capabilities()
+	capabilities.PositionEncoding = &encoding
+	s.lifecycle.state = stateInitialized
+	s.logger.Printf("Initialized: positionEncoding=%s", encoding)
+	return lsp.NewInitializeResult(capabilities), nil
+}
+
+// workspaceFolders returns the folders opened by the client. Clients that predate workspace folders
+// only send the root of the workspace.
+func workspaceFolders(params lsp.InitializeParams) []lsp.DocumentURI {
+	if len(params.WorkspaceFolders) > 0 {
+		folders := make([]lsp.DocumentURI, 0, len(params.WorkspaceFolders))
+		for _, folder := range params.WorkspaceFolders {
+			folders = append(folders, lsp.DocumentURI(folder.URI))
+		}
+		return folders
+	}
+	if params.RootUri != nil && *params.RootUri != "" {
+		return []lsp.DocumentURI{lsp.DocumentURI(*params.RootUri)}
+	}
+	if params.RootPath != nil && *params.RootPath != "" {
+		return []lsp.DocumentURI{lsp.URIFromPath(*params.RootPath)}
+	}
+	return nil
+}
+
+func (s *Server) initialized(_ context.Context, _ struct{}) error {
+	s.logger.Println("Client finished initializing")
+	return nil
+}
+
+func (s *Server) shutdown(_ context.Context, _ lsp.ID, _ struct{}) (any, error) {
+	s.lifecycle.state = stateShuttingDown
+	// Let the requests that are still running answer before acknowledging the shutdown. Messages
+	// are not read in the meantime, so the requests waiting for the client to answer one of the
+	// server's requests are given up on.
+	close(s.shuttingDown)
+	s.wg.Wait()
+	return nil, nil
+}
+
+func (s *Server) exitNotification(_ context.Context, _ struct{}) error {
+	s.exit()
+	return nil
+}
+
+func (s *Server) cancelRequest(_ context.Context, params lsp.CancelParams) error {
+	s.cancel(params.ID)
+	return nil
+}
+
+func (s *Server) didOpen(_ context.Context, params lsp.DidOpenTextDocumentParams) error {
+	document := params.TextDocument
+	s.logger.Printf("Opened text document: URI=%v, version=%d", document.URI, document.Version)
+	diagnostics, err := s.state.OpenDocument(document.URI, document.Version, document.Text)
+	if err != nil {
+		// The diagnostics of a document that could not be opened are left as they were.
+		s.logger.Printf("Error opening document: %v", err)
+		return nil
+	}
+
+	s.publishDiagnostics(document.URI, document.Version, diagnostics)
+	return nil
+}
+
+func (s *Server) didChange(_ context.Context, params lsp.DidChangeTextDocumentParams) error {
+	document := params.TextDocument
+	s.logger.Printf("Changed text document: URI=%v, version=%d, changes=%d", document.URI, document.Version, len(params.ContentChanges))
+	diagnostics, err := s.state.UpdateDocument(document.URI, document.Version, params.ContentChanges)
+	if errors.Is(err, compiler.ErrStaleVersion) {
+		s.logger.Printf("Ignoring out-of-order change: %v", err)
+		return nil
+	}
+	if err != nil {
+		s.logger.Printf("Error updating document: %v", err)
+		return nil
+	}
+
+	s.publishDiagnostics(document.URI, document.Version, diagnostics)
+	return nil
+}
+
+func (s *Server) hover(ctx context.Context, id lsp.ID, params lsp.HoverParams) (*lsp.HoverResult, error) {
+	s.logger.Printf("Hovered over text document: URI=%v, character=%v, line=%v",
+		params.TextDocument.URI,
+		params.Position.Character,
+		params.Position.Line,
+	)
+
+	response, err := s.state.Hover(ctx, params.TextDocument.URI, id, params.Position)
+	if err != nil {
+		return nil, err
+	}
+	return response.Result, nil
+}
+
+func (s *Server) definition(ctx context.Context, id lsp.ID, params lsp.DefinitionParams) (*lsp.Location, error) {
+	s.logger.Printf("Definition of text document: URI=%v, character=%v, line=%v",
+		params.TextDocument.URI,
+		params.Position.Character,
+		params.Position.Line,
+	)
+
+	response, err := s.state.Definition(ctx, params.TextDocument.URI, id, params.Position)
+	if err != nil {
+		return nil, err
+	}
+	return response.Result, nil
+}
+
+func (s *Server) codeAction(ctx context.Context, id lsp.ID, params lsp.TextDocumentCodeActionParams) (any, error) {
+	response, err := s.state.TextDocumentCodeAction(ctx, id, params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	return response.Result, nil
+}
+
+func (s *Server) documentSymbol(ctx context.Context, id lsp.ID, params lsp.DocumentSymbolParams) (any, error) {
+	response, err := s.state.DocumentSymbol(ctx, params.TextDocument.URI, id)
+	if err != nil {
+		return nil, err
+	}
+	return response.Result, nil
+}
+
+func (s *Server) completion(ctx context.Context, id lsp.ID, params lsp.CompletionParams) ([]lsp.CompletionItem, error) {
+	response := s.state.TextDocumentCompletion(ctx, id, params.TextDocument.URI)
+	return response.Result, nil
+}
+
+func (s *Server) workspaceSymbol(ctx context.Context, id lsp.ID, params lsp.WorkspaceSymbolParams) ([]lsp.SymbolInformation, error) {
+	response, err := s.state.WorkspaceSymbol(ctx, id, params.Query)
+	if err != nil {
+		return nil, err
+	}
+	return response.Result, nil
+}
+
+func (s *Server) executeCommand(ctx context.Context, _ lsp.ID, params lsp.ExecuteCommandParams) (any, error) {
+	s.logger.Printf("Executing command: command=%v, arguments=%d", params.Command, len(params.Arguments))
+	if params.Command != compiler.ApplyEditCommand {
+		return nil, &lsp.ResponseError{Code: lsp.InvalidParams, Message: "unknown command: " + params.Command}
+	}
+
+	var edit lsp.WorkspaceEdit
+	if len(params.Arguments) != 1 || json.Unmarshal(params.Arguments[0], &edit) != nil {
+		return nil, &lsp.ResponseError{Code: lsp.InvalidParams, Message: "expected a single workspace edit argument"}
+	}
+	if workspace := s.clientCapabilities.Workspace; workspace == nil || !workspace.ApplyEdit {
+		return nil, &lsp.ResponseError{Code: lsp.RequestFailed, Message: "the client does not support workspace/applyEdit"}
+	}
+
+	result, err := s.ApplyEdit(ctx, lsp.ApplyWorkspaceEditParams{Edit: edit})
+	if err != nil {
+		return nil, err
+	}
+	if !result.Applied {
+		return nil, &lsp.ResponseError{Code: lsp.RequestFailed, Message: "the edit was not applied: " + result.FailureReason}
+	}
+	return nil, nil
+}
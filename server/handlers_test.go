@@ -0,0 +1,191 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sebastian-nunez/golang-language-server-protocol/lsp"
+)
+
+func TestServerExecuteCommand(t *testing.T) {
+	t.Parallel()
+
+	executeCommand := `{"jsonrpc":"2.0","id":2,"method":"workspace/executeCommand","params":{"command":"golang-lsp.applyEdit","arguments":[{"changes":{"file:///a.md":[{"range":{"start":{"line":0,"character":0},"end":{"line":0,"character":7}},"newText":"Neovim"}]}}]}}`
+	testCases := []struct {
+		name         string
+		capabilities string
+		command      string
+		// applyEdit is the answer of the client to `workspace/applyEdit`, if it is asked.
+		applyEdit string
+		wantCode  *lsp.ErrorCode
+	}{
+		{
+			name:         "edit applied by the client",
+			capabilities: `{"workspace":{"applyEdit":true}}`,
+			command:      executeCommand,
+			applyEdit:    `{"applied":true}`,
+		},
+		{
+			name:         "edit rejected by the client",
+			capabilities: `{"workspace":{"applyEdit":true}}`,
+			command:      executeCommand,
+			applyEdit:    `{"applied":false,"failureReason":"read only"}`,
+			wantCode:     errorCodePtr(lsp.RequestFailed),
+		},
+		{
+			name:         "client without workspace/applyEdit",
+			capabilities: `{}`,
+			command:      executeCommand,
+			wantCode:     errorCodePtr(lsp.RequestFailed),
+		},
+		{
+			name:         "unknown command",
+			capabilities: `{"workspace":{"applyEdit":true}}`,
+			command:      `{"jsonrpc":"2.0","id":2,"method":"workspace/executeCommand","params":{"command":"unknown"}}`,
+			wantCode:     errorCodePtr(lsp.InvalidParams),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, client := newCallTestServer(t)
+			client.send(fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"capabilities":%s}}`, tc.capabilities))
+			client.receive()
+			client.send(tc.command)
+
+			if tc.applyEdit != "" {
+				call := client.receiveCall()
+				if call.Method != "workspace/applyEdit" {
+					t.Fatalf("got request %q, want workspace/applyEdit", call.Method)
+				}
+				client.send(fmt.Sprintf(`{"jsonrpc":"2.0","id":%v,"result":%s}`, call.ID, tc.applyEdit))
+			}
+
+			msg := client.receive()
+			if msg.ID == nil || *msg.ID != lsp.NewIntID(2) {
+				t.Fatalf("got message = %+v, want the executeCommand response", msg)
+			}
+			if tc.wantCode == nil && msg.Error != nil {
+				t.Errorf("executeCommand got error = %v", msg.Error)
+			}
+			if tc.wantCode != nil && (msg.Error == nil || msg.Error.Code != *tc.wantCode) {
+				t.Errorf("executeCommand got response = %+v, want code %v", msg, *tc.wantCode)
+			}
+		})
+	}
+}
+
+func TestWorkspaceFolders(t *testing.T) {
+	t.Parallel()
+
+	root := "file:///home/user/root"
+	path := filepath.FromSlash("/home/user/path")
+	testCases := []struct {
+		name   string
+		params lsp.InitializeParams
+		want   []lsp.DocumentURI
+	}{
+		{
+			name: "workspace folders",
+			params: lsp.InitializeParams{
+				RootUri:          &root,
+				WorkspaceFolders: []lsp.WorkspaceFolder{{URI: "file:///a", Name: "a"}, {URI: "file:///b", Name: "b"}},
+			},
+			want: []lsp.DocumentURI{"file:///a", "file:///b"},
+		},
+		{
+			name:   "root URI",
+			params: lsp.InitializeParams{RootUri: &root, RootPath: &path},
+			want:   []lsp.DocumentURI{"file:///home/user/root"},
+		},
+		{
+			name:   "root path",
+			params: lsp.InitializeParams{RootPath: &path},
+			want:   []lsp.DocumentURI{"file:///home/user/path"},
+		},
+		{
+			name:   "no workspace",
+			params: lsp.InitializeParams{},
+			want:   nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := workspaceFolders(tc.params); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("workspaceFolders got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestServerDiagnostics(t *testing.T) {
+	t.Parallel()
+
+	initialize := `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"capabilities":{}}}`
+	didOpen := `{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"uri":"file:///a.md","languageId":"markdown","version":1,"text":"I use VS Code"}}}`
+	testCases := []struct {
+		name     string
+		messages []string
+		// want holds the URI and version of every diagnostics notification.
+		want []string
+	}{
+		{
+			name:     "open",
+			messages: []string{initialize, didOpen},
+			want:     []string{"file:///a.md@1"},
+		},
+		{
+			name: "change",
+			messages: []string{initialize, didOpen,
+				`{"jsonrpc":"2.0","method":"textDocument/didChange","params":{"textDocument":{"uri":"file:///a.md","version":2},"contentChanges":[{"text":"hello"}]}}`,
+			},
+			want: []string{"file:///a.md@1", "file:///a.md@2"},
+		},
+		{
+			name:     "opened twice",
+			messages: []string{initialize, didOpen, didOpen},
+			want:     []string{"file:///a.md@1"},
+		},
+		{
+			name: "change to a document that is not open",
+			messages: []string{initialize,
+				`{"jsonrpc":"2.0","method":"textDocument/didChange","params":{"textDocument":{"uri":"file:///nope.md","version":2},"contentChanges":[{"text":"hello"}]}}`,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s, out := newTestServer(encodeMessages(tc.messages...))
+			s.Serve(context.Background())
+
+			var got []string
+			for _, msg := range readMessages(t, out) {
+				if msg.Method != "textDocument/publishDiagnostics" {
+					continue
+				}
+				var params lsp.PublishDiagnosticsParams
+				if err := json.Unmarshal(msg.Params, &params); err != nil {
+					t.Fatalf("Unmarshal got unexpected error: %v", err)
+				}
+				if params.Diagnostics == nil || params.Version == nil {
+					t.Errorf("got diagnostics = %s, want an array and a version", msg.Params)
+					continue
+				}
+				got = append(got, fmt.Sprintf("%s@%d", params.URI, *params.Version))
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got diagnostics for %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"github.com/sebastian-nunez/golang-language-server-protocol/lsp"
+)
+
+// lifecycleState is the stage of the LSP lifecycle the server is in.
+type lifecycleState int
+
+const (
+	// stateUninitialized is the state before the `initialize` request was answered.
+	stateUninitialized lifecycleState = iota
+	// stateInitialized is the state in which all requests are served.
+	stateInitialized
+	// stateShuttingDown is the state after the `shutdown` request, waiting for `exit`.
+	stateShuttingDown
+)
+
+// lifecycle tracks the state of the server as described by the LSP specification:
+// https://microsoft.github.io/language-server-protocol/specifications/lsp/3.17/specification/#lifeCycleMessages
+type lifecycle struct {
+	state lifecycleState
+}
+
+// check reports whether a message for the method can be handled in the current state. The
+// returned error is meant to be sent back to the client when the message is a request.
+func (l *lifecycle) check(method string) *lsp.ResponseError {
+	if method == "exit" {
+		return nil
+	}
+
+	switch l.state {
+	case stateUninitialized:
+		if method != "initialize" {
+			return &lsp.ResponseError{Code: lsp.ServerNotInitialized, Message: "server has not been initialized"}
+		}
+	case stateInitialized:
+		if method == "initialize" {
+			return &lsp.ResponseError{Code: lsp.InvalidRequest, Message: "server was already initialized"}
+		}
+	case stateShuttingDown:
+		return &lsp.ResponseError{Code: lsp.InvalidRequest, Message: "server is shutting down"}
+	}
+	return nil
+}
+
+// exitError returns nil if the `shutdown` request was received before exiting and
+// `ErrExitWithoutShutdown` otherwise.
+func (l *lifecycle) exitError() error {
+	if l.state == stateShuttingDown {
+		return nil
+	}
+	return ErrExitWithoutShutdown
+}
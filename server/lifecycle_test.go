@@ -0,0 +1,122 @@
+package server
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sebastian-nunez/golang-language-server-protocol/lsp"
+)
+
+func TestLifecycleCheck(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		state    lifecycleState
+		method   string
+		wantCode *lsp.ErrorCode
+	}{
+		{
+			name:     "initialize before initialization",
+			state:    stateUninitialized,
+			method:   "initialize",
+			wantCode: nil,
+		},
+		{
+			name:     "request before initialization",
+			state:    stateUninitialized,
+			method:   "textDocument/hover",
+			wantCode: errorCodePtr(lsp.ServerNotInitialized),
+		},
+		{
+			name:     "exit before initialization",
+			state:    stateUninitialized,
+			method:   "exit",
+			wantCode: nil,
+		},
+		{
+			name:     "request after initialization",
+			state:    stateInitialized,
+			method:   "textDocument/hover",
+			wantCode: nil,
+		},
+		{
+			name:     "initialize twice",
+			state:    stateInitialized,
+			method:   "initialize",
+			wantCode: errorCodePtr(lsp.InvalidRequest),
+		},
+		{
+			name:     "request after shutdown",
+			state:    stateShuttingDown,
+			method:   "textDocument/hover",
+			wantCode: errorCodePtr(lsp.InvalidRequest),
+		},
+		{
+			name:     "shutdown twice",
+			state:    stateShuttingDown,
+			method:   "shutdown",
+			wantCode: errorCodePtr(lsp.InvalidRequest),
+		},
+		{
+			name:     "exit after shutdown",
+			state:    stateShuttingDown,
+			method:   "exit",
+			wantCode: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := &lifecycle{state: tc.state}
+			got := l.check(tc.method)
+			switch {
+			case tc.wantCode == nil && got != nil:
+				t.Errorf("check got error = %v, want nil", got)
+			case tc.wantCode != nil && got == nil:
+				t.Errorf("check got nil, want code %v", *tc.wantCode)
+			case tc.wantCode != nil && got.Code != *tc.wantCode:
+				t.Errorf("check got code = %v, want %v", got.Code, *tc.wantCode)
+			}
+		})
+	}
+}
+
+func TestLifecycleExitError(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name  string
+		state lifecycleState
+		want  error
+	}{
+		{
+			name:  "exit without initialization",
+			state: stateUninitialized,
+			want:  ErrExitWithoutShutdown,
+		},
+		{
+			name:  "exit without shutdown",
+			state: stateInitialized,
+			want:  ErrExitWithoutShutdown,
+		},
+		{
+			name:  "exit after shutdown",
+			state: stateShuttingDown,
+			want:  nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := &lifecycle{state: tc.state}
+			if got := l.exitError(); !errors.Is(got, tc.want) {
+				t.Errorf("exitError got = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func errorCodePtr(code lsp.ErrorCode) *lsp.ErrorCode {
+	return &code
+}
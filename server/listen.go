@@ -0,0 +1,134 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"sync"
+
+	"github.com/sebastian-nunez/golang-language-server-protocol/compiler"
+)
+
+// ListenAddress is an address on which the server accepts clients.
+type ListenAddress struct {
+	// Network and Address are passed to `net.Listen`.
+	Network string
+	Address string
+	// WebSocketPath is the HTTP path on which clients open their WebSocket. It is only set for
+	// `ws://` addresses.
+	WebSocketPath string
+	// AllowedOrigins are the origins of the web pages, besides those served by the host itself,
+	// that may open a WebSocket.
+	AllowedOrigins []string
+}
+
+// ParseListenAddress parses an address of the form `tcp://host:port`, `unix:///path/to/socket` or
+// `ws://host:port/path`. WebSocket addresses can allow other web pages to connect with `origin`
+// parameters, e.g. `ws://localhost:7777/lsp?origin=https://editor.example.com`.
+func ParseListenAddress(address string) (ListenAddress, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return ListenAddress{}, fmt.Errorf("invalid listen address '%s': %w", address, err)
+	}
+
+	switch u.Scheme {
+	case "tcp":
+		if u.Host == "" || u.Path != "" || u.RawQuery != "" {
+			return ListenAddress{}, fmt.Errorf("invalid listen address '%s': want tcp://host:port", address)
+		}
+		return ListenAddress{Network: "tcp", Address: u.Host}, nil
+	case "unix":
+		if u.Host != "" || u.Path == "" || u.RawQuery != "" {
+			return ListenAddress{}, fmt.Errorf("invalid listen address '%s': want unix:///path/to/socket", address)
+		}
+		return ListenAddress{Network: "unix", Address: u.Path}, nil
+	case "ws":
+		if u.Host == "" {
+			return ListenAddress{}, fmt.Errorf("invalid listen address '%s': want ws://host:port/path", address)
+		}
+		path := u.Path
+		if path == "" {
+			path = "/"
+		}
+		query := u.Query()
+		for name := range query {
+			if name != "origin" {
+				return ListenAddress{}, fmt.Errorf("invalid listen address '%s': unsupported parameter '%s'", address, name)
+			}
+		}
+		return ListenAddress{Network: "tcp", Address: u.Host, WebSocketPath: path, AllowedOrigins: query["origin"]}, nil
+	default:
+		return ListenAddress{}, fmt.Errorf("invalid listen address '%s': unsupported scheme '%s'", address, u.Scheme)
+	}
+}
+
+// ListenAndServe listens on the address (see `ParseListenAddress`) and serves every client that
+// connects to it. See `ServeListener` and `ServeWebSocket`.
+func ListenAndServe(ctx context.Context, address string, logger *log.Logger) error {
+	addr, err := ParseListenAddress(address)
+	if err != nil {
+		return err
+	}
+
+	listener, err := net.Listen(addr.Network, addr.Address)
+	if err != nil {
+		return fmt.Errorf("unable to listen on '%s': %w", address, err)
+	}
+	logger.Printf("Listening on %s", address)
+	if addr.WebSocketPath != "" {
+		return ServeWebSocket(ctx, listener, addr.WebSocketPath, addr.AllowedOrigins, logger)
+	}
+	return ServeListener(ctx, listener, logger)
+}
+
+// ServeListener accepts connections on the listener and serves a session on each of them until
+// the context is cancelled. Sessions run concurrently and each has its own `compiler.State`, so
+// clients never see each other's documents. A session ends when its client exits or disconnects.
+//
+// Cancelling the context closes the listener and every connection. ServeListener waits for the
+// sessions to end and returns the context's error.
+func ServeListener(ctx context.Context, listener net.Listener, logger *log.Logger) error {
+	stop := context.AfterFunc(ctx, func() { listener.Close() })
+	defer stop()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for session := 1; ; session++ {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return fmt.Errorf("unable to accept connection: %w", err)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			serveSession(ctx, NewStreamTransport(conn, conn), conn.RemoteAddr(), sessionLogger(logger, session))
+		}()
+	}
+}
+
+// serveSession serves a single session with a new state over the transport and closes it once
+// the session ends.
+func serveSession(ctx context.Context, transport Transport, remote any, logger *log.Logger) {
+	defer transport.Close()
+
+	logger.Printf("Session started: remote=%v", remote)
+	err := NewWithTransport(transport, logger, compiler.NewState()).Serve(ctx)
+	logger.Printf("Session ended: error=%v", err)
+}
+
+// sessionLogger returns a logger that writes to the same output as the given one, with the
+// session number added to its prefix so that concurrent sessions can be told apart.
+func sessionLogger(logger *log.Logger, session int) *log.Logger {
+	return log.New(logger.Writer(), fmt.Sprintf("%s[session %d] ", logger.Prefix(), session), logger.Flags())
+}
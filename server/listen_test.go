@@ -0,0 +1,230 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/sebastian-nunez/golang-language-server-protocol/compiler"
+	"github.com/sebastian-nunez/golang-language-server-protocol/lsp"
+	"github.com/sebastian-nunez/golang-language-server-protocol/rpc"
+)
+
+func TestParseListenAddress(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		address string
+		want    ListenAddress
+		wantErr bool
+	}{
+		{
+			name:    "tcp",
+			address: "tcp://127.0.0.1:7777",
+			want:    ListenAddress{Network: "tcp", Address: "127.0.0.1:7777"},
+		},
+		{
+			name:    "tcp on every interface",
+			address: "tcp://:7777",
+			want:    ListenAddress{Network: "tcp", Address: ":7777"},
+		},
+		{
+			name:    "unix socket",
+			address: "unix:///tmp/lsp.sock",
+			want:    ListenAddress{Network: "unix", Address: "/tmp/lsp.sock"},
+		},
+		{
+			name:    "websocket",
+			address: "ws://localhost:7777/lsp",
+			want:    ListenAddress{Network: "tcp", Address: "localhost:7777", WebSocketPath: "/lsp"},
+		},
+		{
+			name:    "websocket without a path",
+			address: "ws://localhost:7777",
+			want:    ListenAddress{Network: "tcp", Address: "localhost:7777", WebSocketPath: "/"},
+		},
+		{
+			name:    "websocket with allowed origins",
+			address: "ws://localhost:7777/lsp?origin=https://editor.example.com&origin=http://localhost:3000",
+			want: ListenAddress{
+				Network:        "tcp",
+				Address:        "localhost:7777",
+				WebSocketPath:  "/lsp",
+				AllowedOrigins: []string{"https://editor.example.com", "http://localhost:3000"},
+			},
+		},
+		{
+			name:    "websocket with an unknown parameter",
+			address: "ws://localhost:7777/lsp?host=example.com",
+			wantErr: true,
+		},
+		{
+			name:    "tcp with parameters",
+			address: "tcp://127.0.0.1:7777?origin=https://editor.example.com",
+			wantErr: true,
+		},
+		{
+			name:    "tcp without a host",
+			address: "tcp:///tmp/lsp.sock",
+			wantErr: true,
+		},
+		{
+			name:    "unix socket with a host",
+			address: "unix://tmp/lsp.sock",
+			wantErr: true,
+		},
+		{
+			name:    "unsupported scheme",
+			address: "udp://127.0.0.1:7777",
+			wantErr: true,
+		},
+		{
+			name:    "missing scheme",
+			address: "127.0.0.1:7777",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseListenAddress(tc.address)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ParseListenAddress got error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("ParseListenAddress got = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+// testClient sends messages over a connection and reads the messages sent back.
+type testClient struct {
+	t       *testing.T
+	encoder *rpc.Encoder
+	reader  *rpc.Reader
+}
+
+func newTestClient(t *testing.T, conn io.ReadWriter) *testClient {
+	return &testClient{t: t, encoder: rpc.NewEncoder(conn), reader: rpc.NewReader(conn)}
+}
+
+func (c *testClient) send(msg string) {
+	c.t.Helper()
+	if err := c.encoder.Encode(json.RawMessage(msg)); err != nil {
+		c.t.Fatalf("Encode got unexpected error: %v", err)
+	}
+}
+
+func (c *testClient) receive() testMessage {
+	c.t.Helper()
+	content, err := c.reader.Read()
+	if err != nil {
+		c.t.Fatalf("Read got unexpected error: %v", err)
+	}
+
+	var msg testMessage
+	if err := json.Unmarshal(content, &msg); err != nil {
+		c.t.Fatalf("Unmarshal got unexpected error: %v", err)
+	}
+	return msg
+}
+
+func TestServeListener(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen got unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- ServeListener(ctx, listener, log.New(io.Discard, "", 0)) }()
+
+	var clients []*testClient
+	for i := 0; i < 2; i++ {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			t.Fatalf("Dial got unexpected error: %v", err)
+		}
+		defer conn.Close()
+
+		client := newTestClient(t, conn)
+		client.send(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"capabilities":{}}}`)
+		if msg := client.receive(); msg.Error != nil {
+			t.Fatalf("initialize got error = %v", msg.Error)
+		}
+		clients = append(clients, client)
+	}
+
+	// Every session has its own state, so a document opened by one client is unknown to the other.
+	clients[0].send(`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"uri":"file:///a.md","languageId":"markdown","version":1,"text":"hello"}}}`)
+	clients[0].receive() // publishDiagnostics
+	hover := `{"jsonrpc":"2.0","id":2,"method":"textDocument/hover","params":{"textDocument":{"uri":"file:///a.md"},"position":{"line":0,"character":0}}}`
+	clients[0].send(hover)
+	if msg := clients[0].receive(); msg.Error != nil {
+		t.Errorf("hover on the client that opened the document got error = %v", msg.Error)
+	}
+	clients[1].send(hover)
+	if msg := clients[1].receive(); msg.Error == nil || msg.Error.Code != lsp.RequestFailed {
+		t.Errorf("hover on the other client got response = %+v, want code %v", msg, lsp.RequestFailed)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("ServeListener got error = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("ServeListener did not return after the context was cancelled")
+	}
+}
+
+func TestDialPipe(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("named pipes cannot be created without the Windows API")
+	}
+
+	name := filepath.Join(t.TempDir(), "lsp.sock")
+	listener, err := net.Listen("unix", name)
+	if err != nil {
+		t.Fatalf("Listen got unexpected error: %v", err)
+	}
+	defer listener.Close()
+
+	conn, err := DialPipe(name)
+	if err != nil {
+		t.Fatalf("DialPipe got unexpected error: %v", err)
+	}
+	defer conn.Close()
+	go New(conn, conn, log.New(io.Discard, "", 0), compiler.NewState()).Serve(context.Background())
+
+	clientConn, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("Accept got unexpected error: %v", err)
+	}
+	defer clientConn.Close()
+
+	client := newTestClient(t, clientConn)
+	client.send(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"capabilities":{}}}`)
+	if msg := client.receive(); msg.Error != nil {
+		t.Errorf("initialize got error = %v", msg.Error)
+	}
+
+	if _, err := DialPipe(filepath.Join(t.TempDir(), "missing.sock")); err == nil {
+		t.Errorf("DialPipe got no error for a missing pipe")
+	}
+}
@@ -0,0 +1,17 @@
+package server
+
+import (
+	"fmt"
+	"io"
+)
+
+// DialPipe connects to the pipe created by the client for the session, as requested with the
+// `--pipe=<name>` argument. On Windows the name is a named pipe (`\\.\pipe\...`), elsewhere it is
+// the path of a Unix domain socket.
+func DialPipe(name string) (io.ReadWriteCloser, error) {
+	conn, err := dialPipe(name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to pipe '%s': %w", name, err)
+	}
+	return conn, nil
+}
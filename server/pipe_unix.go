@@ -0,0 +1,13 @@
+//go:build !windows
+
+package server
+
+import (
+	"io"
+	"net"
+)
+
+// dialPipe connects to the Unix domain socket at the path.
+func dialPipe(name string) (io.ReadWriteCloser, error) {
+	return net.Dial("unix", name)
+}
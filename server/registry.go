@@ -0,0 +1,131 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/sebastian-nunez/golang-language-server-protocol/lsp"
+)
+
+// RequestHandler handles a request whose params decode into P and returns its result.
+type RequestHandler[P, R any] func(ctx context.Context, id lsp.ID, params P) (R, error)
+
+// NotificationHandler handles a notification whose params decode into P.
+type NotificationHandler[P any] func(ctx context.Context, params P) error
+
+// registry maps method names to their handlers. Handlers are registered with their concrete
+// params and result types; the registry takes care of decoding the params and boxing the
+// result so that the dispatcher can treat every method the same way.
+type registry struct {
+	methods map[string]*method
+}
+
+// method is a registered handler with its params and result types erased.
+type method struct {
+	name string
+	// isRequest is true if the method expects a response.
+	isRequest bool
+	// inOrder is true if the handler must run on the reading goroutine, before the next
+	// message is read. Notifications always run in order.
+	inOrder bool
+	// capability advertises the method in the server capabilities, if set.
+	capability func(capabilities *lsp.ServerCapabilities)
+	handle     func(ctx context.Context, id lsp.ID, params json.RawMessage) (any, error)
+}
+
+// methodOption configures a registered method.
+type methodOption func(m *method)
+
+// inOrder makes a request run on the reading goroutine instead of concurrently, for requests
+// that change the state of the server.
+func inOrder() methodOption {
+	return func(m *method) {
+		m.inOrder = true
+	}
+}
+
+// withCapability sets the server capability that advertises the method to the client.
+func withCapability(capability func(capabilities *lsp.ServerCapabilities)) methodOption {
+	return func(m *method) {
+		m.capability = capability
+	}
+}
+
+func newRegistry() *registry {
+	return &registry{
+		methods: make(map[string]*method),
+	}
+}
+
+// registerRequest registers the handler for a request method.
+func registerRequest[P, R any](r *registry, name string, handler RequestHandler[P, R], opts ...methodOption) {
+	r.add(name, true, func(ctx context.Context, id lsp.ID, raw json.RawMessage) (any, error) {
+		var params P
+		if err := decodeParams(raw, &params); err != nil {
+			return nil, err
+		}
+		return handler(ctx, id, params)
+	}, opts)
+}
+
+// registerNotification registers the handler for a notification method.
+func registerNotification[P any](r *registry, name string, handler NotificationHandler[P], opts ...methodOption) {
+	opts = append(opts, inOrder())
+	r.add(name, false, func(ctx context.Context, _ lsp.ID, raw json.RawMessage) (any, error) {
+		var params P
+		if err := decodeParams(raw, &params); err != nil {
+			return nil, err
+		}
+		return nil, handler(ctx, params)
+	}, opts)
+}
+
+func (r *registry) add(name string, isRequest bool, handle func(context.Context, lsp.ID, json.RawMessage) (any, error), opts []methodOption) {
+	if _, ok := r.methods[name]; ok {
+		panic("method registered twice: " + name)
+	}
+
+	m := &method{name: name, isRequest: isRequest, handle: handle}
+	for _, opt := range opts {
+		opt(m)
+	}
+	r.methods[name] = m
+}
+
+// lookup returns the method registered under the given name.
+func (r *registry) lookup(name string) (*method, bool) {
+	m, ok := r.methods[name]
+	return m, ok
+}
+
+// capabilities returns the server capabilities advertised by the registered methods.
+func (r *registry) capabilities() lsp.ServerCapabilities {
+	// Apply the capabilities in a stable order so that methods sharing a capability behave
+	// the same on every run.
+	names := make([]string, 0, len(r.methods))
+	for name := range r.methods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var capabilities lsp.ServerCapabilities
+	for _, name := range names {
+		if capability := r.methods[name].capability; capability != nil {
+			capability(&capabilities)
+		}
+	}
+	return capabilities
+}
+
+// decodeParams unmarshals the params of a message. Missing params leave the value untouched.
+func decodeParams(raw json.RawMessage, v any) error {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return &lsp.ResponseError{Code: lsp.InvalidParams, Message: fmt.Sprintf("invalid params: %v", err)}
+	}
+	return nil
+}
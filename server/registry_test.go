@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/sebastian-nunez/golang-language-server-protocol/lsp"
+)
+
+func TestRegistryRequest(t *testing.T) {
+	t.Parallel()
+
+	type params struct {
+		Name string `json:"name"`
+	}
+
+	r := newRegistry()
+	registerRequest(r, "greet", func(_ context.Context, id lsp.ID, p params) (string, error) {
+		return "hello " + p.Name + " " + id.String(), nil
+	})
+
+	m, ok := r.lookup("greet")
+	if !ok {
+		t.Fatalf("lookup got ok = false, want true")
+	}
+	if !m.isRequest || m.inOrder {
+		t.Errorf("lookup got isRequest = %v, inOrder = %v, want true, false", m.isRequest, m.inOrder)
+	}
+
+	got, err := m.handle(context.Background(), lsp.NewIntID(7), json.RawMessage(`{"name":"world"}`))
+	if err != nil {
+		t.Fatalf("handle got unexpected error: %v", err)
+	}
+	if got != "hello world 7" {
+		t.Errorf("handle got = %v, want %v", got, "hello world 7")
+	}
+
+	_, err = m.handle(context.Background(), lsp.NewIntID(8), json.RawMessage(`{"name":42}`))
+	var respErr *lsp.ResponseError
+	if !errors.As(err, &respErr) || respErr.Code != lsp.InvalidParams {
+		t.Errorf("handle with invalid params got error = %v, want code %v", err, lsp.InvalidParams)
+	}
+
+	if _, ok := r.lookup("missing"); ok {
+		t.Errorf("lookup of unregistered method got ok = true, want false")
+	}
+}
+
+func TestRegistryNotification(t *testing.T) {
+	t.Parallel()
+
+	r := newRegistry()
+	var got lsp.CancelParams
+	registerNotification(r, "$/cancelRequest", func(_ context.Context, p lsp.CancelParams) error {
+		got = p
+		return nil
+	})
+
+	m, _ := r.lookup("$/cancelRequest")
+	if m.isRequest || !m.inOrder {
+		t.Errorf("lookup got isRequest = %v, inOrder = %v, want false, true", m.isRequest, m.inOrder)
+	}
+	if _, err := m.handle(context.Background(), lsp.ID{}, json.RawMessage(`{"id":"abc"}`)); err != nil {
+		t.Fatalf("handle got unexpected error: %v", err)
+	}
+	if got.ID != lsp.NewStringID("abc") {
+		t.Errorf("handle got params = %+v, want id %v", got, "abc")
+	}
+}
+
+func TestRegistryCapabilities(t *testing.T) {
+	t.Parallel()
+
+	r := newRegistry()
+	registerRequest(r, "textDocument/hover", func(context.Context, lsp.ID, lsp.HoverParams) (*lsp.HoverResult, error) {
+		return nil, nil
+	}, withCapability(func(c *lsp.ServerCapabilities) {
+		hoverProvider := true
+		c.HoverProvider = &hoverProvider
+	}))
+	registerNotification(r, "textDocument/didOpen", func(context.Context, lsp.DidOpenTextDocumentParams) error {
+		return nil
+	}, withCapability(incrementalSync))
+	registerRequest(r, "no/capability", func(context.Context, lsp.ID, struct{}) (any, error) {
+		return nil, nil
+	})
+
+	hoverProvider := true
+	textDocumentSync := lsp.TextDocumentSyncIncremental
+	want := lsp.ServerCapabilities{
+		HoverProvider:    &hoverProvider,
+		TextDocumentSync: &textDocumentSync,
+	}
+	if got := r.capabilities(); !reflect.DeepEqual(got, want) {
+		t.Errorf("capabilities got = %+v, want %+v", got, want)
+	}
+}
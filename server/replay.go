@@ -0,0 +1,213 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/sebastian-nunez/golang-language-server-protocol/compiler"
+	"github.com/sebastian-nunez/golang-language-server-protocol/lsp"
+	"github.com/sebastian-nunez/golang-language-server-protocol/record"
+	"github.com/sebastian-nunez/golang-language-server-protocol/rpc"
+)
+
+// replayResponseTimeout is how long a replay waits for the server to send the request that a
+// recorded client response answers.
+const replayResponseTimeout = 5 * time.Second
+
+// recordingTransport records every message read from or written to the transport it wraps.
+type recordingTransport struct {
+	Transport
+	recorder *record.Recorder
+}
+
+// NewRecordingTransport wraps the transport so that every message it carries is recorded. Messages
+// are recorded as their content part, without the framing of the transport.
+func NewRecordingTransport(transport Transport, recorder *record.Recorder) Transport {
+	return &recordingTransport{
+		Transport: transport,
+		recorder:  recorder,
+	}
+}
+
+func (t *recordingTransport) Read() ([]byte, error) {
+	content, err := t.Transport.Read()
+	if err != nil {
+		return nil, err
+	}
+	if err := t.recorder.Record(record.Inbound, content); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+func (t *recordingTransport) Write(msgs ...any) error {
+	// The messages are encoded once here, so that exactly what is sent is recorded.
+	contents := make([]any, len(msgs))
+	for i, msg := range msgs {
+		content, err := json.Marshal(msg)
+		if err != nil {
+			return fmt.Errorf("%w: %w", rpc.ErrEncoding, err)
+		}
+		contents[i] = json.RawMessage(content)
+	}
+
+	if err := t.Transport.Write(contents...); err != nil {
+		return err
+	}
+	for _, content := range contents {
+		if err := t.recorder.Record(record.Outbound, content.(json.RawMessage)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Replay feeds the inbound messages of a recorded session, in order, into a new server and
+// compares the messages it sends back with the outbound messages of the recording (see
+// `record.Diff`). It returns no mismatches if the server behaves as it did when recording.
+//
+// Recorded responses to requests sent by the server are only fed once the server sent the
+// request again, so that they are not dropped as answers to unknown requests.
+func Replay(ctx context.Context, entries []record.Entry, logger *log.Logger) ([]record.Mismatch, error) {
+	transport := newReplayTransport()
+	s := NewWithTransport(transport, logger, compiler.NewState())
+
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		// The recorded session may not end with `exit`, so the error is not meaningful.
+		s.Serve(ctx)
+	}()
+
+	for _, entry := range entries {
+		if entry.Direction != record.Inbound {
+			continue
+		}
+		if id, ok := responseID(entry.Message); ok {
+			if err := transport.waitForRequest(ctx, id, served); err != nil {
+				return nil, err
+			}
+		}
+
+		select {
+		case transport.inbound <- entry.Message:
+		case <-served:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	close(transport.inbound)
+	<-served
+
+	return record.Diff(record.Messages(entries, record.Outbound), transport.messages()), nil
+}
+
+// responseID returns the ID of the message if it is a response.
+func responseID(content []byte) (lsp.ID, bool) {
+	var message struct {
+		ID     *lsp.ID `json:"id"`
+		Method string  `json:"method"`
+	}
+	if err := json.Unmarshal(content, &message); err != nil || message.Method != "" || message.ID == nil {
+		return lsp.ID{}, false
+	}
+	return *message.ID, true
+}
+
+// replayTransport feeds the recorded messages to the server and collects the messages it sends.
+type replayTransport struct {
+	inbound chan []byte
+	closed  chan struct{}
+	once    sync.Once
+
+	mu       sync.Mutex
+	outbound []json.RawMessage
+	// written is signalled every time the server sends a message.
+	written chan struct{}
+}
+
+func newReplayTransport() *replayTransport {
+	return &replayTransport{
+		inbound: make(chan []byte),
+		closed:  make(chan struct{}),
+		written: make(chan struct{}, 1),
+	}
+}
+
+func (t *replayTransport) Read() ([]byte, error) {
+	select {
+	case content, ok := <-t.inbound:
+		if !ok {
+			return nil, io.EOF
+		}
+		return content, nil
+	case <-t.closed:
+		return nil, io.EOF
+	}
+}
+
+func (t *replayTransport) Write(msgs ...any) error {
+	contents := make([]json.RawMessage, len(msgs))
+	for i, msg := range msgs {
+		content, err := json.Marshal(msg)
+		if err != nil {
+			return fmt.Errorf("%w: %w", rpc.ErrEncoding, err)
+		}
+		contents[i] = content
+	}
+
+	t.mu.Lock()
+	t.outbound = append(t.outbound, contents...)
+	t.mu.Unlock()
+	select {
+	case t.written <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (t *replayTransport) Close() error {
+	t.once.Do(func() { close(t.closed) })
+	return nil
+}
+
+// messages returns the messages sent by the server so far.
+func (t *replayTransport) messages() []json.RawMessage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]json.RawMessage(nil), t.outbound...)
+}
+
+// waitForRequest waits until the server sent a request with the given ID.
+func (t *replayTransport) waitForRequest(ctx context.Context, id lsp.ID, served <-chan struct{}) error {
+	timeout := time.NewTimer(replayResponseTimeout)
+	defer timeout.Stop()
+
+	for {
+		for _, content := range t.messages() {
+			var message struct {
+				ID     *lsp.ID `json:"id"`
+				Method string  `json:"method"`
+			}
+			if json.Unmarshal(content, &message) == nil && message.Method != "" && message.ID != nil && *message.ID == id {
+				return nil
+			}
+		}
+
+		select {
+		case <-t.written:
+		case <-served:
+			return errors.New("the server stopped before sending request " + id.String())
+		case <-timeout.C:
+			return fmt.Errorf("the server did not send request %v within %v", id, replayResponseTimeout)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
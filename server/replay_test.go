@@ -0,0 +1,128 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/sebastian-nunez/golang-language-server-protocol/compiler"
+	"github.com/sebastian-nunez/golang-language-server-protocol/record"
+)
+
+// recordSession runs a session whose messages are recorded and returns the trace.
+func recordSession(t *testing.T, drive func(client *testClient)) []record.Entry {
+	t.Helper()
+
+	var trace bytes.Buffer
+	serverConn, clientConn := net.Pipe()
+	transport := NewRecordingTransport(NewStreamTransport(serverConn, serverConn), record.NewRecorder(&trace))
+	s := NewWithTransport(transport, log.New(io.Discard, "", 0), compiler.NewState())
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		s.Serve(context.Background())
+	}()
+
+	drive(newTestClient(t, clientConn))
+	clientConn.Close()
+	<-served
+
+	entries, err := record.ReadTrace(&trace)
+	if err != nil {
+		t.Fatalf("ReadTrace got unexpected error: %v", err)
+	}
+	return entries
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	t.Parallel()
+
+	entries := recordSession(t, func(client *testClient) {
+		client.send(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"capabilities":{"workspace":{"applyEdit":true}}}}`)
+		client.receive()
+		client.send(`{"jsonrpc":"2.0","method":"initialized","params":{}}`)
+		client.send(`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"uri":"file:///a.md","languageId":"markdown","version":1,"text":"I use VS Code"}}}`)
+		client.receive()
+		client.send(`{"jsonrpc":"2.0","id":2,"method":"textDocument/codeAction","params":{"textDocument":{"uri":"file:///a.md"},"range":{"start":{"line":0,"character":0},"end":{"line":0,"character":0}},"context":{}}}`)
+		client.receive()
+		client.send(`{"jsonrpc":"2.0","id":3,"method":"workspace/executeCommand","params":{"command":"golang-lsp.applyEdit","arguments":[{"changes":{}}]}}`)
+		call := client.receiveCall()
+		client.send(fmt.Sprintf(`{"jsonrpc":"2.0","id":%v,"result":{"applied":true}}`, call.ID))
+		client.receive()
+		client.send(`{"jsonrpc":"2.0","id":4,"method":"shutdown"}`)
+		client.receive()
+		client.send(`{"jsonrpc":"2.0","method":"exit"}`)
+	})
+
+	if got := len(record.Messages(entries, record.Inbound)); got != 8 {
+		t.Errorf("got %d inbound messages, want 8", got)
+	}
+	if got := len(record.Messages(entries, record.Outbound)); got != 6 {
+		t.Errorf("got %d outbound messages, want 6", got)
+	}
+
+	mismatches, err := Replay(context.Background(), entries, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("Replay got unexpected error: %v", err)
+	}
+	for _, mismatch := range mismatches {
+		t.Errorf("Replay got mismatch %v", mismatch)
+	}
+}
+
+func TestReplayDetectsRegressions(t *testing.T) {
+	t.Parallel()
+
+	entries := recordSession(t, func(client *testClient) {
+		client.send(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"capabilities":{}}}`)
+		client.receive()
+		client.send(`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"uri":"file:///a.md","languageId":"markdown","version":1,"text":"hello"}}}`)
+		client.receive()
+		client.send(`{"jsonrpc":"2.0","id":2,"method":"textDocument/hover","params":{"textDocument":{"uri":"file:///a.md"},"position":{"line":0,"character":0}}}`)
+		client.receive()
+	})
+
+	// Pretend the recorded server answered the hover differently.
+	for i, entry := range entries {
+		if entry.Direction == record.Outbound && strings.Contains(string(entry.Message), "characters=5") {
+			entries[i].Message = json.RawMessage(strings.Replace(string(entry.Message), "characters=5", "characters=6", 1))
+		}
+	}
+
+	mismatches, err := Replay(context.Background(), entries, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("Replay got unexpected error: %v", err)
+	}
+	if len(mismatches) != 1 || mismatches[0].Key != "response 2" {
+		t.Errorf("Replay got mismatches %v, want one for response 2", mismatches)
+	}
+}
+
+func TestReplayTestdata(t *testing.T) {
+	t.Parallel()
+
+	file, err := os.Open("testdata/session.jsonl")
+	if err != nil {
+		t.Fatalf("Open got unexpected error: %v", err)
+	}
+	defer file.Close()
+
+	entries, err := record.ReadTrace(file)
+	if err != nil {
+		t.Fatalf("ReadTrace got unexpected error: %v", err)
+	}
+	mismatches, err := Replay(context.Background(), entries, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("Replay got unexpected error: %v", err)
+	}
+	for _, mismatch := range mismatches {
+		t.Errorf("Replay got mismatch %v", mismatch)
+	}
+}
@@ -0,0 +1,376 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sebastian-nunez/golang-language-server-protocol/compiler"
+	"github.com/sebastian-nunez/golang-language-server-protocol/lsp"
+	"github.com/sebastian-nunez/golang-language-server-protocol/rpc"
+)
+
+// ErrExitWithoutShutdown is returned by `Serve` when the session ended without a `shutdown`
+// request, either because the client sent `exit` too early or because the connection closed.
+var ErrExitWithoutShutdown = errors.New("session ended without a shutdown request")
+
+// Server is a language server session with a single client. It reads messages from the client
+// and routes them to their handlers.
+//
+// Requests run concurrently, each with its own context which is cancelled when the client sends
+// `$/cancelRequest`. Lifecycle messages and notifications that mutate documents are handled in
+// order on the reading goroutine, so every request sees the changes sent before it.
+type Server struct {
+	transport Transport
+	logger    *log.Logger
+	state     *compiler.State
+	registry  *registry
+	lifecycle *lifecycle
+	// clientCapabilities are the capabilities declared by the client in `initialize`.
+	clientCapabilities lsp.ClientCapabilities
+	// ctx is the parent of every request context. It is set when the server starts serving.
+	ctx context.Context
+	// stop cancels ctx. It is called when the connection to the client is broken.
+	stop context.CancelFunc
+	// exited is set once the client asked the server to exit.
+	exited bool
+	// trace is the `lsp.TraceValue` set by the client. It is read by the requests running
+	// concurrently.
+	trace atomic.Value
+	// writeErr is the first error that occurred while writing to the client, after which the
+	// session cannot continue.
+	writeErr error
+
+	mu sync.Mutex
+	// inflight holds the cancel function of every request that is still being handled.
+	inflight map[lsp.ID]context.CancelFunc
+	wg       sync.WaitGroup
+
+	// nextID is the ID of the last request sent to the client.
+	nextID atomic.Int64
+	// pending holds the channel awaiting the response of every request sent to the client.
+	pending map[lsp.ID]chan<- callResult
+	// callTimeout is how long `Call` waits for a response.
+	callTimeout time.Duration
+	// shuttingDown is closed when the `shutdown` request is received, after which no response of
+	// the client is read until the running requests are done.
+	shuttingDown chan struct{}
+	// done is closed once `Serve` returns.
+	done chan struct{}
+}
+
+// New creates a server that reads messages from the reader and writes messages to the writer,
+// framed with a `Content-Length` header. The state holds the documents of the session and should
+// not be shared with other servers.
+func New(reader io.Reader, writer io.Writer, logger *log.Logger, state *compiler.State) *Server {
+	return NewWithTransport(NewStreamTransport(reader, writer), logger, state)
+}
+
+// NewWithTransport creates a server that exchanges messages with the client over the transport.
+func NewWithTransport(transport Transport, logger *log.Logger, state *compiler.State) *Server {
+	s := &Server{
+		transport: transport,
+		logger:    logger,
+		state:     state,
+		registry:  newRegistry(),
+		lifecycle: &lifecycle{},
+		ctx:       context.Background(),
+		stop:      func() {},
+		inflight:  make(map[lsp.ID]context.CancelFunc),
+		pending:   make(map[lsp.ID]chan<- callResult),
+
+		callTimeout:  defaultCallTimeout,
+		shuttingDown: make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	s.trace.Store(lsp.TraceOff)
+	s.register(s.registry)
+	return s
+}
+
+// Serve handles the messages of the client until it asks the server to exit, the reader is
+// exhausted, the writer fails or the context is cancelled. It returns nil if the session ended
+// after a `shutdown` request and `ErrExitWithoutShutdown` if it did not. A failed write (e.g. a
+// broken pipe) ends the session and its error is returned.
+//
+// Cancelling the context cancels every running request and closes the transport to unblock the
+// pending read.
+func (s *Server) Serve(ctx context.Context) error {
+	defer close(s.done)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	s.ctx = ctx
+	s.stop = cancel
+
+	stopClose := context.AfterFunc(ctx, func() { s.transport.Close() })
+	defer stopClose()
+
+	var readErr error
+	for !s.exited && ctx.Err() == nil {
+		content, err := s.transport.Read()
+		if errors.Is(err, rpc.ErrMessageTooLarge) || errors.Is(err, rpc.ErrUnsupportedCharset) {
+			s.logger.Printf("Skipping message: %v", err)
+			continue
+		}
+		if err != nil {
+			readErr = err
+			break
+		}
+
+		if rpc.IsBatch(content) {
+			s.handleBatch(content)
+			continue
+		}
+		s.handleMessage(content, nil)
+	}
+
+	if !s.exited {
+		if err := ctx.Err(); err != nil {
+			s.exit()
+			if writeErr := s.writeFailure(); writeErr != nil {
+				return writeErr
+			}
+			return err
+		}
+		if !errors.Is(readErr, io.EOF) {
+			s.logger.Printf("Error reading messages: %v", readErr)
+		}
+		// The client went away without going through `shutdown` and `exit`.
+		s.logger.Println("Input stream closed, exiting")
+		s.exit()
+	}
+	return s.lifecycle.exitError()
+}
+
+// handleMessage decodes a single message from the client and handles it. The replies to requests
+// are added to the batch, if the message is part of one, or sent right away.
+func (s *Server) handleMessage(content []byte, b *batch) {
+	method, err := rpc.DecodeContent(content)
+	if err != nil {
+		s.logger.Printf("Error decoding message: %v", err)
+		// The ID of the request cannot be known, so the error is reported with a null ID.
+		code := lsp.InvalidRequest
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			code = lsp.ParseError
+		}
+		s.reply(b, lsp.NewErrorResponse(lsp.ID{}, code, err.Error()))
+		return
+	}
+	if method == "" {
+		// Messages without a method are responses to the requests sent by the server.
+		s.handleResponse(content)
+		return
+	}
+	s.dispatch(method, content, b)
+}
+
+// dispatch handles the incoming message from the client and sends the appropriate response (if needed).
+func (s *Server) dispatch(name string, content []byte, b *batch) {
+	if respErr := s.lifecycle.check(name); respErr != nil {
+		s.logger.Printf("Rejecting message: method=%v, error=%v", name, respErr)
+		s.replyError(b, content, respErr)
+		return
+	}
+
+	var message struct {
+		ID     *lsp.ID         `json:"id"`
+		Params json.RawMessage `json:"params"`
+	}
+	if err := json.Unmarshal(content, &message); err != nil {
+		s.logger.Printf("Error unmarshalling %s: %v", name, err)
+		s.reply(b, lsp.NewErrorResponse(lsp.ID{}, lsp.InvalidRequest, err.Error()))
+		return
+	}
+
+	m, ok := s.registry.lookup(name)
+	if !ok {
+		s.logger.Printf("Received message: method=%v, content=%v", name, string(content))
+		// Unknown notifications are ignored, but every request must be answered.
+		s.replyError(b, content, &lsp.ResponseError{Code: lsp.MethodNotFound, Message: "method not found: " + name})
+		return
+	}
+
+	if !m.isRequest {
+		start := time.Now()
+		_, err := m.handle(s.ctx, lsp.ID{}, message.Params)
+		if err != nil {
+			s.logger.Printf("Error handling %s: %v", name, err)
+		}
+		s.logTrace(b, name, nil, message.Params, time.Since(start), err)
+		return
+	}
+	if message.ID == nil {
+		s.logger.Printf("Ignoring request sent without an ID: method=%v", name)
+		return
+	}
+
+	id := *message.ID
+	s.logger.Printf("Received request: method=%v, id=%v", name, id)
+	var duration time.Duration
+	handle := func(ctx context.Context) (any, error) {
+		start := time.Now()
+		defer func() { duration = time.Since(start) }()
+		return m.handle(ctx, id, message.Params)
+	}
+	// Requests are traced once they are answered, since nothing may be sent to the client before
+	// the response to `initialize`.
+	respond := func(result any, err error) {
+		s.respond(b, id, name, result, err)
+		s.logTrace(b, name, &id, message.Params, duration, err)
+	}
+	if m.inOrder {
+		respond(handle(s.ctx))
+		return
+	}
+	s.goRequest(b, id, handle, respond)
+}
+
+// goRequest runs the handler of a request on its own goroutine and passes its outcome to respond.
+// The handler's context is cancelled if the client cancels the request.
+func (s *Server) goRequest(b *batch, id lsp.ID, handler func(ctx context.Context) (any, error), respond func(result any, err error)) {
+	ctx, cancel := context.WithCancel(s.ctx)
+	s.mu.Lock()
+	s.inflight[id] = cancel
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	b.begin()
+	go func() {
+		defer s.wg.Done()
+		defer b.end()
+		defer func() {
+			s.mu.Lock()
+			delete(s.inflight, id)
+			s.mu.Unlock()
+			cancel()
+		}()
+
+		result, err := handler(ctx)
+		if err == nil && ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		respond(result, err)
+	}()
+}
+
+// respond writes the response to a request: its result or, if the handler failed, its error.
+func (s *Server) respond(b *batch, id lsp.ID, method string, result any, err error) {
+	if err != nil {
+		s.logger.Printf("Error handling %s: id=%v, error=%v", method, id, err)
+		respErr := toResponseError(err)
+		response := lsp.NewErrorResponse(id, respErr.Code, respErr.Message)
+		response.Error.Data = respErr.Data
+		s.reply(b, response)
+		return
+	}
+
+	if err := s.reply(b, lsp.NewResultResponse(id, result)); errors.Is(err, rpc.ErrEncoding) {
+		// The client is still waiting for a response, so it is told why the result is missing.
+		s.reply(b, lsp.NewErrorResponse(id, lsp.InternalError, err.Error()))
+		return
+	}
+	s.logger.Printf("Sent %s response: id=%v", method, id)
+}
+
+// cancel cancels the request with the given ID, if it is still running.
+func (s *Server) cancel(id lsp.ID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if cancel, ok := s.inflight[id]; ok {
+		s.logger.Printf("Cancelling request: id=%v", id)
+		cancel()
+	}
+}
+
+// exit cancels the requests that are still running and stops reading messages.
+func (s *Server) exit() {
+	s.mu.Lock()
+	for _, cancel := range s.inflight {
+		cancel()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+
+	s.exited = true
+	s.logger.Printf("Exiting: error=%v", s.lifecycle.exitError())
+}
+
+// publishDiagnostics sends the diagnostics computed for the given version of a document. They are
+// dropped if the document has since moved on to a newer version.
+func (s *Server) publishDiagnostics(uri lsp.DocumentURI, version int, diagnostics []lsp.Diagnostic) {
+	if current, err := s.state.DocumentVersion(uri); err == nil && current != version {
+		s.logger.Printf("Dropping diagnostics for superseded version: URI=%v, version=%d, current=%d", uri, version, current)
+		return
+	}
+
+	s.write(lsp.PublishDiagnosticsNotification{
+		Notification: lsp.Notification{
+			RPC:    "2.0",
+			Method: "textDocument/publishDiagnostics",
+		},
+		Params: lsp.PublishDiagnosticsParams{
+			URI:         uri,
+			Version:     &version,
+			Diagnostics: diagnostics,
+		},
+	})
+}
+
+// replyError answers the request in content with the given error. Notifications are never answered.
+func (s *Server) replyError(b *batch, content []byte, respErr *lsp.ResponseError) {
+	id, ok := requestID(content)
+	if !ok {
+		return
+	}
+
+	response := lsp.NewErrorResponse(id, respErr.Code, respErr.Message)
+	response.Error.Data = respErr.Data
+	s.reply(b, response)
+}
+
+// requestID returns the ID of the message, if it is a request. Notifications have no ID.
+func requestID(content []byte) (lsp.ID, bool) {
+	var message struct {
+		ID *lsp.ID `json:"id"`
+	}
+	if err := json.Unmarshal(content, &message); err != nil || message.ID == nil {
+		return lsp.ID{}, false
+	}
+	return *message.ID, true
+}
+
+// write sends a message to the client. Messages that cannot be encoded are dropped, but any other
+// error means the connection is broken: the session is stopped and the error is returned by `Serve`.
+func (s *Server) write(msg any) error {
+	err := s.transport.Write(msg)
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, rpc.ErrEncoding) {
+		s.logger.Printf("Error encoding message: %v", err)
+		return err
+	}
+
+	s.mu.Lock()
+	if s.writeErr == nil {
+		s.logger.Printf("Error writing message, stopping: %v", err)
+		s.writeErr = err
+	}
+	s.mu.Unlock()
+	s.stop()
+	return err
+}
+
+// writeFailure returns the error that broke the connection to the client, if any.
+func (s *Server) writeFailure() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.writeErr
+}
@@ -0,0 +1,290 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/sebastian-nunez/golang-language-server-protocol/compiler"
+	"github.com/sebastian-nunez/golang-language-server-protocol/lsp"
+	"github.com/sebastian-nunez/golang-language-server-protocol/rpc"
+)
+
+// testMessage is the subset of a JSON-RPC message inspected by the tests.
+type testMessage struct {
+	ID     *lsp.ID            `json:"id"`
+	Method string             `json:"method"`
+	Params json.RawMessage    `json:"params"`
+	Result json.RawMessage    `json:"result"`
+	Error  *lsp.ResponseError `json:"error"`
+}
+
+func newTestServer(input io.Reader) (*Server, *bytes.Buffer) {
+	var out bytes.Buffer
+	return New(input, &out, log.New(io.Discard, "", 0), compiler.NewState()), &out
+}
+
+// encodeMessages frames the messages as they would be sent by a client.
+func encodeMessages(messages ...string) *bytes.Buffer {
+	var in bytes.Buffer
+	for _, msg := range messages {
+		// The messages are framed by hand, so that malformed content can be sent too.
+		fmt.Fprintf(&in, "%s%d%s%s", rpc.ContentLength, len(msg), rpc.Separator, msg)
+	}
+	return &in
+}
+
+// readMessages decodes every message written to the buffer.
+func readMessages(t *testing.T, out *bytes.Buffer) []testMessage {
+	t.Helper()
+
+	var messages []testMessage
+	scanner := bufio.NewScanner(out)
+	scanner.Split(rpc.SplitMessage)
+	for scanner.Scan() {
+		_, content, err := rpc.DecodeMessage(scanner.Bytes())
+		if err != nil {
+			t.Fatalf("DecodeMessage got unexpected error: %v", err)
+		}
+
+		var msg testMessage
+		if err := json.Unmarshal(content, &msg); err != nil {
+			t.Fatalf("Unmarshal got unexpected error: %v", err)
+		}
+		messages = append(messages, msg)
+	}
+	return messages
+}
+
+// responsesByID indexes the responses among the messages by their ID.
+func responsesByID(messages []testMessage) map[lsp.ID]testMessage {
+	responses := map[lsp.ID]testMessage{}
+	for _, msg := range messages {
+		if msg.ID != nil {
+			responses[*msg.ID] = msg
+		}
+	}
+	return responses
+}
+
+func TestServerRequests(t *testing.T) {
+	t.Parallel()
+
+	s, out := newTestServer(encodeMessages(
+		`{"jsonrpc":"2.0","id":"init","method":"initialize","params":{"capabilities":{}}}`,
+		`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"uri":"file:///a.md","languageId":"markdown","version":1,"text":"hello"}}}`,
+		`{"jsonrpc":"2.0","id":"hover","method":"textDocument/hover","params":{"textDocument":{"uri":"file:///a.md"},"position":{"line":0,"character":0}}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"textDocument/hover","params":{"textDocument":{"uri":"file:///missing.md"},"position":{"line":0,"character":0}}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"unknown/method"}`,
+		`{"jsonrpc":"2.0","id":4,"method":"shutdown"}`,
+		`{"jsonrpc":"2.0","method":"exit"}`,
+	))
+	if err := s.Serve(context.Background()); err != nil {
+		t.Fatalf("Serve got unexpected error: %v", err)
+	}
+
+	messages := readMessages(t, out)
+	notifications := 0
+	for _, msg := range messages {
+		if msg.ID == nil {
+			notifications++
+		}
+	}
+	responses := responsesByID(messages)
+
+	if notifications != 1 {
+		t.Errorf("got %d notifications, want 1", notifications)
+	}
+	if msg, ok := responses[lsp.NewStringID("init")]; !ok || msg.Error != nil {
+		t.Errorf("initialize got response = %+v, want a result", msg)
+	}
+	if msg, ok := responses[lsp.NewStringID("hover")]; !ok || msg.Error != nil {
+		t.Errorf("hover got response = %+v, want a result", msg)
+	}
+	if msg := responses[lsp.NewIntID(2)]; msg.Error == nil || msg.Error.Code != lsp.RequestFailed {
+		t.Errorf("hover on missing document got response = %+v, want code %v", msg, lsp.RequestFailed)
+	}
+	if msg := responses[lsp.NewIntID(3)]; msg.Error == nil || msg.Error.Code != lsp.MethodNotFound {
+		t.Errorf("unknown method got response = %+v, want code %v", msg, lsp.MethodNotFound)
+	}
+	if msg, ok := responses[lsp.NewIntID(4)]; !ok || msg.Error != nil || string(msg.Result) != "null" {
+		t.Errorf("shutdown got response = %+v, want a null result", msg)
+	}
+}
+
+func TestServerParseError(t *testing.T) {
+	t.Parallel()
+
+	in := bytes.NewBufferString("Content-Length: 9\r\n\r\n{bad json")
+	s, out := newTestServer(in)
+	s.Serve(context.Background())
+
+	messages := readMessages(t, out)
+	if len(messages) != 1 {
+		t.Fatalf("got %d messages, want 1", len(messages))
+	}
+	// A null ID decodes into a nil pointer.
+	if messages[0].ID != nil {
+		t.Errorf("got id = %v, want null", messages[0].ID)
+	}
+	if messages[0].Error == nil || messages[0].Error.Code != lsp.ParseError {
+		t.Errorf("got response = %+v, want code %v", messages[0], lsp.ParseError)
+	}
+}
+
+func TestServerCancelRequest(t *testing.T) {
+	t.Parallel()
+
+	s, out := newTestServer(&bytes.Buffer{})
+	s.lifecycle.state = stateInitialized
+
+	started := make(chan struct{})
+	respond := func(result any, err error) { s.respond(nil, lsp.NewIntID(1), "slow/request", result, err) }
+	s.goRequest(nil, lsp.NewIntID(1), func(ctx context.Context) (any, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, respond)
+	<-started
+
+	s.dispatch("$/cancelRequest", []byte(`{"jsonrpc":"2.0","method":"$/cancelRequest","params":{"id":1}}`), nil)
+	s.wg.Wait()
+
+	messages := readMessages(t, out)
+	if len(messages) != 1 {
+		t.Fatalf("got %d messages, want 1", len(messages))
+	}
+	if messages[0].Error == nil || messages[0].Error.Code != lsp.RequestCancelled {
+		t.Errorf("got response = %+v, want code %v", messages[0], lsp.RequestCancelled)
+	}
+}
+
+func TestServerExit(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		messages []string
+		wantErr  error
+	}{
+		{
+			name: "exit after shutdown",
+			messages: []string{
+				`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"capabilities":{}}}`,
+				`{"jsonrpc":"2.0","id":2,"method":"shutdown"}`,
+				`{"jsonrpc":"2.0","method":"exit"}`,
+				`{"jsonrpc":"2.0","id":3,"method":"textDocument/hover","params":{}}`,
+			},
+			wantErr: nil,
+		},
+		{
+			name: "exit without shutdown",
+			messages: []string{
+				`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"capabilities":{}}}`,
+				`{"jsonrpc":"2.0","method":"exit"}`,
+			},
+			wantErr: ErrExitWithoutShutdown,
+		},
+		{
+			name: "input closed without shutdown",
+			messages: []string{
+				`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"capabilities":{}}}`,
+			},
+			wantErr: ErrExitWithoutShutdown,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, out := newTestServer(encodeMessages(tc.messages...))
+			if err := s.Serve(context.Background()); !errors.Is(err, tc.wantErr) {
+				t.Errorf("Serve got error = %v, want %v", err, tc.wantErr)
+			}
+
+			// Nothing is read after `exit`.
+			if _, ok := responsesByID(readMessages(t, out))[lsp.NewIntID(3)]; ok {
+				t.Errorf("got a response to a request sent after exit")
+			}
+		})
+	}
+}
+
+func TestServerContextCancelled(t *testing.T) {
+	t.Parallel()
+
+	reader, writer := io.Pipe()
+	defer writer.Close()
+	s, _ := newTestServer(reader)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(ctx) }()
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Serve got error = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Serve did not return after the context was cancelled")
+	}
+}
+
+func TestServerEncodingError(t *testing.T) {
+	t.Parallel()
+
+	s, out := newTestServer(&bytes.Buffer{})
+	registerRequest(s.registry, "test/nan", func(ctx context.Context, id lsp.ID, params any) (float64, error) {
+		return math.NaN(), nil
+	})
+	s.lifecycle.state = stateInitialized
+
+	s.dispatch("test/nan", []byte(`{"jsonrpc":"2.0","id":1,"method":"test/nan"}`), nil)
+	s.wg.Wait()
+
+	messages := readMessages(t, out)
+	if len(messages) != 1 {
+		t.Fatalf("got %d messages, want 1", len(messages))
+	}
+	if messages[0].Error == nil || messages[0].Error.Code != lsp.InternalError {
+		t.Errorf("got response = %+v, want code %v", messages[0], lsp.InternalError)
+	}
+}
+
+func TestServerWriteError(t *testing.T) {
+	t.Parallel()
+
+	reader, writer := io.Pipe()
+	defer writer.Close()
+	s := New(reader, failingWriter{}, log.New(io.Discard, "", 0), compiler.NewState())
+
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(context.Background()) }()
+	go encodeMessages(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"capabilities":{}}}`).WriteTo(writer)
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, syscall.EPIPE) {
+			t.Errorf("Serve got error = %v, want %v", err, syscall.EPIPE)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Serve did not return after the writer failed")
+	}
+}
+
+// failingWriter fails every write as if the client closed the connection.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, syscall.EPIPE
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/sebastian-nunez/golang-language-server-protocol/lsp"
+)
+
+// setTrace changes the level of the `$/logTrace` notifications sent to the client.
+func (s *Server) setTrace(_ context.Context, params lsp.SetTraceParams) error {
+	if !validTrace(params.Value) {
+		return fmt.Errorf("unknown trace value '%s'", params.Value)
+	}
+	s.logger.Printf("Trace set: value=%s", params.Value)
+	s.trace.Store(params.Value)
+	return nil
+}
+
+// validTrace reports whether the value is one of the trace levels defined by the specification.
+func validTrace(value lsp.TraceValue) bool {
+	return value == lsp.TraceOff || value == lsp.TraceMessages || value == lsp.TraceVerbose
+}
+
+// traceLevel returns the trace level requested by the client.
+func (s *Server) traceLevel() lsp.TraceValue {
+	return s.trace.Load().(lsp.TraceValue)
+}
+
+// logTrace sends a `$/logTrace` notification describing a message handled by the server, so that
+// it shows up in the LSP log of the editor. The ID is nil for notifications. Nothing is sent if the
+// client turned the trace off. The traces of the messages of a batch are sent after its replies.
+//
+// https://microsoft.github.io/language-server-protocol/specifications/lsp/3.17/specification/#logTrace
+func (s *Server) logTrace(b *batch, method string, id *lsp.ID, params json.RawMessage, duration time.Duration, err error) {
+	level := s.traceLevel()
+	if level == lsp.TraceOff {
+		return
+	}
+
+	var message string
+	if id != nil {
+		message = fmt.Sprintf("Handled request '%s - (%v)' in %dms.", method, *id, duration.Milliseconds())
+	} else {
+		message = fmt.Sprintf("Handled notification '%s' in %dms.", method, duration.Milliseconds())
+	}
+	if err != nil {
+		message += fmt.Sprintf(" Failed: %v", err)
+	}
+
+	var verbose string
+	if level == lsp.TraceVerbose {
+		if len(params) == 0 {
+			verbose = "No parameters provided."
+		} else {
+			verbose = "Params: " + string(params)
+		}
+	}
+
+	notification := lsp.LogTraceNotification{
+		Notification: lsp.Notification{
+			RPC:    "2.0",
+			Method: "$/logTrace",
+		},
+		Params: lsp.LogTraceParams{
+			Message: message,
+			Verbose: verbose,
+		},
+	}
+	if b != nil {
+		b.addTrace(notification)
+		return
+	}
+	s.write(notification)
+}
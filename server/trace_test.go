@@ -0,0 +1,162 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/sebastian-nunez/golang-language-server-protocol/lsp"
+)
+
+func TestServerTrace(t *testing.T) {
+	t.Parallel()
+
+	shutdown := `{"jsonrpc":"2.0","id":2,"method":"shutdown"}`
+	testCases := []struct {
+		name     string
+		messages []string
+		// want describes every message sent to the client, in order: the responses by ID and the
+		// traces by message, followed by their verbose details, if any.
+		want []string
+	}{
+		{
+			name: "trace not requested",
+			messages: []string{
+				`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"capabilities":{}}}`,
+				shutdown,
+			},
+			want: []string{"response 1", "response 2"},
+		},
+		{
+			name: "messages",
+			messages: []string{
+				`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"capabilities":{},"trace":"messages"}}`,
+				`{"jsonrpc":"2.0","method":"initialized","params":{}}`,
+				shutdown,
+			},
+			want: []string{
+				"response 1",
+				"Handled request 'initialize - (1)' in 0ms.",
+				"Handled notification 'initialized' in 0ms.",
+				"response 2",
+				"Handled request 'shutdown - (2)' in 0ms.",
+			},
+		},
+		{
+			name: "verbose",
+			messages: []string{
+				`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"capabilities":{},"trace":"verbose"}}`,
+				`{"jsonrpc":"2.0","method":"initialized","params":{}}`,
+				shutdown,
+			},
+			want: []string{
+				"response 1",
+				`Handled request 'initialize - (1)' in 0ms. | Params: {"capabilities":{},"trace":"verbose"}`,
+				"Handled notification 'initialized' in 0ms. | Params: {}",
+				"response 2",
+				"Handled request 'shutdown - (2)' in 0ms. | No parameters provided.",
+			},
+		},
+		{
+			name: "failed request",
+			messages: []string{
+				`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"capabilities":{},"trace":"messages"}}`,
+				`{"jsonrpc":"2.0","id":2,"method":"textDocument/hover","params":{"textDocument":{"uri":"file:///missing.md"},"position":{"line":0,"character":0}}}`,
+			},
+			want: []string{
+				"response 1",
+				"Handled request 'initialize - (1)' in 0ms.",
+				"response 2",
+				"Handled request 'textDocument/hover - (2)' in 0ms. Failed: document was not opened",
+			},
+		},
+		{
+			name: "batch",
+			messages: []string{
+				`[{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"capabilities":{},"trace":"messages"}},
+				  {"jsonrpc":"2.0","method":"initialized","params":{}}]`,
+				shutdown,
+			},
+			want: []string{
+				"response 1",
+				"Handled request 'initialize - (1)' in 0ms.",
+				"Handled notification 'initialized' in 0ms.",
+				"response 2",
+				"Handled request 'shutdown - (2)' in 0ms.",
+			},
+		},
+		{
+			name: "set at runtime",
+			messages: []string{
+				`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"capabilities":{}}}`,
+				`{"jsonrpc":"2.0","method":"$/setTrace","params":{"value":"messages"}}`,
+				shutdown,
+			},
+			want: []string{
+				"response 1",
+				"Handled notification '$/setTrace' in 0ms.",
+				"response 2",
+				"Handled request 'shutdown - (2)' in 0ms.",
+			},
+		},
+		{
+			name: "turned off at runtime",
+			messages: []string{
+				`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"capabilities":{},"trace":"messages"}}`,
+				`{"jsonrpc":"2.0","method":"$/setTrace","params":{"value":"off"}}`,
+				shutdown,
+			},
+			want: []string{
+				"response 1",
+				"Handled request 'initialize - (1)' in 0ms.",
+				"response 2",
+			},
+		},
+		{
+			name: "unknown value",
+			messages: []string{
+				`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"capabilities":{},"trace":"loud"}}`,
+				`{"jsonrpc":"2.0","method":"$/setTrace","params":{"value":"loud"}}`,
+				shutdown,
+			},
+			want: []string{"response 1", "response 2"},
+		},
+	}
+
+	// Durations depend on the machine running the tests.
+	duration := regexp.MustCompile(`in \d+ms\.`)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s, out := newTestServer(encodeMessages(tc.messages...))
+			s.Serve(context.Background())
+
+			var got []string
+			for _, batch := range readReplies(t, out) {
+				for _, msg := range batch {
+					if msg.Method != "$/logTrace" {
+						got = append(got, fmt.Sprintf("response %v", *msg.ID))
+						continue
+					}
+					var params lsp.LogTraceParams
+					if err := json.Unmarshal(msg.Params, &params); err != nil {
+						t.Fatalf("Unmarshal got unexpected error: %v", err)
+					}
+					trace := duration.ReplaceAllString(params.Message, "in 0ms.")
+					if params.Verbose != "" {
+						trace += " | " + params.Verbose
+					}
+					got = append(got, trace)
+				}
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got messages\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tc.want, "\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/sebastian-nunez/golang-language-server-protocol/rpc"
+	"github.com/sebastian-nunez/golang-language-server-protocol/websocket"
+)
+
+// Transport carries messages between the client and the server. Every transport feeds the same
+// dispatcher, only the way messages are delimited on the wire differs.
+type Transport interface {
+	// Read returns the content of the next message. It returns `io.EOF` once the client is gone.
+	// Errors wrapping `rpc.ErrMessageTooLarge` or `rpc.ErrUnsupportedCharset` only concern the
+	// skipped message.
+	Read() ([]byte, error)
+	// Write sends the messages to the client. It is safe for concurrent use and returns an error
+	// wrapping `rpc.ErrEncoding` if a message cannot be encoded.
+	Write(msgs ...any) error
+	// Close closes the transport, unblocking a pending Read.
+	Close() error
+}
+
+// streamTransport frames messages with a `Content-Length` header, as done over stdio, sockets and pipes.
+type streamTransport struct {
+	*rpc.Reader
+	*rpc.Writer
+	input io.Reader
+}
+
+// NewStreamTransport creates a transport that reads messages framed with a `Content-Length` header
+// from the reader and writes them to the writer. Closing it closes the reader, if it is an `io.Closer`.
+func NewStreamTransport(reader io.Reader, writer io.Writer) Transport {
+	return &streamTransport{
+		Reader: rpc.NewReader(reader),
+		Writer: rpc.NewWriter(writer),
+		input:  reader,
+	}
+}
+
+func (t *streamTransport) Close() error {
+	if closer, ok := t.input.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// webSocketTransport sends every message in its own WebSocket message, without any header.
+type webSocketTransport struct {
+	conn *websocket.Conn
+}
+
+// NewWebSocketTransport creates a transport that exchanges one message per WebSocket message.
+func NewWebSocketTransport(conn *websocket.Conn) Transport {
+	return &webSocketTransport{
+		conn: conn,
+	}
+}
+
+func (t *webSocketTransport) Read() ([]byte, error) {
+	return t.conn.ReadMessage()
+}
+
+func (t *webSocketTransport) Write(msgs ...any) error {
+	// Every message is encoded first, so that none of them are sent if one cannot be encoded.
+	contents := make([][]byte, len(msgs))
+	for i, msg := range msgs {
+		content, err := json.Marshal(msg)
+		if err != nil {
+			return fmt.Errorf("%w: %w", rpc.ErrEncoding, err)
+		}
+		contents[i] = content
+	}
+
+	for _, content := range contents {
+		if err := t.conn.WriteMessage(content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *webSocketTransport) Close() error {
+	return t.conn.Close()
+}
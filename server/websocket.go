@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sebastian-nunez/golang-language-server-protocol/websocket"
+)
+
+// WebSocketHandler is an HTTP handler that upgrades every request to a WebSocket and serves a
+// session over it, for browser based editors. Every WebSocket message carries exactly one
+// JSON-RPC message, without the `Content-Length` header used by the other transports.
+type WebSocketHandler struct {
+	ctx            context.Context
+	logger         *log.Logger
+	allowedOrigins []string
+	sessions       atomic.Int64
+	wg             sync.WaitGroup
+}
+
+// NewWebSocketHandler creates a handler whose sessions end when the context is cancelled. Like
+// `ServeListener`, every session has its own `compiler.State`.
+//
+// A session can read any markdown file of the machine, so web pages can only connect if they are
+// served by the same loopback host or if their origin is one of the allowed ones (see
+// `websocket.Upgrade`).
+func NewWebSocketHandler(ctx context.Context, allowedOrigins []string, logger *log.Logger) *WebSocketHandler {
+	return &WebSocketHandler{
+		ctx:            ctx,
+		logger:         logger,
+		allowedOrigins: allowedOrigins,
+	}
+}
+
+func (h *WebSocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.wg.Add(1)
+	defer h.wg.Done()
+
+	conn, err := websocket.Upgrade(w, r, h.allowedOrigins...)
+	if err != nil {
+		h.logger.Printf("Rejecting WebSocket handshake: remote=%v, error=%v", r.RemoteAddr, err)
+		return
+	}
+	session := int(h.sessions.Add(1))
+	serveSession(h.ctx, NewWebSocketTransport(conn), r.RemoteAddr, sessionLogger(h.logger, session))
+}
+
+// Wait waits for every session to end.
+func (h *WebSocketHandler) Wait() {
+	h.wg.Wait()
+}
+
+// ServeWebSocket accepts WebSocket clients on the given HTTP path of the listener until the context
+// is cancelled. It waits for the sessions to end and returns the context's error.
+func ServeWebSocket(ctx context.Context, listener net.Listener, path string, allowedOrigins []string, logger *log.Logger) error {
+	handler := NewWebSocketHandler(ctx, allowedOrigins, logger)
+	defer handler.Wait()
+
+	mux := http.NewServeMux()
+	mux.Handle(path, handler)
+	srv := &http.Server{Handler: mux, ErrorLog: logger}
+	stop := context.AfterFunc(ctx, func() { srv.Close() })
+	defer stop()
+
+	err := srv.Serve(listener)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
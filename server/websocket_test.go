@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sebastian-nunez/golang-language-server-protocol/lsp"
+	"github.com/sebastian-nunez/golang-language-server-protocol/websocket"
+)
+
+func TestServeWebSocket(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen got unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- ServeWebSocket(ctx, listener, "/lsp", nil, log.New(io.Discard, "", 0)) }()
+
+	conn, err := websocket.Dial("ws://" + listener.Addr().String() + "/lsp")
+	if err != nil {
+		t.Fatalf("Dial got unexpected error: %v", err)
+	}
+	defer conn.Close()
+
+	send := func(msg string) {
+		t.Helper()
+		if err := conn.WriteMessage([]byte(msg)); err != nil {
+			t.Fatalf("WriteMessage got unexpected error: %v", err)
+		}
+	}
+	receive := func() testMessage {
+		t.Helper()
+		content, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("ReadMessage got unexpected error: %v", err)
+		}
+		// Every WebSocket message is a bare JSON-RPC message, without a `Content-Length` header.
+		var msg testMessage
+		if err := json.Unmarshal(content, &msg); err != nil {
+			t.Fatalf("Unmarshal got unexpected error: %v, content=%q", err, content)
+		}
+		return msg
+	}
+
+	send(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"capabilities":{}}}`)
+	if msg := receive(); msg.Error != nil || msg.ID == nil || *msg.ID != lsp.NewIntID(1) {
+		t.Fatalf("initialize got response = %+v", msg)
+	}
+	send(`{"jsonrpc":"2.0","method":"initialized","params":{}}`)
+	send(`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"uri":"file:///a.md","languageId":"markdown","version":1,"text":"hello"}}}`)
+	if msg := receive(); msg.Method != "textDocument/publishDiagnostics" {
+		t.Errorf("got message = %+v, want diagnostics", msg)
+	}
+	send(`{"jsonrpc":"2.0","id":2,"method":"textDocument/hover","params":{"textDocument":{"uri":"file:///a.md"},"position":{"line":0,"character":0}}}`)
+	if msg := receive(); msg.Error != nil || len(msg.Result) == 0 {
+		t.Errorf("hover got response = %+v", msg)
+	}
+	send(`not json`)
+	if msg := receive(); msg.Error == nil || msg.Error.Code != lsp.ParseError {
+		t.Errorf("got response = %+v, want code %v", msg, lsp.ParseError)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("ServeWebSocket got error = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("ServeWebSocket did not return after the context was cancelled")
+	}
+	if _, err := conn.ReadMessage(); !errors.Is(err, io.EOF) {
+		t.Errorf("ReadMessage after the server stopped got error = %v, want %v", err, io.EOF)
+	}
+}
@@ -0,0 +1,167 @@
+package websocket
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Upgrade completes the opening handshake of a WebSocket request and takes over its connection.
+// If the request is not a valid WebSocket handshake, an error response is written and an error
+// is returned.
+//
+// Browsers let any web page open a WebSocket to any host, so handshakes sent by a page (those with
+// an `Origin` header) are only accepted from one of the allowed origins, such as
+// `https://editor.example.com`, or from a page served by the same loopback host (e.g. `localhost`).
+// Other hosts are not trusted even if the origin matches, since a page can point its own domain
+// at this server (DNS rebinding).
+func Upgrade(w http.ResponseWriter, r *http.Request, allowedOrigins ...string) (*Conn, error) {
+	if err := checkHandshake(r); err != nil {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, err
+	}
+	if err := checkOrigin(r, allowedOrigins); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil, err
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		err := errors.New("websocket: the response does not support hijacking")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, err
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("websocket: unable to hijack the connection: %w", err)
+	}
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(r.Header.Get("Sec-WebSocket-Key")) + "\r\n\r\n"
+	if _, err := conn.Write([]byte(response)); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("websocket: unable to complete the handshake: %w", err)
+	}
+	return newConn(conn, rw.Reader, false), nil
+}
+
+// checkHandshake validates the opening handshake sent by a client.
+func checkHandshake(r *http.Request) error {
+	if r.Method != http.MethodGet {
+		return fmt.Errorf("websocket: handshake method must be GET, got %s", r.Method)
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		return errors.New("websocket: not a websocket handshake")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return errors.New("websocket: unsupported version")
+	}
+	if key, err := base64.StdEncoding.DecodeString(r.Header.Get("Sec-WebSocket-Key")); err != nil || len(key) != 16 {
+		return errors.New("websocket: invalid Sec-WebSocket-Key")
+	}
+	return nil
+}
+
+// checkOrigin validates the origin of the page that sent the handshake, if any. Clients other than
+// browsers do not send an origin.
+func checkOrigin(r *http.Request, allowedOrigins []string) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	if u, err := url.Parse(origin); err == nil && u.Host != "" && strings.EqualFold(u.Host, r.Host) && isLoopback(u.Hostname()) {
+		return nil
+	}
+	for _, allowed := range allowedOrigins {
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return nil
+		}
+	}
+	return fmt.Errorf("websocket: origin '%s' is not allowed", origin)
+}
+
+// isLoopback reports whether the host name always refers to the local machine.
+func isLoopback(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// headerContains reports whether the comma separated header holds the token, case insensitively.
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, field := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(field), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Dial opens a WebSocket connection to the `ws://` URL. It is the client end of `Upgrade`, mostly
+// useful to test servers in-process.
+func Dial(rawURL string) (*Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("websocket: invalid URL '%s': %w", rawURL, err)
+	}
+	if u.Scheme != "ws" {
+		return nil, fmt.Errorf("websocket: unsupported scheme '%s'", u.Scheme)
+	}
+
+	conn, err := net.Dial("tcp", u.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	var nonce [16]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        u,
+		Host:       u.Host,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header: http.Header{
+			"Upgrade":               {"websocket"},
+			"Connection":            {"Upgrade"},
+			"Sec-WebSocket-Key":     {key},
+			"Sec-WebSocket-Version": {"13"},
+		},
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("websocket: unable to send the handshake: %w", err)
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("websocket: unable to read the handshake: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		conn.Close()
+		return nil, fmt.Errorf("websocket: handshake failed: %s", resp.Status)
+	}
+	return newConn(conn, reader, true), nil
+}
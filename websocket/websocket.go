@@ -0,0 +1,288 @@
+// Package websocket implements the subset of the WebSocket protocol (RFC 6455) needed to carry
+// JSON-RPC messages: the opening handshake, text and binary messages (possibly fragmented), pings
+// and the closing handshake. Extensions and subprotocols are not supported.
+package websocket
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"unicode/utf8"
+)
+
+// DefaultMaxMessageSize is the largest message accepted by a `Conn` by default.
+const DefaultMaxMessageSize = 64 << 20 // 64 MiB
+
+// acceptGUID is appended to the client's key to compute the `Sec-WebSocket-Accept` header.
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maxControlPayload is the largest payload of a control frame.
+const maxControlPayload = 125
+
+// ErrMessageTooLarge is returned when a message is larger than the maximum size. The connection
+// is closed afterwards, since the rest of the message cannot be skipped reliably.
+var ErrMessageTooLarge = errors.New("websocket message is too large")
+
+// opcode identifies the type of a frame.
+type opcode byte
+
+const (
+	opContinuation opcode = 0x0
+	opText         opcode = 0x1
+	opBinary       opcode = 0x2
+	opClose        opcode = 0x8
+	opPing         opcode = 0x9
+	opPong         opcode = 0xA
+)
+
+// isControl reports whether the frame is a control frame, which may be sent between the
+// fragments of a message.
+func (op opcode) isControl() bool {
+	return op&0x8 != 0
+}
+
+// Status codes sent in close frames.
+const (
+	statusNormalClosure   = 1000
+	statusProtocolError   = 1002
+	statusInvalidPayload  = 1007
+	statusMessageTooLarge = 1009
+)
+
+// Conn is a WebSocket connection. Reads must be made from a single goroutine, but writes are safe
+// for concurrent use.
+type Conn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	// client is true for the client end of the connection, which masks the frames it sends.
+	client bool
+	// MaxMessageSize is the largest message accepted. Zero means `DefaultMaxMessageSize`.
+	MaxMessageSize int
+
+	mu sync.Mutex
+	// closeSent is set once a close frame was sent, after which nothing else may be sent.
+	closeSent bool
+}
+
+func newConn(conn net.Conn, reader *bufio.Reader, client bool) *Conn {
+	return &Conn{
+		conn:   conn,
+		reader: reader,
+		client: client,
+	}
+}
+
+// ReadMessage reads the next text or binary message and returns its payload. Fragmented messages
+// are reassembled and pings are answered. It returns `io.EOF` once the peer closed the connection.
+func (c *Conn) ReadMessage() ([]byte, error) {
+	maxSize := c.MaxMessageSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxMessageSize
+	}
+
+	var (
+		message []byte
+		text    bool
+		started bool
+	)
+	for {
+		fin, op, payload, err := c.readFrame(maxSize - len(message))
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case op == opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case op == opPong:
+			continue
+		case op == opClose:
+			// Echo the status code back to complete the closing handshake.
+			if len(payload) >= 2 {
+				payload = payload[:2]
+			}
+			c.writeClose(payload)
+			return nil, io.EOF
+		case op == opContinuation && !started, (op == opText || op == opBinary) && started:
+			return nil, c.fail(statusProtocolError, "unexpected frame in message")
+		case op == opText || op == opBinary:
+			started = true
+			text = op == opText
+		case op != opContinuation:
+			return nil, c.fail(statusProtocolError, fmt.Sprintf("unknown opcode %#x", byte(op)))
+		}
+
+		message = append(message, payload...)
+		if fin {
+			break
+		}
+	}
+
+	if text && !utf8.Valid(message) {
+		return nil, c.fail(statusInvalidPayload, "text message is not valid UTF-8")
+	}
+	return message, nil
+}
+
+// readFrame reads a single frame whose payload may be at most maxSize bytes.
+func (c *Conn) readFrame(maxSize int) (fin bool, op opcode, payload []byte, err error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin = header[0]&0x80 != 0
+	op = opcode(header[0] & 0x0F)
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, c.fail(statusProtocolError, "reserved bits are set")
+	}
+
+	masked := header[1]&0x80 != 0
+	if masked == c.client {
+		// Clients must mask every frame they send and servers must never mask them.
+		return false, 0, nil, c.fail(statusProtocolError, "invalid frame masking")
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return false, 0, nil, unexpectedEOF(err)
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return false, 0, nil, unexpectedEOF(err)
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	if op.isControl() && (length > maxControlPayload || !fin) {
+		return false, 0, nil, c.fail(statusProtocolError, "invalid control frame")
+	}
+	if !op.isControl() && length > uint64(maxSize) {
+		c.fail(statusMessageTooLarge, "")
+		return false, 0, nil, ErrMessageTooLarge
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.reader, mask[:]); err != nil {
+			return false, 0, nil, unexpectedEOF(err)
+		}
+	}
+
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return false, 0, nil, unexpectedEOF(err)
+	}
+	if masked {
+		maskBytes(mask, payload)
+	}
+	return fin, op, payload, nil
+}
+
+// WriteMessage sends the payload as a single text message.
+func (c *Conn) WriteMessage(payload []byte) error {
+	return c.writeFrame(opText, payload)
+}
+
+// writeFrame sends the payload in a single frame.
+func (c *Conn) writeFrame(op opcode, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closeSent {
+		return net.ErrClosed
+	}
+	if op == opClose {
+		c.closeSent = true
+	}
+
+	frame := make([]byte, 0, 14+len(payload))
+	frame = append(frame, 0x80|byte(op))
+
+	maskBit := byte(0)
+	if c.client {
+		maskBit = 0x80
+	}
+	switch {
+	case len(payload) <= 125:
+		frame = append(frame, maskBit|byte(len(payload)))
+	case len(payload) <= 0xFFFF:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(payload)))
+	}
+
+	if c.client {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		frame = append(frame, mask[:]...)
+		start := len(frame)
+		frame = append(frame, payload...)
+		maskBytes(mask, frame[start:])
+	} else {
+		frame = append(frame, payload...)
+	}
+
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// writeClose sends a close frame with the given payload, unless one was already sent.
+func (c *Conn) writeClose(payload []byte) {
+	// The error is ignored: the peer may already be gone, and the connection is closing anyway.
+	c.writeFrame(opClose, payload)
+}
+
+// fail closes the connection with the status code after a protocol violation by the peer.
+func (c *Conn) fail(status uint16, reason string) error {
+	payload := binary.BigEndian.AppendUint16(nil, status)
+	c.writeClose(append(payload, reason...))
+	c.conn.Close()
+	return fmt.Errorf("websocket: %s", reason)
+}
+
+// Close sends a close frame and closes the underlying connection.
+func (c *Conn) Close() error {
+	c.writeClose(binary.BigEndian.AppendUint16(nil, statusNormalClosure))
+	return c.conn.Close()
+}
+
+// maskBytes applies the masking key to the payload, in place. Masking and unmasking are the same
+// operation.
+func maskBytes(mask [4]byte, payload []byte) {
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+}
+
+// acceptKey computes the `Sec-WebSocket-Accept` header for the client's `Sec-WebSocket-Key`.
+func acceptKey(key string) string {
+	h := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// unexpectedEOF converts an `io.EOF` in the middle of a frame into `io.ErrUnexpectedEOF`.
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,353 @@
+package websocket
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestServer starts an HTTP server that upgrades every request and hands the server end of
+// the connection to the handler. It returns the client end.
+func newTestServer(t *testing.T, handler func(conn *Conn)) *Conn {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		handler(conn)
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := Dial("ws" + strings.TrimPrefix(srv.URL, "http"))
+	if err != nil {
+		t.Fatalf("Dial got unexpected error: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// echo sends every message back until the connection is closed.
+func echo(conn *Conn) {
+	for {
+		msg, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		conn.WriteMessage(msg)
+	}
+}
+
+// writeRawFrame sends a masked frame from a client, bypassing the checks of `writeFrame`.
+func writeRawFrame(t *testing.T, c *Conn, fin bool, op opcode, payload []byte) {
+	t.Helper()
+
+	first := byte(op)
+	if fin {
+		first |= 0x80
+	}
+	frame := []byte{first, 0x80 | byte(len(payload))}
+	var mask [4]byte
+	rand.Read(mask[:])
+	frame = append(frame, mask[:]...)
+	masked := append([]byte(nil), payload...)
+	maskBytes(mask, masked)
+	if _, err := c.conn.Write(append(frame, masked...)); err != nil {
+		t.Fatalf("Write got unexpected error: %v", err)
+	}
+}
+
+func TestConnEcho(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		message string
+	}{
+		{
+			name:    "empty message",
+			message: "",
+		},
+		{
+			name:    "short message",
+			message: `{"jsonrpc":"2.0","method":"initialized"}`,
+		},
+		{
+			name:    "message with a 16-bit length",
+			message: strings.Repeat("a", 1000),
+		},
+		{
+			name:    "message with a 64-bit length",
+			message: strings.Repeat("a", 70_000),
+		},
+	}
+
+	client := newTestServer(t, echo)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := client.WriteMessage([]byte(tc.message)); err != nil {
+				t.Fatalf("WriteMessage got unexpected error: %v", err)
+			}
+			got, err := client.ReadMessage()
+			if err != nil {
+				t.Fatalf("ReadMessage got unexpected error: %v", err)
+			}
+			if string(got) != tc.message {
+				t.Errorf("ReadMessage got %.40q, want %.40q", got, tc.message)
+			}
+		})
+	}
+}
+
+func TestConnFragmentsAndControlFrames(t *testing.T) {
+	t.Parallel()
+
+	client := newTestServer(t, echo)
+
+	// A ping between the fragments of a message is answered right away.
+	writeRawFrame(t, client, false, opText, []byte("hello "))
+	writeRawFrame(t, client, true, opPing, []byte("ping"))
+	writeRawFrame(t, client, true, opContinuation, []byte("world"))
+
+	fin, op, payload, err := client.readFrame(DefaultMaxMessageSize)
+	if err != nil || !fin || op != opPong || string(payload) != "ping" {
+		t.Fatalf("readFrame got (%v, %v, %q, %v), want a pong", fin, op, payload, err)
+	}
+	got, err := client.ReadMessage()
+	if err != nil {
+		t.Fatalf("ReadMessage got unexpected error: %v", err)
+	}
+	if string(got) != "hello world" {
+		t.Errorf("ReadMessage got %q, want %q", got, "hello world")
+	}
+}
+
+func TestConnProtocolErrors(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name       string
+		send       func(t *testing.T, c *Conn)
+		wantStatus uint16
+	}{
+		{
+			name: "continuation without a message",
+			send: func(t *testing.T, c *Conn) {
+				writeRawFrame(t, c, true, opContinuation, []byte("a"))
+			},
+			wantStatus: statusProtocolError,
+		},
+		{
+			name: "invalid utf-8 text",
+			send: func(t *testing.T, c *Conn) {
+				writeRawFrame(t, c, true, opText, []byte{0xff, 0xfe})
+			},
+			wantStatus: statusInvalidPayload,
+		},
+		{
+			name: "message over the maximum size",
+			send: func(t *testing.T, c *Conn) {
+				c.WriteMessage(bytes.Repeat([]byte("a"), 100))
+			},
+			wantStatus: statusMessageTooLarge,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			readErr := make(chan error, 1)
+			client := newTestServer(t, func(conn *Conn) {
+				conn.MaxMessageSize = 10
+				_, err := conn.ReadMessage()
+				readErr <- err
+			})
+			tc.send(t, client)
+
+			if err := <-readErr; err == nil {
+				t.Errorf("ReadMessage got no error")
+			}
+			_, op, payload, err := client.readFrame(DefaultMaxMessageSize)
+			if err != nil || op != opClose || len(payload) < 2 {
+				t.Fatalf("readFrame got (%v, %q, %v), want a close frame", op, payload, err)
+			}
+			if got := binary.BigEndian.Uint16(payload); got != tc.wantStatus {
+				t.Errorf("got close status %d, want %d", got, tc.wantStatus)
+			}
+		})
+	}
+}
+
+func TestConnClose(t *testing.T) {
+	t.Parallel()
+
+	readErr := make(chan error, 1)
+	client := newTestServer(t, func(conn *Conn) {
+		_, err := conn.ReadMessage()
+		readErr <- err
+	})
+	client.Close()
+
+	if err := <-readErr; !errors.Is(err, io.EOF) {
+		t.Errorf("ReadMessage got error = %v, want %v", err, io.EOF)
+	}
+	if err := client.WriteMessage([]byte("late")); err == nil {
+		t.Errorf("WriteMessage after Close got no error")
+	}
+}
+
+func TestUpgradeRejectsInvalidHandshake(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Upgrade(w, r)
+	}))
+	defer srv.Close()
+
+	testCases := []struct {
+		name   string
+		header http.Header
+	}{
+		{
+			name:   "plain http request",
+			header: http.Header{},
+		},
+		{
+			name: "unsupported version",
+			header: http.Header{
+				"Connection":            {"Upgrade"},
+				"Upgrade":               {"websocket"},
+				"Sec-WebSocket-Version": {"8"},
+				"Sec-WebSocket-Key":     {"dGhlIHNhbXBsZSBub25jZQ=="},
+			},
+		},
+		{
+			name: "invalid key",
+			header: http.Header{
+				"Connection":            {"keep-alive, Upgrade"},
+				"Upgrade":               {"websocket"},
+				"Sec-WebSocket-Version": {"13"},
+				"Sec-WebSocket-Key":     {"short"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+			req.Header = tc.header
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Do got unexpected error: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("got status %d, want %d", resp.StatusCode, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestUpgradeChecksOrigin(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if conn, err := Upgrade(w, r, "https://editor.example.com"); err == nil {
+			conn.Close()
+		}
+	}))
+	defer srv.Close()
+
+	testCases := []struct {
+		name   string
+		origin string
+		// host is the Host header of the request, the address of the server if empty.
+		host       string
+		wantStatus int
+	}{
+		{
+			name:       "no origin",
+			wantStatus: http.StatusSwitchingProtocols,
+		},
+		{
+			name:       "same host",
+			origin:     srv.URL,
+			wantStatus: http.StatusSwitchingProtocols,
+		},
+		{
+			name:       "allowed origin",
+			origin:     "https://EDITOR.example.com",
+			wantStatus: http.StatusSwitchingProtocols,
+		},
+		{
+			name:       "other site",
+			origin:     "https://attacker.example.com",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "localhost",
+			origin:     "http://localhost:7777",
+			host:       "localhost:7777",
+			wantStatus: http.StatusSwitchingProtocols,
+		},
+		{
+			name:       "same host that is not a loopback address",
+			origin:     "http://evil.example.com:7777",
+			host:       "evil.example.com:7777",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "same host on another port",
+			origin:     "http://127.0.0.1:1",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "opaque origin",
+			origin:     "null",
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+			req.Header = http.Header{
+				"Connection":            {"Upgrade"},
+				"Upgrade":               {"websocket"},
+				"Sec-WebSocket-Version": {"13"},
+				"Sec-WebSocket-Key":     {"dGhlIHNhbXBsZSBub25jZQ=="},
+			}
+			if tc.origin != "" {
+				req.Header.Set("Origin", tc.origin)
+			}
+			if tc.host != "" {
+				req.Host = tc.host
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Do got unexpected error: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tc.wantStatus {
+				t.Errorf("got status %d, want %d", resp.StatusCode, tc.wantStatus)
+			}
+		})
+	}
+}
+
+func TestAcceptKey(t *testing.T) {
+	t.Parallel()
+
+	// The example from RFC 6455, section 1.3.
+	got := acceptKey("dGhlIHNhbXBsZSBub25jZQ==")
+	if want := "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="; got != want {
+		t.Errorf("acceptKey got %q, want %q", got, want)
+	}
+}